@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Summary granularities supported by the summary endpoint
+const (
+	GranularityDaily   = "daily"
+	GranularityWeekly  = "weekly"
+	GranularityMonthly = "monthly"
+)
+
+// GranularityMinute is accepted by the ohlc endpoint only, returning raw minute bars
+const GranularityMinute = "minute"
+
+func isValidGranularity(granularity string) bool {
+	switch granularity {
+	case GranularityDaily, GranularityWeekly, GranularityMonthly:
+		return true
+	}
+	return false
+}
+
+// summaryPeriod describes how daily summaries roll up into a longer bucket
+type summaryPeriod struct {
+	table string
+	start func(time.Time) time.Time // Bucket start containing the given date
+	next  func(time.Time) time.Time // Start of the following bucket
+}
+
+var (
+	weeklyPeriod = summaryPeriod{
+		table: StockWeeklySummary{}.TableName(),
+		start: weekStart,
+		next:  func(start time.Time) time.Time { return start.AddDate(0, 0, 7) },
+	}
+	monthlyPeriod = summaryPeriod{
+		table: StockMonthlySummary{}.TableName(),
+		start: monthStart,
+		next:  func(start time.Time) time.Time { return start.AddDate(0, 1, 0) },
+	}
+)
+
+// weekStart returns the Monday of the week containing date. Daily summary dates
+// are stored as midnight UTC of the market date, so the weekday is the market weekday.
+func weekStart(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7 // Monday=0 ... Sunday=6
+	return time.Date(date.Year(), date.Month(), date.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// monthStart returns the first day of the month containing date
+func monthStart(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// aggregateSummaries combines consecutive summaries into a single OHLCV bucket
+// dated at bucketDate. Rows must be sorted by date ascending.
+func aggregateSummaries(symbol string, bucketDate time.Time, rows []DailySummaryAPI) DailySummaryAPI {
+	bucket := DailySummaryAPI{
+		Symbol: symbol,
+		Date:   bucketDate,
+		Open:   rows[0].Open,
+		High:   rows[0].High,
+		Low:    rows[0].Low,
+		Close:  rows[len(rows)-1].Close,
+	}
+
+	for _, row := range rows {
+		if row.High > bucket.High {
+			bucket.High = row.High
+		}
+		if row.Low < bucket.Low {
+			bucket.Low = row.Low
+		}
+		bucket.Volume += row.Volume
+		if row.CreateAt.After(bucket.CreateAt) {
+			bucket.CreateAt = row.CreateAt
+		}
+	}
+
+	return bucket
+}
+
+// updatePeriodSummaries recomputes only the buckets containing the given daily
+// dates, reading the daily summaries that fall inside each bucket
+func updatePeriodSummaries(tx *gorm.DB, period summaryPeriod, symbol string, dates []time.Time) error {
+	// Collect the distinct buckets touched by these dates
+	starts := make(map[time.Time]bool)
+	for _, date := range dates {
+		starts[period.start(date)] = true
+	}
+
+	for start := range starts {
+		var days []StockDailySummary
+		err := tx.Where("symbol = ? AND date >= ? AND date < ?", symbol, start, period.next(start)).
+			Order("date ASC").
+			Find(&days).Error
+		if err != nil {
+			return fmt.Errorf("failed to query daily summaries for %s: %v", period.table, err)
+		}
+
+		if len(days) == 0 {
+			continue
+		}
+
+		rows := make([]DailySummaryAPI, 0, len(days))
+		for _, day := range days {
+			rows = append(rows, toDailySummaryAPI(day))
+		}
+		bucket := aggregateSummaries(symbol, start, rows)
+
+		now := time.Now()
+		result := tx.Table(period.table).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "symbol"}, {Name: "date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "updated_at"}),
+		}).Create(map[string]interface{}{
+			"symbol":     symbol,
+			"date":       start,
+			"open":       NewPrice(bucket.Open),
+			"high":       NewPrice(bucket.High),
+			"low":        NewPrice(bucket.Low),
+			"close":      NewPrice(bucket.Close),
+			"volume":     bucket.Volume,
+			"created_at": now,
+			"updated_at": now,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to upsert %s for %s: %v", period.table, start.Format("2006-01-02"), result.Error)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestWeekStartAroundYearEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		date time.Time
+		want time.Time
+	}{
+		{name: "new year's eve 2024 in ISO week 1 of 2025", date: date(2024, 12, 31), want: date(2024, 12, 30)},
+		{name: "new year's day 2025 in ISO week 1 of 2025", date: date(2025, 1, 1), want: date(2024, 12, 30)},
+		{name: "friday of ISO week 1 of 2025", date: date(2025, 1, 3), want: date(2024, 12, 30)},
+		{name: "sunday 2021-01-03 in ISO week 53 of 2020", date: date(2021, 1, 3), want: date(2020, 12, 28)},
+		{name: "monday 2021-01-04 starts ISO week 1", date: date(2021, 1, 4), want: date(2021, 1, 4)},
+		{name: "saturday 2022-01-01 in ISO week 52 of 2021", date: date(2022, 1, 1), want: date(2021, 12, 27)},
+		{name: "friday 2021-12-31 in ISO week 52 of 2021", date: date(2021, 12, 31), want: date(2021, 12, 27)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := weekStart(tt.date)
+			if !got.Equal(tt.want) {
+				t.Errorf("weekStart(%s) = %s, want %s", tt.date.Format("2006-01-02"), got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+
+			// The bucket is the ISO week of the date
+			gotYear, gotWeek := got.ISOWeek()
+			wantYear, wantWeek := tt.date.ISOWeek()
+			if gotYear != wantYear || gotWeek != wantWeek {
+				t.Errorf("bucket is ISO week %d-%d, date is in %d-%d", gotYear, gotWeek, wantYear, wantWeek)
+			}
+		})
+	}
+}
+
+func TestPeriodSummariesAcrossYearEnd(t *testing.T) {
+	// Trading days from Monday 2024-12-30 to Friday 2025-01-03; New Year's
+	// Day is a holiday
+	days := []StockDailySummary{
+		{Date: date(2024, 12, 30), Open: NewPrice(10), High: NewPrice(12), Low: NewPrice(9), Close: NewPrice(11), Volume: 100},
+		{Date: date(2024, 12, 31), Open: NewPrice(11), High: NewPrice(13), Low: NewPrice(10), Close: NewPrice(12), Volume: 200},
+		{Date: date(2025, 1, 2), Open: NewPrice(12), High: NewPrice(15), Low: NewPrice(8), Close: NewPrice(14), Volume: 300},
+		{Date: date(2025, 1, 3), Open: NewPrice(14), High: NewPrice(14), Low: NewPrice(13), Close: NewPrice(13), Volume: 400},
+	}
+
+	type bucket struct {
+		date                   time.Time
+		open, high, low, close float64
+		volume                 int64
+	}
+	tests := []struct {
+		name   string
+		period summaryPeriod
+		want   []bucket
+	}{
+		{
+			name:   "weekly bucket spans the year boundary",
+			period: weeklyPeriod,
+			want:   []bucket{{date(2024, 12, 30), 10, 15, 8, 13, 1000}},
+		},
+		{
+			name:   "monthly buckets split at the year boundary",
+			period: monthlyPeriod,
+			want: []bucket{
+				{date(2024, 12, 1), 10, 13, 9, 12, 300},
+				{date(2025, 1, 1), 12, 15, 8, 13, 700},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			dates := make([]time.Time, 0, len(days))
+			for _, day := range days {
+				day.Symbol = "AAPL"
+				if err := database.db.Create(&day).Error; err != nil {
+					t.Fatalf("create daily summary: %v", err)
+				}
+				dates = append(dates, day.Date)
+			}
+
+			if err := updatePeriodSummaries(database.db.WithContext(context.Background()), tt.period, "AAPL", dates); err != nil {
+				t.Fatalf("updatePeriodSummaries: %v", err)
+			}
+
+			var rows []StockWeeklySummary
+			if err := database.db.Table(tt.period.table).Where("symbol = ?", "AAPL").Order("date ASC").Find(&rows).Error; err != nil {
+				t.Fatalf("query %s: %v", tt.period.table, err)
+			}
+			if len(rows) != len(tt.want) {
+				t.Fatalf("got %d buckets, want %d", len(rows), len(tt.want))
+			}
+			for i, want := range tt.want {
+				row := rows[i]
+				got := bucket{row.Date.UTC(), row.Open.Float64(), row.High.Float64(), row.Low.Float64(), row.Close.Float64(), row.Volume}
+				if got != want {
+					t.Errorf("bucket %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultSymbolAliases maps renamed tickers and common company names to the
+// current ticker. GOOG and GOOGL are both live share classes, so only the
+// company names point at one of them.
+var defaultSymbolAliases = map[string]string{
+	"FB":        "META",
+	"FACEBOOK":  "META",
+	"GOOGLE":    "GOOGL",
+	"ALPHABET":  "GOOGL",
+	"ANTM":      "ELV",
+	"FISV":      "FI",
+	"PEAK":      "DOC",
+	"SQ":        "XYZ",
+	"APPLE":     "AAPL",
+	"MICROSOFT": "MSFT",
+	"AMAZON":    "AMZN",
+	"TESLA":     "TSLA",
+	"NVIDIA":    "NVDA",
+	"NETFLIX":   "NFLX",
+}
+
+// SymbolAliases resolves what users type to canonical tickers
+type SymbolAliases struct {
+	aliases map[string]string
+}
+
+// NewSymbolAliases returns a resolver for the built-in aliases, when builtin
+// is set, plus extra, which overrides them
+func NewSymbolAliases(builtin bool, extra map[string]string) *SymbolAliases {
+	aliases := make(map[string]string)
+	if builtin {
+		for alias, symbol := range defaultSymbolAliases {
+			aliases[alias] = symbol
+		}
+	}
+	for alias, symbol := range extra {
+		aliases[NormalizeSymbol(alias)] = NormalizeSymbol(symbol)
+	}
+	return &SymbolAliases{aliases: aliases}
+}
+
+// Resolve returns the ticker input stands for and whether it was an alias.
+// Input that isn't an alias comes back normalized. A nil resolver only
+// normalizes.
+func (a *SymbolAliases) Resolve(input string) (string, bool) {
+	symbol := NormalizeSymbol(input)
+	if a == nil {
+		return symbol, false
+	}
+	if resolved, ok := a.aliases[symbol]; ok && resolved != symbol {
+		return resolved, true
+	}
+	return symbol, false
+}
+
+// NormalizeSymbol upper-cases input and strips surrounding whitespace
+func NormalizeSymbol(input string) string {
+	return strings.ToUpper(strings.TrimSpace(input))
+}
+
+// parseSymbolAliases reads comma-separated ALIAS=SYMBOL pairs, e.g.
+// "FB=META,FACEBOOK=META"
+func parseSymbolAliases(s string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		alias, symbol, ok := strings.Cut(pair, "=")
+		alias, symbol = NormalizeSymbol(alias), NormalizeSymbol(symbol)
+		if !ok || alias == "" || !isValidSymbol(symbol) {
+			return nil, fmt.Errorf("invalid alias %q, expected ALIAS=SYMBOL", pair)
+		}
+		aliases[alias] = symbol
+	}
+	return aliases, nil
+}
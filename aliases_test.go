@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSymbolAliasesResolve(t *testing.T) {
+	builtin := NewSymbolAliases(true, nil)
+	overridden := NewSymbolAliases(true, map[string]string{"fb": "metax", "Instagram": "meta"})
+	extraOnly := NewSymbolAliases(false, map[string]string{"FB": "META"})
+
+	tests := []struct {
+		name        string
+		aliases     *SymbolAliases
+		input       string
+		want        string
+		wantAliased bool
+	}{
+		{name: "ticker change", aliases: builtin, input: "FB", want: "META", wantAliased: true},
+		{name: "company name, any case", aliases: builtin, input: " facebook ", want: "META", wantAliased: true},
+		{name: "Google to its class A shares", aliases: builtin, input: "Google", want: "GOOGL", wantAliased: true},
+		{name: "GOOG stays a share class of its own", aliases: builtin, input: "goog", want: "GOOG"},
+		{name: "current ticker", aliases: builtin, input: "META", want: "META"},
+		{name: "Square to Block", aliases: builtin, input: "SQ", want: "XYZ", wantAliased: true},
+		{name: "configured alias overrides", aliases: overridden, input: "FB", want: "METAX", wantAliased: true},
+		{name: "configured alias added", aliases: overridden, input: "instagram", want: "META", wantAliased: true},
+		{name: "built-ins off", aliases: extraOnly, input: "FACEBOOK", want: "FACEBOOK"},
+		{name: "configured alias without built-ins", aliases: extraOnly, input: "fb", want: "META", wantAliased: true},
+		{name: "nil resolver only normalizes", aliases: nil, input: " fb ", want: "FB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, aliased := tt.aliases.Resolve(tt.input)
+			if got != tt.want || aliased != tt.wantAliased {
+				t.Errorf("Resolve(%q) = %q, %v; want %q, %v", tt.input, got, aliased, tt.want, tt.wantAliased)
+			}
+		})
+	}
+}
+
+func TestParseSymbolAliases(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{input: "FB=META", want: map[string]string{"FB": "META"}},
+		{input: " fb = meta ,Facebook=META", want: map[string]string{"FB": "META", "FACEBOOK": "META"}},
+		{input: "FB", wantErr: true},
+		{input: "=META", wantErr: true},
+		{input: "FB=", wantErr: true},
+		{input: "FB=META,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSymbolAliases(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSymbolAliases(%q) error = %v, want error %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSymbolAliases(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSyncResolvesAlias(t *testing.T) {
+	tests := []struct {
+		name             string
+		resolve          bool
+		path             string
+		wantStatus       int
+		wantSymbol       string
+		wantResolvedFrom string
+	}{
+		{name: "old ticker", resolve: true, path: "/api/stocks/FB/sync", wantStatus: http.StatusOK, wantSymbol: "META", wantResolvedFrom: "FB"},
+		{name: "company name", resolve: true, path: "/api/stocks/facebook/sync", wantStatus: http.StatusOK, wantSymbol: "META", wantResolvedFrom: "facebook"},
+		{name: "current ticker", resolve: true, path: "/api/stocks/META/sync", wantStatus: http.StatusOK},
+		{name: "resolution off", resolve: false, path: "/api/stocks/FB/sync", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newStubbedWebServer(t, Config{ResolveAliases: tt.resolve}, func(w http.ResponseWriter, r *http.Request) {
+				w.Write(chartJSON(t, "META", stubBar{time.Now().Add(-time.Hour).Truncate(time.Minute), 500, 10}))
+			})
+			if _, err := ws.collector.database.AddWatchedStock(context.Background(), "META", "Meta Platforms"); err != nil {
+				t.Fatalf("AddWatchedStock: %v", err)
+			}
+
+			w := serve(ws, http.MethodPost, tt.path, "")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var response SyncResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if response.Symbol != tt.wantSymbol || response.ResolvedFrom != tt.wantResolvedFrom {
+				t.Errorf("symbol %q resolved from %q, want %q from %q", response.Symbol, response.ResolvedFrom, tt.wantSymbol, tt.wantResolvedFrom)
+			}
+		})
+	}
+}
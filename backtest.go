@@ -0,0 +1,122 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// BacktestParams configures an SMA-crossover simulation
+type BacktestParams struct {
+	Fast        int     // Fast moving-average period in days
+	Slow        int     // Slow moving-average period in days
+	InitialCash float64 // Starting cash
+}
+
+// BacktestTrade is one completed round trip (buy then sell)
+type BacktestTrade struct {
+	EntryDate     time.Time `json:"entryDate"`
+	EntryPrice    float64   `json:"entryPrice"`
+	ExitDate      time.Time `json:"exitDate"`
+	ExitPrice     float64   `json:"exitPrice"`
+	Shares        float64   `json:"shares"`
+	Profit        float64   `json:"profit"`
+	ReturnPercent float64   `json:"returnPercent"`
+}
+
+// BacktestResult summarises a simulation. Percentages are expressed as 0-100.
+type BacktestResult struct {
+	Trades      []BacktestTrade `json:"trades"`
+	FinalEquity float64         `json:"finalEquity"`
+	TotalReturn float64         `json:"totalReturn"`
+	MaxDrawdown float64         `json:"maxDrawdown"`
+	WinRate     float64         `json:"winRate"`
+}
+
+// Backtest runs a long-only SMA crossover over daily bars: buy with all cash
+// at the close when the fast SMA crosses above the slow SMA, sell everything
+// when it crosses back below. An open position is closed at the last bar.
+// Bars may be in any order; with fewer than Slow+1 bars no trades are made.
+func Backtest(bars []DailySummaryAPI, params BacktestParams) BacktestResult {
+	result := BacktestResult{
+		Trades:      []BacktestTrade{},
+		FinalEquity: params.InitialCash,
+	}
+
+	if params.Fast <= 0 || params.Slow <= params.Fast || len(bars) <= params.Slow {
+		return result
+	}
+
+	// Work on a chronologically sorted copy
+	sorted := make([]DailySummaryAPI, len(bars))
+	copy(sorted, bars)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	closes := make([]float64, len(sorted))
+	for i, bar := range sorted {
+		closes[i] = bar.Close
+	}
+	fast := SMA(closes, params.Fast)
+	slow := SMA(closes, params.Slow)
+
+	cash := params.InitialCash
+	var shares float64
+	var entry BacktestTrade
+	peak := params.InitialCash
+	wins := 0
+
+	sell := func(bar DailySummaryAPI) {
+		trade := entry
+		trade.ExitDate = bar.Date
+		trade.ExitPrice = bar.Close
+		trade.Profit = roundToDecimal((bar.Close-trade.EntryPrice)*trade.Shares, 2)
+		trade.ReturnPercent = roundToDecimal(safeDiv(bar.Close-trade.EntryPrice, trade.EntryPrice)*100, 2)
+		if trade.Profit > 0 {
+			wins++
+		}
+		result.Trades = append(result.Trades, trade)
+
+		cash = shares * bar.Close
+		shares = 0
+	}
+
+	for i := params.Slow; i < len(sorted); i++ {
+		bar := sorted[i]
+		crossedUp := fast[i-1] <= slow[i-1] && fast[i] > slow[i]
+		crossedDown := fast[i-1] >= slow[i-1] && fast[i] < slow[i]
+
+		if crossedUp && shares == 0 && bar.Close > 0 {
+			shares = cash / bar.Close
+			cash = 0
+			entry = BacktestTrade{
+				EntryDate:  bar.Date,
+				EntryPrice: bar.Close,
+				Shares:     shares,
+			}
+		} else if crossedDown && shares > 0 {
+			sell(bar)
+		}
+
+		// Track drawdown on the daily equity curve
+		equity := cash + shares*bar.Close
+		if equity > peak {
+			peak = equity
+		}
+		if drawdown := safeDiv(peak-equity, peak) * 100; drawdown > result.MaxDrawdown {
+			result.MaxDrawdown = drawdown
+		}
+	}
+
+	// Close any open position at the final bar
+	if shares > 0 {
+		sell(sorted[len(sorted)-1])
+	}
+
+	result.FinalEquity = roundToDecimal(cash, 2)
+	result.MaxDrawdown = roundToDecimal(result.MaxDrawdown, 2)
+	result.TotalReturn = roundToDecimal(safeDiv(cash-params.InitialCash, params.InitialCash)*100, 2)
+	result.WinRate = roundToDecimal(safeDiv(float64(wins), float64(len(result.Trades)))*100, 2)
+
+	return result
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// dailyCloses returns one summary per close on consecutive days from 2024-01-01
+func dailyCloses(closes ...float64) []DailySummaryAPI {
+	bars := make([]DailySummaryAPI, len(closes))
+	for i, c := range closes {
+		bars[i] = DailySummaryAPI{
+			Symbol: "AAPL",
+			Date:   time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC),
+			Open:   c, High: c, Low: c, Close: c,
+		}
+	}
+	return bars
+}
+
+func reversed(bars []DailySummaryAPI) []DailySummaryAPI {
+	out := make([]DailySummaryAPI, len(bars))
+	for i, bar := range bars {
+		out[len(bars)-1-i] = bar
+	}
+	return out
+}
+
+func TestBacktest(t *testing.T) {
+	// With fast=1 and slow=2 the fast SMA is the close: buy at 10 on day 3,
+	// sell at 11 on day 5 (peak equity 1200 on day 4), buy again at 10 on day
+	// 7 and close that position flat at the last bar
+	crossing := dailyCloses(10, 9, 10, 12, 11, 9, 10)
+
+	tests := []struct {
+		name       string
+		bars       []DailySummaryAPI
+		params     BacktestParams
+		wantTrades int
+		want       BacktestResult
+	}{
+		{
+			name:       "two round trips",
+			bars:       crossing,
+			params:     BacktestParams{Fast: 1, Slow: 2, InitialCash: 1000},
+			wantTrades: 2,
+			want:       BacktestResult{FinalEquity: 1100, TotalReturn: 10, MaxDrawdown: 8.33, WinRate: 50},
+		},
+		{
+			name:       "unsorted bars give the same result",
+			bars:       reversed(crossing),
+			params:     BacktestParams{Fast: 1, Slow: 2, InitialCash: 1000},
+			wantTrades: 2,
+			want:       BacktestResult{FinalEquity: 1100, TotalReturn: 10, MaxDrawdown: 8.33, WinRate: 50},
+		},
+		{
+			name:   "too few bars for the slow SMA",
+			bars:   dailyCloses(10, 11),
+			params: BacktestParams{Fast: 1, Slow: 2, InitialCash: 1000},
+			want:   BacktestResult{FinalEquity: 1000},
+		},
+		{
+			name:   "slow period not above fast",
+			bars:   crossing,
+			params: BacktestParams{Fast: 3, Slow: 3, InitialCash: 1000},
+			want:   BacktestResult{FinalEquity: 1000},
+		},
+		{
+			name:   "no crossover never trades",
+			bars:   dailyCloses(10, 10, 10, 10, 10),
+			params: BacktestParams{Fast: 1, Slow: 2, InitialCash: 1000},
+			want:   BacktestResult{FinalEquity: 1000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Backtest(tt.bars, tt.params)
+			if len(got.Trades) != tt.wantTrades {
+				t.Fatalf("got %d trades, want %d: %+v", len(got.Trades), tt.wantTrades, got.Trades)
+			}
+			if got.FinalEquity != tt.want.FinalEquity || got.TotalReturn != tt.want.TotalReturn ||
+				got.MaxDrawdown != tt.want.MaxDrawdown || got.WinRate != tt.want.WinRate {
+				t.Errorf("got equity %v return %v drawdown %v win rate %v, want %v %v %v %v",
+					got.FinalEquity, got.TotalReturn, got.MaxDrawdown, got.WinRate,
+					tt.want.FinalEquity, tt.want.TotalReturn, tt.want.MaxDrawdown, tt.want.WinRate)
+			}
+		})
+	}
+}
+
+func TestBacktestTrades(t *testing.T) {
+	got := Backtest(dailyCloses(10, 9, 10, 12, 11, 9, 10), BacktestParams{Fast: 1, Slow: 2, InitialCash: 1000})
+
+	day := func(n int) time.Time { return time.Date(2024, 1, n, 0, 0, 0, 0, time.UTC) }
+	want := []BacktestTrade{
+		{EntryDate: day(3), EntryPrice: 10, ExitDate: day(5), ExitPrice: 11, Shares: 100, Profit: 100, ReturnPercent: 10},
+		{EntryDate: day(7), EntryPrice: 10, ExitDate: day(7), ExitPrice: 10, Shares: 110, Profit: 0, ReturnPercent: 0},
+	}
+	if len(got.Trades) != len(want) {
+		t.Fatalf("got %d trades, want %d", len(got.Trades), len(want))
+	}
+	for i := range want {
+		if got.Trades[i] != want[i] {
+			t.Errorf("trade %d = %+v, want %+v", i, got.Trades[i], want[i])
+		}
+	}
+}
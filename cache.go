@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache backends selectable with -cache
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+)
+
+// Cache stores serialized API responses by key with a TTL
+type Cache interface {
+	// Get returns the cached value and whether it was found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// DeletePrefix removes every entry whose key starts with prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+	Close() error
+}
+
+// newCache builds the backend selected by cfg.CacheBackend
+func newCache(cfg Config) (Cache, error) {
+	switch cfg.CacheBackend {
+	case "", CacheBackendMemory:
+		return newMemoryCache(), nil
+	case CacheBackendRedis:
+		return newRedisCache(cfg.RedisAddr)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q, expected memory or redis", cfg.CacheBackend)
+	}
+}
+
+// Cache keys are "<kind>:<SYMBOL>:<query>" so a symbol's entries can be
+// dropped by prefix after a sync
+func symbolCacheKey(kind, symbol, query string) string {
+	return kind + ":" + symbol + ":" + query
+}
+
+// symbolCacheKinds are the response kinds keyed by symbol
+var symbolCacheKinds = []string{"summary", "data"}
+
+// invalidateSymbol drops cached responses for symbol, or for every symbol when
+// symbol is empty
+func invalidateSymbol(ctx context.Context, cache Cache, symbol string) error {
+	for _, kind := range symbolCacheKinds {
+		prefix := kind + ":"
+		if symbol != "" {
+			prefix = symbolCacheKey(kind, symbol, "")
+		}
+		if err := cache.DeletePrefix(ctx, prefix); err != nil {
+			return fmt.Errorf("failed to invalidate cache: %v", err)
+		}
+	}
+	return nil
+}
+
+// watchedSearchCacheKey keys a tenant's ?includeWatched=true search; every
+// one of them shares the tenant's prefix, as the watchlist they merge does
+func watchedSearchCacheKey(tenant, query string) string {
+	return "search:watched:" + tenant + ":" + query
+}
+
+// invalidateWatchedSearch drops tenant's cached watchlist searches, so a
+// symbol added or removed shows up in the next one
+func invalidateWatchedSearch(ctx context.Context, cache Cache, tenant string) error {
+	if err := cache.DeletePrefix(ctx, watchedSearchCacheKey(tenant, "")); err != nil {
+		return fmt.Errorf("failed to invalidate cache: %v", err)
+	}
+	return nil
+}
+
+// memoryCacheSweepSize is the entry count above which Set drops expired entries
+const memoryCacheSweepSize = 1024
+
+type memoryCacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// memoryCache is a process-local Cache; expired entries are dropped lazily
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{entries: make(map[string]memoryCacheEntry)}
+}
+
+func (m *memoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (m *memoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if len(m.entries) >= memoryCacheSweepSize {
+		for k, entry := range m.entries {
+			if now.After(entry.expires) {
+				delete(m.entries, k)
+			}
+		}
+	}
+
+	m.entries[key] = memoryCacheEntry{value: value, expires: now.Add(ttl)}
+	return nil
+}
+
+func (m *memoryCache) DeletePrefix(_ context.Context, prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.entries, key)
+		}
+	}
+	return nil
+}
+
+func (m *memoryCache) Close() error {
+	return nil
+}
+
+// redisKeyPrefix namespaces our keys in a shared Redis
+const redisKeyPrefix = "stock-collector:"
+
+// redisCache shares cached responses between processes, e.g. a CLI collect
+// run invalidates what the web server has cached
+type redisCache struct {
+	client *redis.Client
+}
+
+func newRedisCache(addr string) (*redisCache, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis at %s: %v", addr, err)
+	}
+
+	return &redisCache{client: client}, nil
+}
+
+func (r *redisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, redisKeyPrefix+key, value, ttl).Err()
+}
+
+func (r *redisCache) DeletePrefix(ctx context.Context, prefix string) error {
+	iter := r.client.Scan(ctx, 0, redisKeyPrefix+prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := r.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func (r *redisCache) Close() error {
+	return r.client.Close()
+}
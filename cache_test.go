@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		setKey  string
+		ttl     time.Duration
+		getKey  string
+		wantHit bool
+	}{
+		{name: "hit", setKey: "summary:AAPL:30", ttl: time.Minute, getKey: "summary:AAPL:30", wantHit: true},
+		{name: "miss on another key", setKey: "summary:AAPL:30", ttl: time.Minute, getKey: "summary:AAPL:7"},
+		{name: "miss once expired", setKey: "summary:AAPL:30", ttl: -time.Second, getKey: "summary:AAPL:30"},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newMemoryCache()
+			if err := cache.Set(ctx, tt.setKey, []byte("cached"), tt.ttl); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+
+			value, ok, err := cache.Get(ctx, tt.getKey)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if ok != tt.wantHit {
+				t.Fatalf("hit = %v, want %v", ok, tt.wantHit)
+			}
+			if ok && string(value) != "cached" {
+				t.Errorf("value = %q, want %q", value, "cached")
+			}
+		})
+	}
+}
+
+func TestInvalidateSymbol(t *testing.T) {
+	keys := []string{
+		symbolCacheKey("summary", "AAPL", "30"),
+		symbolCacheKey("data", "AAPL", "7"),
+		symbolCacheKey("summary", "AAPLX", "30"),
+		symbolCacheKey("data", "MSFT", "7"),
+		"search:apple:10",
+	}
+
+	tests := []struct {
+		name   string
+		symbol string
+		want   map[string]bool // whether each key is still cached
+	}{
+		{
+			name:   "one symbol",
+			symbol: "AAPL",
+			want: map[string]bool{
+				"summary:AAPL:30":  false,
+				"data:AAPL:7":      false,
+				"summary:AAPLX:30": true,
+				"data:MSFT:7":      true,
+				"search:apple:10":  true,
+			},
+		},
+		{
+			name:   "every symbol",
+			symbol: "",
+			want: map[string]bool{
+				"summary:AAPL:30":  false,
+				"data:AAPL:7":      false,
+				"summary:AAPLX:30": false,
+				"data:MSFT:7":      false,
+				"search:apple:10":  true,
+			},
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newMemoryCache()
+			for _, key := range keys {
+				if err := cache.Set(ctx, key, []byte("cached"), time.Minute); err != nil {
+					t.Fatalf("Set(%s): %v", key, err)
+				}
+			}
+
+			if err := invalidateSymbol(ctx, cache, tt.symbol); err != nil {
+				t.Fatalf("invalidateSymbol: %v", err)
+			}
+
+			for key, want := range tt.want {
+				if _, ok, _ := cache.Get(ctx, key); ok != want {
+					t.Errorf("%s cached = %v, want %v", key, ok, want)
+				}
+			}
+		})
+	}
+}
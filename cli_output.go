@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"text/tabwriter"
+	"time"
+)
+
+// CLI output formats. OutputFormatLog prints timestamped log lines as the CLI
+// always has; OutputFormatTable renders aligned columns.
+const (
+	OutputFormatLog   = "log"
+	OutputFormatTable = "table"
+)
+
+const cliTimeLayout = "2006-01-02 15:04:05"
+
+// isValidOutputFormat reports whether format is a supported CLI output format
+func isValidOutputFormat(format string) bool {
+	return format == OutputFormatLog || format == OutputFormatTable
+}
+
+// barAnalysis is the basic analysis the CLI prints for a range of minute bars
+type barAnalysis struct {
+	DataPoints    int
+	First         time.Time
+	Last          time.Time
+	Currency      string
+	MinPrice      float64
+	MaxPrice      float64
+	LatestPrice   float64
+	PriceChange   float64
+	ChangePercent float64
+	TotalVolume   int64
+	AvgVolume     float64
+
+	// Notable points, set when there are at least two bars
+	HasNotable   bool
+	MaxVolumeBar MinuteBar
+	MinPriceBar  MinuteBar
+}
+
+// analyzeBars computes the price range, change and volume figures for bars,
+// which must not be empty
+func analyzeBars(bars []MinuteBar) barAnalysis {
+	var minPrice, maxPrice float64 = bars[0].Close, bars[0].Close
+	var totalVolume int64 = 0
+
+	for _, bar := range bars {
+		if bar.Close < minPrice {
+			minPrice = bar.Close
+		}
+		if bar.Close > maxPrice {
+			maxPrice = bar.Close
+		}
+		totalVolume += bar.Volume
+	}
+
+	latestPrice := bars[len(bars)-1].Close
+	firstPrice := bars[0].Close
+	priceChange := latestPrice - firstPrice
+
+	a := barAnalysis{
+		DataPoints:    len(bars),
+		First:         bars[0].Timestamp,
+		Last:          bars[len(bars)-1].Timestamp,
+		Currency:      currencySymbol(bars[len(bars)-1].Currency),
+		MinPrice:      minPrice,
+		MaxPrice:      maxPrice,
+		LatestPrice:   latestPrice,
+		PriceChange:   priceChange,
+		ChangePercent: safeDiv(priceChange, firstPrice) * 100,
+		TotalVolume:   totalVolume,
+		AvgVolume:     safeDiv(float64(totalVolume), float64(len(bars))),
+	}
+	findHighLowBars(bars, &a)
+	return a
+}
+
+// findHighLowBars records the highest-volume and lowest-price bars
+func findHighLowBars(bars []MinuteBar, a *barAnalysis) {
+	if len(bars) < 2 {
+		return
+	}
+
+	maxVolume := bars[0].Volume
+	minPrice := bars[0].Close
+	a.MaxVolumeBar, a.MinPriceBar = bars[0], bars[0]
+
+	for _, bar := range bars {
+		if bar.Volume > maxVolume {
+			maxVolume = bar.Volume
+			a.MaxVolumeBar = bar
+		}
+		if bar.Close < minPrice {
+			minPrice = bar.Close
+			a.MinPriceBar = bar
+		}
+	}
+	a.HasNotable = true
+}
+
+// writeAnalysis renders an analysis to w in the given format
+func writeAnalysis(w io.Writer, format string, a barAnalysis) error {
+	if format == OutputFormatTable {
+		return writeAnalysisTable(w, a)
+	}
+
+	l := log.New(w, "", log.LstdFlags)
+	l.Printf("\n=== Basic Analysis ===")
+	l.Printf("Data Points: %d", a.DataPoints)
+	l.Printf("Date Range: %s to %s", a.First.Format(cliTimeLayout), a.Last.Format(cliTimeLayout))
+	l.Printf("Price Range: %s%.2f - %s%.2f", a.Currency, a.MinPrice, a.Currency, a.MaxPrice)
+	l.Printf("Current Price: %s%.2f", a.Currency, a.LatestPrice)
+	l.Printf("Price Change: %s%.2f (%.2f%%)", a.Currency, a.PriceChange, a.ChangePercent)
+	l.Printf("Total Volume: %d", a.TotalVolume)
+
+	if a.HasNotable {
+		l.Printf("\n=== Notable Points ===")
+		l.Printf("Highest Volume Day: %s (Volume: %d, Price: %s%.2f)",
+			a.MaxVolumeBar.Timestamp.Format(cliTimeLayout),
+			a.MaxVolumeBar.Volume, a.Currency, a.MaxVolumeBar.Close)
+		l.Printf("Lowest Price Point: %s (Price: %s%.2f)",
+			a.MinPriceBar.Timestamp.Format(cliTimeLayout),
+			a.Currency, a.MinPriceBar.Close)
+	}
+
+	l.Printf("Average Volume per Minute: %.0f", a.AvgVolume)
+	return nil
+}
+
+func writeAnalysisTable(w io.Writer, a barAnalysis) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "METRIC\tVALUE\n")
+	fmt.Fprintf(tw, "Data points\t%d\n", a.DataPoints)
+	fmt.Fprintf(tw, "Date range\t%s to %s\n", a.First.Format(cliTimeLayout), a.Last.Format(cliTimeLayout))
+	fmt.Fprintf(tw, "Price range\t%s%.2f - %s%.2f\n", a.Currency, a.MinPrice, a.Currency, a.MaxPrice)
+	fmt.Fprintf(tw, "Current price\t%s%.2f\n", a.Currency, a.LatestPrice)
+	fmt.Fprintf(tw, "Price change\t%s%.2f (%.2f%%)\n", a.Currency, a.PriceChange, a.ChangePercent)
+	fmt.Fprintf(tw, "Total volume\t%d\n", a.TotalVolume)
+	fmt.Fprintf(tw, "Avg volume/minute\t%.0f\n", a.AvgVolume)
+	if a.HasNotable {
+		fmt.Fprintf(tw, "Highest volume\t%s (%d @ %s%.2f)\n",
+			a.MaxVolumeBar.Timestamp.Format(cliTimeLayout), a.MaxVolumeBar.Volume, a.Currency, a.MaxVolumeBar.Close)
+		fmt.Fprintf(tw, "Lowest price\t%s (%s%.2f)\n",
+			a.MinPriceBar.Timestamp.Format(cliTimeLayout), a.Currency, a.MinPriceBar.Close)
+	}
+	return tw.Flush()
+}
+
+// writeSampleData renders the first limit bars, and the last 5 when there are
+// more, to w in the given format
+func writeSampleData(w io.Writer, format, symbol string, bars []MinuteBar, limit int) error {
+	count := limit
+	if count > len(bars) {
+		count = len(bars)
+	}
+	var tail []MinuteBar
+	if len(bars) > limit {
+		start := len(bars) - 5
+		if start < count {
+			start = count
+		}
+		tail = bars[start:]
+	}
+
+	if format == OutputFormatTable {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(w, "%s: %d records\n", symbol, len(bars))
+		fmt.Fprintf(tw, "TIME\tOPEN\tHIGH\tLOW\tCLOSE\tVOLUME\t\n")
+		for _, bar := range bars[:count] {
+			writeBarRow(tw, bar)
+		}
+		if tail != nil {
+			fmt.Fprintf(tw, "...\t\t\t\t\t\t\n")
+			for _, bar := range tail {
+				writeBarRow(tw, bar)
+			}
+		}
+		return tw.Flush()
+	}
+
+	l := log.New(w, "", log.LstdFlags)
+	l.Printf("\n=== Sample Data for %s ===", symbol)
+	l.Printf("Total records: %d", len(bars))
+	l.Printf("\nFirst %d records:", count)
+	for _, bar := range bars[:count] {
+		l.Printf("%s | O:%.2f H:%.2f L:%.2f C:%.2f V:%d",
+			bar.Timestamp.Format(cliTimeLayout),
+			bar.Open, bar.High, bar.Low, bar.Close, bar.Volume)
+	}
+	if tail != nil {
+		l.Printf("...")
+		l.Printf("Last 5 records:")
+		for _, bar := range tail {
+			l.Printf("%s | O:%.2f H:%.2f L:%.2f C:%.2f V:%d",
+				bar.Timestamp.Format(cliTimeLayout),
+				bar.Open, bar.High, bar.Low, bar.Close, bar.Volume)
+		}
+	}
+	return nil
+}
+
+func writeBarRow(w io.Writer, bar MinuteBar) {
+	fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%d\t\n",
+		bar.Timestamp.Format(cliTimeLayout), bar.Open, bar.High, bar.Low, bar.Close, bar.Volume)
+}
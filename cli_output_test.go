@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// cliBars returns n bars from 2024-03-05 14:30 UTC, one a minute, closing at
+// 100, 101, ... with volume 10, 20, ...
+func cliBars(n int) []MinuteBar {
+	start := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+	bars := make([]MinuteBar, n)
+	for i := range bars {
+		price := 100 + float64(i)
+		bars[i] = MinuteBar{
+			Symbol:    "AAPL",
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Open:      price, High: price + 1, Low: price - 1, Close: price,
+			Volume:   int64(10 * (i + 1)),
+			Currency: "USD",
+		}
+	}
+	return bars
+}
+
+func TestWriteAnalysis(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		bars   []MinuteBar
+		want   []string // lines the output must contain
+		absent []string
+	}{
+		{
+			name:   "table",
+			format: OutputFormatTable,
+			bars:   cliBars(3),
+			want: []string{
+				"METRIC             VALUE\n",
+				"Data points        3\n",
+				"Date range         2024-03-05 14:30:00 to 2024-03-05 14:32:00\n",
+				"Price range        $100.00 - $102.00\n",
+				"Price change       $2.00 (2.00%)\n",
+				"Total volume       60\n",
+				"Avg volume/minute  20\n",
+				"Highest volume     2024-03-05 14:32:00 (30 @ $102.00)\n",
+				"Lowest price       2024-03-05 14:30:00 ($100.00)\n",
+			},
+		},
+		{
+			name:   "table without notable points for one bar",
+			format: OutputFormatTable,
+			bars:   cliBars(1),
+			want:   []string{"Data points        1\n"},
+			absent: []string{"Highest volume", "Lowest price"},
+		},
+		{
+			name:   "log",
+			format: OutputFormatLog,
+			bars:   cliBars(3),
+			want: []string{
+				"=== Basic Analysis ===",
+				"Data Points: 3",
+				"Price Range: $100.00 - $102.00",
+				"Price Change: $2.00 (2.00%)",
+				"Highest Volume Day: 2024-03-05 14:32:00 (Volume: 30, Price: $102.00)",
+				"Average Volume per Minute: 20",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := writeAnalysis(&out, tt.format, analyzeBars(tt.bars)); err != nil {
+				t.Fatalf("writeAnalysis: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output lacks %q:\n%s", want, out.String())
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(out.String(), absent) {
+					t.Errorf("output has %q:\n%s", absent, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWriteSampleData(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		bars     int
+		limit    int
+		wantRows int // bar rows printed
+		wantGap  bool
+	}{
+		{name: "table, all bars", format: OutputFormatTable, bars: 3, limit: 10, wantRows: 3},
+		{name: "table, head and tail", format: OutputFormatTable, bars: 20, limit: 3, wantRows: 8, wantGap: true},
+		{name: "table, tail overlapping the head", format: OutputFormatTable, bars: 6, limit: 3, wantRows: 6, wantGap: true},
+		{name: "log, head and tail", format: OutputFormatLog, bars: 20, limit: 3, wantRows: 8, wantGap: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := writeSampleData(&out, tt.format, "AAPL", cliBars(tt.bars), tt.limit); err != nil {
+				t.Fatalf("writeSampleData: %v", err)
+			}
+
+			rows := strings.Count(out.String(), "2024-03-05 14:")
+			if rows != tt.wantRows {
+				t.Errorf("printed %d bar rows, want %d:\n%s", rows, tt.wantRows, out.String())
+			}
+			if gap := strings.Contains(out.String(), "..."); gap != tt.wantGap {
+				t.Errorf("gap marker = %v, want %v", gap, tt.wantGap)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// ComparePoint is one rebased value in a comparison series
+type ComparePoint struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+// Rebase scales closes so the first value equals base, making series with
+// different price levels comparable. A non-positive first close yields zeros.
+func Rebase(closes []float64, base float64) []float64 {
+	result := make([]float64, len(closes))
+	if len(closes) == 0 || closes[0] <= 0 {
+		return result
+	}
+
+	for i, close := range closes {
+		result[i] = close / closes[0] * base
+	}
+	return result
+}
+
+// pairedReturns computes the simple returns of two close series aligned on
+// the same dates, keeping a period only when both have positive closes at
+// either end of it, so the results stay pairwise comparable
+func pairedReturns(a, b []float64) ([]float64, []float64) {
+	var returnsA, returnsB []float64
+	for i := 1; i < len(a) && i < len(b); i++ {
+		if a[i-1] > 0 && a[i] > 0 && b[i-1] > 0 && b[i] > 0 {
+			returnsA = append(returnsA, a[i]/a[i-1]-1)
+			returnsB = append(returnsB, b[i]/b[i-1]-1)
+		}
+	}
+	return returnsA, returnsB
+}
+
+// alignDailyCloses keeps only the dates every symbol has a summary for and
+// returns those dates (oldest first) with each symbol's closes on them
+func alignDailyCloses(summaries map[string][]DailySummaryAPI) ([]time.Time, map[string][]float64) {
+	counts := make(map[time.Time]int)
+	closesByDate := make(map[string]map[time.Time]float64)
+	for symbol, days := range summaries {
+		closesByDate[symbol] = make(map[time.Time]float64)
+		for _, day := range days {
+			closesByDate[symbol][day.Date] = day.Close
+			counts[day.Date]++
+		}
+	}
+
+	var dates []time.Time
+	for date, count := range counts {
+		if count == len(summaries) {
+			dates = append(dates, date)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+
+	closes := make(map[string][]float64)
+	for symbol, byDate := range closesByDate {
+		series := make([]float64, len(dates))
+		for i, date := range dates {
+			series[i] = byDate[date]
+		}
+		closes[symbol] = series
+	}
+
+	return dates, closes
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRebase(t *testing.T) {
+	tests := []struct {
+		name   string
+		closes []float64
+		base   float64
+		want   []float64
+	}{
+		{name: "rebased to 100", closes: []float64{50, 55, 45}, base: 100, want: []float64{100, 110, 90}},
+		{name: "rebased to 1", closes: []float64{200, 300}, base: 1, want: []float64{1, 1.5}},
+		{name: "non-positive first close", closes: []float64{0, 10}, base: 100, want: []float64{0, 0}},
+		{name: "empty", closes: nil, base: 100, want: []float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Rebase(tt.closes, tt.base)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Rebase(%v, %v) = %v, want %v", tt.closes, tt.base, got, tt.want)
+			}
+			for i := range tt.want {
+				if !approxEqual(got[i], tt.want[i]) {
+					t.Errorf("Rebase(%v, %v) = %v, want %v", tt.closes, tt.base, got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAlignDailyCloses(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2024, 3, n, 0, 0, 0, 0, time.UTC) }
+	summary := func(n int, close float64) DailySummaryAPI { return DailySummaryAPI{Date: day(n), Close: close} }
+
+	tests := []struct {
+		name       string
+		summaries  map[string][]DailySummaryAPI
+		wantDates  []time.Time
+		wantCloses map[string][]float64
+	}{
+		{
+			name: "only shared dates are kept, oldest first",
+			summaries: map[string][]DailySummaryAPI{
+				"AAPL": {summary(6, 12), summary(4, 10), summary(5, 11)},
+				"MSFT": {summary(4, 20), summary(6, 22)},
+			},
+			wantDates:  []time.Time{day(4), day(6)},
+			wantCloses: map[string][]float64{"AAPL": {10, 12}, "MSFT": {20, 22}},
+		},
+		{
+			name: "no shared dates",
+			summaries: map[string][]DailySummaryAPI{
+				"AAPL": {summary(4, 10)},
+				"MSFT": {summary(5, 20)},
+			},
+			wantDates:  nil,
+			wantCloses: map[string][]float64{"AAPL": {}, "MSFT": {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dates, closes := alignDailyCloses(tt.summaries)
+			if !reflect.DeepEqual(dates, tt.wantDates) {
+				t.Errorf("dates = %v, want %v", dates, tt.wantDates)
+			}
+			if !reflect.DeepEqual(closes, tt.wantCloses) {
+				t.Errorf("closes = %v, want %v", closes, tt.wantCloses)
+			}
+			// Every series lines up with the dates
+			for symbol, series := range closes {
+				if len(series) != len(dates) {
+					t.Errorf("%s has %d closes for %d dates", symbol, len(series), len(dates))
+				}
+			}
+		})
+	}
+}
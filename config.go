@@ -0,0 +1,155 @@
+package main
+
+import "time"
+
+// Config holds runtime settings, populated from command line flags
+type Config struct {
+	DBPath          string
+	Port            string
+	EnableScheduler bool
+
+	// StaticDir serves the web UI from disk; empty uses the embedded copy
+	StaticDir string
+
+	// RetentionDays is how many days of minute data to keep; 0 keeps everything.
+	// Daily summaries are never pruned.
+	RetentionDays int
+
+	// RequestTimeout bounds the sync and data endpoints; 0 disables the deadline
+	RequestTimeout time.Duration
+
+	// StaleAfter flags a watched stock as stale when it hasn't synced for this long
+	StaleAfter time.Duration
+
+	// EnableEvents turns on the dividend/earnings calendar endpoint and its weekly
+	// refresh. Off by default because it calls Yahoo's quoteSummary endpoint.
+	EnableEvents bool
+
+	// UserAgents are rotated per Yahoo request; empty uses the built-in default
+	UserAgents []string
+
+	// LogFile, when set, receives the logs as well as (with LogConsole) the
+	// console, rotated once it reaches LogMaxSizeMB; LogMaxBackups old files
+	// are kept for up to LogMaxAgeDays
+	LogFile       string
+	LogConsole    bool
+	LogMaxSizeMB  int
+	LogMaxBackups int
+	LogMaxAgeDays int
+
+	// DefaultWatchlist is added to the watchlist on startup when it has no
+	// entries at all, so a fresh database has something to collect
+	DefaultWatchlist []string
+
+	// ResolveAliases maps renamed tickers and common company names (FB,
+	// Facebook) to current tickers in the sync and add endpoints, and
+	// SymbolAliases adds to or overrides those aliases
+	ResolveAliases bool
+	SymbolAliases  map[string]string
+
+	// SearchMinLength is the shortest /api/search query, in characters, that
+	// is matched; shorter ones get an empty result
+	SearchMinLength int
+
+	// YahooTimeout bounds each Yahoo request attempt, and YahooRetries is how
+	// many times a timed-out or failed attempt is retried before the next host
+	YahooTimeout time.Duration
+	YahooRetries int
+
+	// ProxyURL routes Yahoo requests through an HTTP proxy; empty disables it
+	ProxyURL string
+
+	// YahooHosts are tried in order when a host fails or rate-limits; empty uses
+	// query1 then query2
+	YahooHosts []string
+
+	// CacheBackend selects where API responses are cached: memory or redis
+	CacheBackend string
+
+	// RedisAddr is the host:port of Redis when CacheBackend is redis
+	RedisAddr string
+
+	// CacheTTL is how long summary, data and search responses are cached; 0 disables
+	CacheTTL time.Duration
+
+	// SpotCacheTTL is how long a live spot price is reused, independently of
+	// CacheTTL; 0 disables it
+	SpotCacheTTL time.Duration
+
+	// SummaryCacheSize is how many daily summary ranges the database keeps in
+	// its LRU; 0 disables it
+	SummaryCacheSize int
+
+	// GRPCPort serves the gRPC API alongside the web server; empty disables it
+	GRPCPort string
+
+	// GzipMinLength is the smallest /api response body that gets gzip-compressed
+	// for clients that accept it; 0 disables compression
+	GzipMinLength int
+
+	// InitialDays is how many days the first collection of a symbol fetches;
+	// later syncs are incremental. Capped at maxInitialDays.
+	InitialDays int
+
+	// ExtendedHours keeps zero-volume pre/post-market bars when collecting
+	ExtendedHours bool
+
+	// SummarySession picks the bars daily summaries are built from: regular,
+	// all, or auto (regular with ExtendedHours, otherwise all)
+	SummarySession string
+
+	// IntradayInterval additionally refreshes watched stocks this often while
+	// the US market is open; 0 disables intraday refreshes
+	IntradayInterval time.Duration
+
+	// SettleSchedule is a cron spec, in China time like the daily update, for
+	// re-fetching the last closed trading day of every watched stock so
+	// summaries pick up Yahoo's late corrections; empty disables the job
+	SettleSchedule string
+
+	// RefreshStaleAfter makes a summary request during market hours whose
+	// latest bar is older than this start a background sync of the symbol and
+	// answer with stale set; 0 disables the check
+	RefreshStaleAfter time.Duration
+
+	// BatchDays is how many days each Yahoo minute-data request covers (1-8),
+	// BatchDelay the minimum gap between request starts of a multi-batch
+	// fetch, and BatchConcurrency how many of its requests run at once
+	BatchDays        int
+	BatchDelay       time.Duration
+	BatchConcurrency int
+
+	// AtomicCollect stores each collection's minute bars and summary updates
+	// in one transaction, rolling the bars back if the summaries fail;
+	// otherwise a summary failure is only logged
+	AtomicCollect bool
+
+	// SpikeFactor enables the cross-bar spike filter when above 1: minute
+	// bars whose close is more than SpikeFactor times off the median of the
+	// SpikeWindow bars around them are dropped
+	SpikeFactor float64
+	SpikeWindow int
+
+	// TradingDaysPerYear annualizes daily statistics such as volatility:
+	// 252 for US equities, 365 for crypto, 250 for some other exchanges
+	TradingDaysPerYear int
+
+	// FilterMode is how aggressively implausible minute bars are dropped:
+	// strict (default), lenient or off
+	FilterMode string
+
+	// ConflictStrategy is how re-fetched minute bars that are already stored
+	// are handled: replace (default) overwrites them, ignore keeps the first
+	ConflictStrategy string
+
+	// QuarantineRejected records the bars FilterMode and the spike filter
+	// drop, with the reason, in the rejected_bars table
+	QuarantineRejected bool
+
+	// ReadTimeout, WriteTimeout and IdleTimeout bound the web server's
+	// connections; 0 disables each. WriteTimeout must outlast a non-zero
+	// RequestTimeout; streaming and maintenance endpoints clear it per request.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
@@ -0,0 +1,33 @@
+package main
+
+// defaultCurrency is assumed for data collected before currencies were tracked
+const defaultCurrency = "USD"
+
+// currencySymbols maps ISO 4217 codes to display symbols for common quote currencies
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CNY": "¥",
+	"HKD": "HK$",
+	"CAD": "C$",
+	"AUD": "A$",
+	"CHF": "CHF ",
+	"KRW": "₩",
+	"INR": "₹",
+	"TWD": "NT$",
+	"SGD": "S$",
+}
+
+// currencySymbol returns the display prefix for a currency code,
+// falling back to the code itself for currencies without a known symbol
+func currencySymbol(code string) string {
+	if code == "" {
+		code = defaultCurrency
+	}
+	if symbol, ok := currencySymbols[code]; ok {
+		return symbol
+	}
+	return code + " "
+}
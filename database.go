@@ -1,17 +1,47 @@
 package main
 
 import (
+	"context"
+	"database/sql/driver"
 	"fmt"
 	"math"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
 type Database struct {
-	db *gorm.DB
+	db   *gorm.DB
+	path string
+
+	// summaryCache holds recent GetDailySummary results; nil disables it
+	summaryCache *summaryLRU
+
+	// regularSessionSummaries excludes pre/post-market bars from daily summaries
+	regularSessionSummaries bool
+
+	// conflictStrategy is how InsertMinuteData treats bars already stored
+	conflictStrategy string
+
+	// observers are told when a symbol's stored data changes
+	observers *dataObservers
+}
+
+// DataChangedFunc is called with a symbol after its minute data or summaries
+// change, so caches built from them can be dropped
+type DataChangedFunc func(symbol string)
+
+// dataObservers holds the OnDataChanged callbacks, shared with transaction
+// copies of the Database
+type dataObservers struct {
+	mu  sync.RWMutex
+	fns []DataChangedFunc
 }
 
 func NewDatabase(dbPath string) (*Database, error) {
@@ -22,89 +52,248 @@ func NewDatabase(dbPath string) (*Database, error) {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
-	// Auto migrate tables
-	if err := db.AutoMigrate(allModels...); err != nil {
-		return nil, fmt.Errorf("failed to auto migrate: %v", err)
+	// Apply versioned schema migrations
+	if err := runMigrations(db); err != nil {
+		return nil, err
 	}
 
-	database := &Database{db: db}
+	return &Database{db: db, path: dbPath, observers: &dataObservers{}}, nil
+}
+
+// OnDataChanged registers fn to be called after InsertMinuteData, the summary
+// updates, RenameSymbol or ImportAll change a symbol's data. Callbacks run
+// synchronously on the writing goroutine, so they should be quick.
+func (d *Database) OnDataChanged(fn DataChangedFunc) {
+	d.observers.mu.Lock()
+	defer d.observers.mu.Unlock()
+	d.observers.fns = append(d.observers.fns, fn)
+}
+
+// notifyDataChanged drops symbol from the summary LRU and tells the observers
+func (d *Database) notifyDataChanged(symbol string) {
+	d.summaryCache.invalidate(symbol)
 
-	// Create additional indexes that are not covered by GORM tags
-	if err := database.createAdditionalIndexes(); err != nil {
-		return nil, fmt.Errorf("failed to create additional indexes: %v", err)
+	d.observers.mu.RLock()
+	fns := d.observers.fns
+	d.observers.mu.RUnlock()
+	for _, fn := range fns {
+		fn(symbol)
 	}
+}
+
+// Minute-bar conflict strategies: how InsertMinuteData treats a bar whose
+// symbol and timestamp are already stored. ConflictReplace overwrites it, so
+// Yahoo's revisions land; ConflictIgnore keeps the bar first stored.
+const (
+	ConflictReplace = "replace"
+	ConflictIgnore  = "ignore"
+)
 
-	return database, nil
+// isValidConflictStrategy reports whether strategy is a supported conflict strategy
+func isValidConflictStrategy(strategy string) bool {
+	return strategy == ConflictReplace || strategy == ConflictIgnore
 }
 
-// createAdditionalIndexes creates indexes that are not easily covered by GORM tags
-func (d *Database) createAdditionalIndexes() error {
-	// Create composite unique index for (symbol, timestamp) in stock_minute_data
-	if err := d.db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_symbol_timestamp_unique ON stock_minute_data(symbol, timestamp)").Error; err != nil {
-		return fmt.Errorf("failed to create unique index: %v", err)
-	}
+// SetConflictStrategy sets how InsertMinuteData treats bars already stored,
+// ConflictReplace (the default) or ConflictIgnore
+func (d *Database) SetConflictStrategy(strategy string) {
+	d.conflictStrategy = strategy
+}
 
-	return nil
+// SetRegularSessionSummaries makes UpdateDailySummary build each day's OHLC
+// and volume from regular-session bars only, ignoring pre/post-market bars
+func (d *Database) SetRegularSessionSummaries(enabled bool) {
+	d.regularSessionSummaries = enabled
 }
 
-// Helper function to round float to specific decimal places
+// SetSummaryCacheSize enables an LRU of up to size GetDailySummary results,
+// or disables it when size is not positive
+func (d *Database) SetSummaryCacheSize(size int) {
+	d.summaryCache = newSummaryLRU(size)
+}
+
+// Helper function to round float to specific decimal places. NaN and Inf
+// become 0, since encoding/json refuses to marshal them.
 func roundToDecimal(value float64, places int) float64 {
+	if !isFinite(value) {
+		return 0
+	}
 	factor := math.Pow10(places)
 	return math.Round(value*factor) / factor
 }
 
-func (d *Database) InsertMinuteData(bars []MinuteBar) error {
+func (d *Database) InsertMinuteData(ctx context.Context, bars []MinuteBar) error {
 	if len(bars) == 0 {
 		return nil
 	}
 
+	symbols := make([]string, 0, 1)
+	seen := make(map[string]bool)
+	for _, bar := range bars {
+		if !seen[bar.Symbol] {
+			symbols = append(symbols, bar.Symbol)
+			seen[bar.Symbol] = true
+		}
+	}
+	precisions, err := d.pricePrecisions(ctx, symbols)
+	if err != nil {
+		return err
+	}
+
 		// Convert MinuteBar to StockMinuteData models
 	var stockData []StockMinuteData
 	for _, bar := range bars {
+		precision := precisionFor(precisions, bar.Symbol)
 		stockData = append(stockData, StockMinuteData{
 			Symbol:    bar.Symbol,
 			Timestamp: bar.Timestamp,
-			Open:      roundToDecimal(bar.Open, 2),
-			High:      roundToDecimal(bar.High, 2),
-			Low:       roundToDecimal(bar.Low, 2),
-			Close:     roundToDecimal(bar.Close, 2),
+			Open:      roundPrice(bar.Open, precision),
+			High:      roundPrice(bar.High, precision),
+			Low:       roundPrice(bar.Low, precision),
+			Close:     roundPrice(bar.Close, precision),
 			Volume:    bar.Volume,
+			Currency:  bar.Currency,
+			Session:   sessionOrRegular(bar.Session),
 		})
 	}
 
-	// Use transaction for batch insert
-	return d.db.Transaction(func(tx *gorm.DB) error {
+	// Upsert on (symbol, timestamp) so re-fetched bars replace existing ones,
+	// or with ConflictIgnore leave them as they are
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}, {Name: "timestamp"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "currency", "session", "updated_at"}),
+	}
+	if d.conflictStrategy == ConflictIgnore {
+		onConflict = clause.OnConflict{
+			Columns:   []clause.Column{{Name: "symbol"}, {Name: "timestamp"}},
+			DoNothing: true,
+		}
+	}
+
+	err = d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Process in batches to avoid memory issues with large datasets
-		batchSize := 1000
-		for i := 0; i < len(stockData); i += batchSize {
-			end := i + batchSize
-			if end > len(stockData) {
-				end = len(stockData)
-			}
+		result := tx.Clauses(onConflict).CreateInBatches(stockData, 1000)
 
-			batch := stockData[i:end]
+		if result.Error != nil {
+			return fmt.Errorf("failed to upsert minute data: %v", result.Error)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-			for _, data := range batch {
-				// Use raw SQL with INSERT OR REPLACE to handle conflicts properly
-				result := tx.Exec(`
-					INSERT OR REPLACE INTO stock_minute_data
-					(symbol, timestamp, open, high, low, close, volume, created_at, updated_at)
-					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-					data.Symbol, data.Timestamp, data.Open, data.High, data.Low, data.Close, data.Volume,
-					time.Now(), time.Now())
+	for _, symbol := range symbols {
+		d.notifyDataChanged(symbol)
+	}
+	return nil
+}
 
-				if result.Error != nil {
-					return fmt.Errorf("failed to insert bar %s %s: %v", data.Symbol, data.Timestamp, result.Error)
-				}
-			}
+// InsertWithSummary inserts bars and updates symbol's daily, weekly and
+// monthly summaries in one transaction, so a failed summary update rolls the
+// inserted bars back too
+func (d *Database) InsertWithSummary(ctx context.Context, symbol string, bars []MinuteBar) error {
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txDB := d.withTx(tx)
+		if err := txDB.InsertMinuteData(ctx, bars); err != nil {
+			return err
 		}
-		return nil
+		summaryBars, err := txDB.summaryBars(ctx, symbol, bars)
+		if err != nil {
+			return err
+		}
+		return txDB.UpdateDailySummary(ctx, symbol, summaryBars)
 	})
+
+	// Readers may have cached pre-commit summaries while the transaction ran
+	d.notifyDataChanged(symbol)
+	return err
 }
 
-func (d *Database) GetMinuteData(symbol string, startTime, endTime time.Time) ([]MinuteBar, error) {
+// summaryBars returns the bars to update symbol's summaries from once bars
+// have been inserted. With ConflictIgnore the stored bars can differ from the
+// fetched ones, so the whole New York days bars cover are read back;
+// otherwise bars are what was stored.
+func (d *Database) summaryBars(ctx context.Context, symbol string, bars []MinuteBar) ([]MinuteBar, error) {
+	if d.conflictStrategy != ConflictIgnore || len(bars) == 0 {
+		return bars, nil
+	}
+
+	first, last := bars[0].Timestamp, bars[0].Timestamp
+	for _, bar := range bars {
+		if bar.Timestamp.Before(first) {
+			first = bar.Timestamp
+		}
+		if bar.Timestamp.After(last) {
+			last = bar.Timestamp
+		}
+	}
+
+	year, month, day := first.In(marketLocation).Date()
+	start := time.Date(year, month, day, 0, 0, 0, 0, marketLocation)
+	year, month, day = last.In(marketLocation).Date()
+	end := time.Date(year, month, day+1, 0, 0, 0, 0, marketLocation)
+	return d.GetMinuteData(ctx, symbol, start, end.Add(-time.Nanosecond))
+}
+
+// withTx returns a copy of d whose queries run in tx
+func (d *Database) withTx(tx *gorm.DB) *Database {
+	txDB := *d
+	txDB.db = tx
+	return &txDB
+}
+
+// pricePrecisions returns the stored price precision of each watched symbol
+// in symbols; symbols that aren't watched are left out
+func (d *Database) pricePrecisions(ctx context.Context, symbols []string) (map[string]int, error) {
+	var stocks []WatchedStock
+	result := d.db.WithContext(ctx).Select("symbol", "precision").
+		Where("symbol IN ?", symbols).
+		Find(&stocks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query price precision: %v", result.Error)
+	}
+
+	precisions := make(map[string]int, len(stocks))
+	for _, stock := range stocks {
+		precisions[stock.Symbol] = stock.Precision
+	}
+	return precisions, nil
+}
+
+// pricePrecision returns the stored price precision for one symbol
+func (d *Database) pricePrecision(ctx context.Context, symbol string) (int, error) {
+	precisions, err := d.pricePrecisions(ctx, []string{symbol})
+	if err != nil {
+		return 0, err
+	}
+	return precisionFor(precisions, symbol), nil
+}
+
+// precisionFor looks up symbol in precisions, falling back to the default
+func precisionFor(precisions map[string]int, symbol string) int {
+	if precision, ok := precisions[symbol]; ok {
+		return precision
+	}
+	return defaultPricePrecision
+}
+
+// SetPricePrecision sets the decimal places a watched stock's prices are
+// stored with. Existing rows keep their stored precision.
+func (d *Database) SetPricePrecision(ctx context.Context, symbol string, precision int) error {
+	result := d.db.WithContext(ctx).Model(&WatchedStock{}).
+		Where("symbol = ?", symbol).
+		Update("precision", precision)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update price precision: %v", result.Error)
+	}
+	return nil
+}
+
+func (d *Database) GetMinuteData(ctx context.Context, symbol string, startTime, endTime time.Time) ([]MinuteBar, error) {
 	var stockData []StockMinuteData
-	result := d.db.Where("symbol = ? AND timestamp BETWEEN ? AND ?", symbol, startTime, endTime).
+	// Timestamps are stored as UTC text, so bounds must be UTC to compare
+	result := d.db.WithContext(ctx).Where("symbol = ? AND timestamp BETWEEN ? AND ?", symbol, startTime.UTC(), endTime.UTC()).
 		Order("timestamp ASC").
 		Find(&stockData)
 
@@ -115,23 +304,44 @@ func (d *Database) GetMinuteData(symbol string, startTime, endTime time.Time) ([
 	// Convert StockMinuteData to MinuteBar for compatibility
 	var bars []MinuteBar
 	for _, data := range stockData {
-		bars = append(bars, MinuteBar{
-			Symbol:    data.Symbol,
-			Timestamp: data.Timestamp,
-			Open:      data.Open,
-			High:      data.High,
-			Low:       data.Low,
-			Close:     data.Close,
-			Volume:    data.Volume,
-		})
+		bars = append(bars, data.MinuteBar())
 	}
 
 	return bars, nil
 }
 
-func (d *Database) GetLatestTimestamp(symbol string) (time.Time, error) {
+// StreamMinuteData calls fn with each of symbol's minute bars between
+// startTime and endTime, oldest first, reading them from a database cursor
+// so the range never has to fit in memory. An error from fn stops the
+// iteration and is returned as is.
+func (d *Database) StreamMinuteData(ctx context.Context, symbol string, startTime, endTime time.Time, fn func(MinuteBar) error) error {
+	rows, err := d.db.WithContext(ctx).Model(&StockMinuteData{}).
+		Where("symbol = ? AND timestamp BETWEEN ? AND ?", symbol, startTime.UTC(), endTime.UTC()).
+		Order("timestamp ASC").
+		Rows()
+	if err != nil {
+		return fmt.Errorf("failed to query data: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data StockMinuteData
+		if err := d.db.ScanRows(rows, &data); err != nil {
+			return fmt.Errorf("failed to read data: %v", err)
+		}
+		if err := fn(data.MinuteBar()); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read data: %v", err)
+	}
+	return nil
+}
+
+func (d *Database) GetLatestTimestamp(ctx context.Context, symbol string) (time.Time, error) {
 	var stockData StockMinuteData
-	result := d.db.Where("symbol = ?", symbol).
+	result := d.db.WithContext(ctx).Where("symbol = ?", symbol).
 		Order("timestamp DESC").
 		First(&stockData)
 
@@ -145,10 +355,34 @@ func (d *Database) GetLatestTimestamp(symbol string) (time.Time, error) {
 	return stockData.Timestamp, nil
 }
 
-func (d *Database) GetDataStats(symbol string) (int, time.Time, time.Time, error) {
+// DaysToFetch returns how many days a sync of symbol should fetch at now:
+// initialDays when nothing is stored yet, otherwise the days since the latest
+// bar, counting that bar's day so a partially collected day is fetched again
+func (d *Database) DaysToFetch(ctx context.Context, symbol string, initialDays int, now time.Time) (int, error) {
+	latestTimestamp, err := d.GetLatestTimestamp(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+	if latestTimestamp.IsZero() {
+		return initialDays, nil
+	}
+	return incrementalDays(latestTimestamp, now), nil
+}
+
+// incrementalDays is the whole days from latest to now plus one, never less
+// than 1, so data from earlier today still re-fetches today
+func incrementalDays(latest, now time.Time) int {
+	days := int(now.Sub(latest).Hours()/24) + 1
+	if days < 1 {
+		return 1
+	}
+	return days
+}
+
+func (d *Database) GetDataStats(ctx context.Context, symbol string) (int, time.Time, time.Time, error) {
 	// Get count first
 	var count int64
-	err := d.db.Model(&StockMinuteData{}).
+	err := d.db.WithContext(ctx).Model(&StockMinuteData{}).
 		Where("symbol = ?", symbol).
 		Count(&count).Error
 	if err != nil {
@@ -162,14 +396,14 @@ func (d *Database) GetDataStats(symbol string) (int, time.Time, time.Time, error
 	// Get earliest and latest timestamps using First/Last
 	var earliest, latest StockMinuteData
 
-	err = d.db.Where("symbol = ?", symbol).
+	err = d.db.WithContext(ctx).Where("symbol = ?", symbol).
 		Order("timestamp ASC").
 		First(&earliest).Error
 	if err != nil {
 		return 0, time.Time{}, time.Time{}, fmt.Errorf("failed to get earliest timestamp: %v", err)
 	}
 
-	err = d.db.Where("symbol = ?", symbol).
+	err = d.db.WithContext(ctx).Where("symbol = ?", symbol).
 		Order("timestamp DESC").
 		First(&latest).Error
 	if err != nil {
@@ -179,6 +413,125 @@ func (d *Database) GetDataStats(symbol string) (int, time.Time, time.Time, error
 	return int(count), earliest.Timestamp, latest.Timestamp, nil
 }
 
+// symbolTables lists every table with per-symbol rows, with the columns that
+// together with symbol identify a row
+var symbolTables = []struct {
+	table string
+	keys  []string
+}{
+	{"stock_minute_data", []string{"timestamp"}},
+	{"stock_daily_summary", []string{"date"}},
+	{"stock_weekly_summary", []string{"date"}},
+	{"stock_monthly_summary", []string{"date"}},
+	{"corporate_events", []string{"type", "date"}},
+	{"rejected_bars", []string{"timestamp"}},
+	{"watched_stocks", []string{"tenant"}},
+}
+
+// RenameSymbol moves every row of oldSymbol to newSymbol, e.g. after a ticker
+// change. Where both symbols have a row for the same key (timestamp, date,
+// or the watchlist entry itself) the more recently updated row is kept.
+// Weekly and monthly summaries are then rebuilt from the merged daily ones,
+// and days with minute bars from the merged bars.
+func (d *Database) RenameSymbol(ctx context.Context, oldSymbol, newSymbol string) error {
+	if oldSymbol == newSymbol {
+		return fmt.Errorf("cannot rename %s to itself", oldSymbol)
+	}
+
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, t := range symbolTables {
+			match := "other.symbol = ?"
+			for _, key := range t.keys {
+				match += fmt.Sprintf(" AND other.%s = %s.%s", key, t.table, key)
+			}
+
+			// Drop newSymbol rows superseded by a newer oldSymbol row, then
+			// oldSymbol rows that still collide, then move the rest
+			err := tx.Exec(fmt.Sprintf(`DELETE FROM %[1]s WHERE symbol = ? AND EXISTS
+				(SELECT 1 FROM %[1]s other WHERE %[2]s AND other.updated_at > %[1]s.updated_at)`, t.table, match),
+				newSymbol, oldSymbol).Error
+			if err != nil {
+				return fmt.Errorf("failed to merge %s: %v", t.table, err)
+			}
+			err = tx.Exec(fmt.Sprintf(`DELETE FROM %[1]s WHERE symbol = ? AND EXISTS
+				(SELECT 1 FROM %[1]s other WHERE %[2]s)`, t.table, match),
+				oldSymbol, newSymbol).Error
+			if err != nil {
+				return fmt.Errorf("failed to merge %s: %v", t.table, err)
+			}
+			if err := tx.Exec(fmt.Sprintf("UPDATE %s SET symbol = ? WHERE symbol = ?", t.table), newSymbol, oldSymbol).Error; err != nil {
+				return fmt.Errorf("failed to rename %s in %s: %v", oldSymbol, t.table, err)
+			}
+		}
+
+		var dates []time.Time
+		if err := tx.Model(&StockDailySummary{}).Where("symbol = ?", newSymbol).Pluck("date", &dates).Error; err != nil {
+			return fmt.Errorf("failed to load daily summaries: %v", err)
+		}
+		if err := updatePeriodSummaries(tx, weeklyPeriod, newSymbol, dates); err != nil {
+			return err
+		}
+		return updatePeriodSummaries(tx, monthlyPeriod, newSymbol, dates)
+	})
+
+	d.notifyDataChanged(oldSymbol)
+	d.notifyDataChanged(newSymbol)
+	if err != nil {
+		return err
+	}
+	return d.RebuildDailySummaries(ctx, newSymbol)
+}
+
+// PruneMinuteData deletes minute bars older than olderThan and returns the number
+// of rows deleted. An empty symbol prunes all symbols. Daily summaries are derived
+// from minute data but stored separately, so they are left untouched.
+func (d *Database) PruneMinuteData(ctx context.Context, symbol string, olderThan time.Time) (int64, error) {
+	query := d.db.WithContext(ctx).Where("timestamp < ?", olderThan.UTC())
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+
+	result := query.Delete(&StockMinuteData{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to prune minute data: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// FileSize returns the size of the database file on disk in bytes
+func (d *Database) FileSize() (int64, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat database file: %v", err)
+	}
+	return info.Size(), nil
+}
+
+// Vacuum rebuilds the database file to reclaim dead pages and refreshes query
+// planner statistics. VACUUM cannot run inside a transaction, so these are
+// issued directly on the connection rather than through db.Transaction.
+func (d *Database) Vacuum(ctx context.Context) error {
+	if err := d.db.WithContext(ctx).Exec("VACUUM").Error; err != nil {
+		return fmt.Errorf("failed to vacuum database: %v", err)
+	}
+	if err := d.db.WithContext(ctx).Exec("PRAGMA optimize").Error; err != nil {
+		return fmt.Errorf("failed to optimize database: %v", err)
+	}
+	return nil
+}
+
+// Ping verifies the database connection is alive
+func (d *Database) Ping(ctx context.Context) error {
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get underlying sql.DB: %v", err)
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("database ping failed: %v", err)
+	}
+	return nil
+}
+
 func (d *Database) Close() error {
 	sqlDB, err := d.db.DB()
 	if err != nil {
@@ -188,32 +541,238 @@ func (d *Database) Close() error {
 }
 
 // Watched Stocks operations
-func (d *Database) AddWatchedStock(symbol, name string) error {
+
+// AddWatchedStock adds symbol to ctx's tenant's watchlist, reporting whether it
+// was newly created (false means it was already being watched)
+func (d *Database) AddWatchedStock(ctx context.Context, symbol, name string) (bool, error) {
+	return addWatchedStock(d.db.WithContext(ctx), TenantFromContext(ctx), symbol, name)
+}
+
+// AddWatchedStocks adds several stocks in a single transaction. The returned
+// slice reports, per input stock, whether it was newly created (false means it
+// was already being watched).
+func (d *Database) AddWatchedStocks(ctx context.Context, stocks []WatchedStock) ([]bool, error) {
+	created := make([]bool, len(stocks))
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, stock := range stocks {
+			isNew, err := addWatchedStock(tx, TenantFromContext(ctx), stock.Symbol, stock.Name)
+			if err != nil {
+				return err
+			}
+			created[i] = isNew
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// SeedWatchlist adds stocks to ctx's tenant's watchlist only if it is empty,
+// and reports whether it did. Inactive entries count as existing, so a
+// watchlist the user has emptied by deactivating isn't refilled.
+func (d *Database) SeedWatchlist(ctx context.Context, stocks []WatchedStock) (bool, error) {
+	seeded := false
+	tenant := TenantFromContext(ctx)
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&WatchedStock{}).Where("tenant = ?", tenant).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count watched stocks: %v", err)
+		}
+		if count > 0 {
+			return nil
+		}
+
+		for _, stock := range stocks {
+			if _, err := addWatchedStock(tx, tenant, stock.Symbol, stock.Name); err != nil {
+				return err
+			}
+		}
+		seeded = len(stocks) > 0
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return seeded, nil
+}
+
+// addWatchedStock creates tenant's watchlist entry if it doesn't exist and reports whether it did
+func addWatchedStock(tx *gorm.DB, tenant, symbol, name string) (bool, error) {
 	stock := WatchedStock{
+		Tenant:   tenant,
 		Symbol:   symbol,
 		Name:     name,
 		IsActive: true,
 	}
 
-	result := d.db.Where("symbol = ?", symbol).FirstOrCreate(&stock)
+	result := tx.Where("tenant = ? AND symbol = ?", tenant, symbol).FirstOrCreate(&stock)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to add watched stock: %v", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// Watchlist filters by whether stocks are active (collected) or paused
+const (
+	WatchlistActive   = "active"
+	WatchlistInactive = "inactive"
+	WatchlistAll      = "all"
+)
+
+// watchlistStatusScope restricts watched_stocks rows to those matching status
+func watchlistStatusScope(status string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch status {
+		case WatchlistAll:
+			return db
+		case WatchlistInactive:
+			return db.Where("watched_stocks.is_active = ?", false)
+		default:
+			return db.Where("watched_stocks.is_active = ?", true)
+		}
+	}
+}
+
+// WatchedStockStats is a watched stock with aggregate facts about its minute data
+type WatchedStockStats struct {
+	WatchedStock
+	RecordCount         int64
+	LatestDataTimestamp *time.Time
+}
+
+// GetWatchedStocksWithStats returns ctx's tenant's watched stocks matching
+// status (WatchlistActive, WatchlistInactive or WatchlistAll) with their
+// minute-data record count and latest bar timestamp, using one aggregate query
+func (d *Database) GetWatchedStocksWithStats(ctx context.Context, status string) ([]WatchedStockStats, error) {
+	stats := d.db.Model(&StockMinuteData{}).
+		Select("symbol, COUNT(*) AS record_count, MAX(timestamp) AS latest_data_timestamp").
+		Group("symbol")
+
+	// Aggregates lose the column's datetime type, so scan the timestamp via nullTime
+	var rows []struct {
+		WatchedStock        `gorm:"embedded"`
+		RecordCount         int64
+		LatestDataTimestamp nullTime
+	}
+	result := d.db.WithContext(ctx).Model(&WatchedStock{}).
+		Select("watched_stocks.*, COALESCE(stats.record_count, 0) AS record_count, stats.latest_data_timestamp").
+		Joins("LEFT JOIN (?) AS stats ON stats.symbol = watched_stocks.symbol", stats).
+		Scopes(tenantScope(ctx), watchlistStatusScope(status)).
+		Order("watched_stocks.sort_order ASC, watched_stocks.added_at DESC").
+		Scan(&rows)
 	if result.Error != nil {
-		return fmt.Errorf("failed to add watched stock: %v", result.Error)
+		return nil, fmt.Errorf("failed to query watched stocks with stats: %v", result.Error)
+	}
+
+	stocks := make([]WatchedStockStats, 0, len(rows))
+	for _, row := range rows {
+		stock := WatchedStockStats{
+			WatchedStock: row.WatchedStock,
+			RecordCount:  row.RecordCount,
+		}
+		if row.LatestDataTimestamp.Valid {
+			latest := row.LatestDataTimestamp.Time
+			stock.LatestDataTimestamp = &latest
+		}
+		stocks = append(stocks, stock)
+	}
+
+	return stocks, nil
+}
+
+// nullTime scans a nullable timestamp that may arrive as time.Time or, from
+// SQLite aggregates such as MAX(timestamp), as its stored text form
+type nullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Text layouts SQLite drivers use when storing time.Time values
+var sqliteTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func (n *nullTime) Scan(value interface{}) error {
+	n.Time, n.Valid = time.Time{}, false
+
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		n.Time, n.Valid = v, true
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into timestamp", value)
+	}
+
+	for _, layout := range sqliteTimeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			n.Time, n.Valid = t, true
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse timestamp %q", text)
+}
+
+func (n nullTime) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
 	}
+	return n.Time, nil
+}
 
+// UpdateWatchedStockMeta stores the exchange and currency for a watched stock,
+// and fills in its name if it was added without one, in every tenant's watchlist
+func (d *Database) UpdateWatchedStockMeta(ctx context.Context, symbol string, meta QuoteMeta) error {
+	updates := map[string]interface{}{
+		"exchange": meta.Exchange,
+	}
+	if meta.Currency != "" {
+		updates["currency"] = meta.Currency
+	}
+
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&WatchedStock{}).Where("symbol = ?", symbol).Updates(updates).Error; err != nil {
+			return err
+		}
+		if meta.Name == "" {
+			return nil
+		}
+		return tx.Model(&WatchedStock{}).
+			Where("symbol = ? AND (name = '' OR name IS NULL)", symbol).
+			Update("name", meta.Name).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update watched stock meta: %v", err)
+	}
 	return nil
 }
 
-func (d *Database) RemoveWatchedStock(symbol string) error {
-	result := d.db.Where("symbol = ?", symbol).Delete(&WatchedStock{})
+func (d *Database) RemoveWatchedStock(ctx context.Context, symbol string) error {
+	result := d.db.WithContext(ctx).Scopes(tenantScope(ctx)).Where("symbol = ?", symbol).Delete(&WatchedStock{})
 	if result.Error != nil {
 		return fmt.Errorf("failed to remove watched stock: %v", result.Error)
 	}
 	return nil
 }
 
-func (d *Database) GetWatchedStocks() ([]WatchedStock, error) {
+// GetWatchedStocks returns ctx's tenant's watched stocks matching status
+// (WatchlistActive, WatchlistInactive or WatchlistAll) in watchlist order
+func (d *Database) GetWatchedStocks(ctx context.Context, status string) ([]WatchedStock, error) {
 	var stocks []WatchedStock
-	result := d.db.Where("is_active = ?", true).Order("added_at DESC").Find(&stocks)
+	result := d.db.WithContext(ctx).Scopes(tenantScope(ctx), watchlistStatusScope(status)).Order("sort_order ASC, added_at DESC").Find(&stocks)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to query watched stocks: %v", result.Error)
 	}
@@ -221,8 +780,119 @@ func (d *Database) GetWatchedStocks() ([]WatchedStock, error) {
 	return stocks, nil
 }
 
-func (d *Database) UpdateLastSync(symbol string) error {
-	result := d.db.Model(&WatchedStock{}).
+// GetCollectedStocks returns the active watched stocks of every tenant, one
+// entry per symbol, for the scheduler to keep up to date
+func (d *Database) GetCollectedStocks(ctx context.Context) ([]WatchedStock, error) {
+	var stocks []WatchedStock
+	result := d.db.WithContext(ctx).Where("is_active = ?", true).Order("symbol ASC, id ASC").Find(&stocks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query watched stocks: %v", result.Error)
+	}
+
+	collected := make([]WatchedStock, 0, len(stocks))
+	for _, stock := range stocks {
+		if len(collected) == 0 || collected[len(collected)-1].Symbol != stock.Symbol {
+			collected = append(collected, stock)
+		}
+	}
+	return collected, nil
+}
+
+// GetWatchedStock looks up one watchlist entry, active or not. It returns nil
+// without an error when the symbol isn't in the watchlist.
+func (d *Database) GetWatchedStock(ctx context.Context, symbol string) (*WatchedStock, error) {
+	var stock WatchedStock
+	result := d.db.WithContext(ctx).Scopes(tenantScope(ctx)).Where("symbol = ?", symbol).Limit(1).Find(&stock)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query watched stock: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &stock, nil
+}
+
+// SearchWatchedStocks returns watchlist entries, active or not, whose symbol
+// or name contains query (case-insensitive), symbol matches first
+func (d *Database) SearchWatchedStocks(ctx context.Context, query string, limit int) ([]WatchedStock, error) {
+	pattern := "%" + strings.ToLower(query) + "%"
+
+	var stocks []WatchedStock
+	result := d.db.WithContext(ctx).Scopes(tenantScope(ctx)).
+		Where("LOWER(symbol) LIKE ? OR LOWER(name) LIKE ?", pattern, pattern).
+		Order(clause.Expr{SQL: "CASE WHEN LOWER(symbol) LIKE ? THEN 0 ELSE 1 END, symbol", Vars: []interface{}{pattern}}).
+		Limit(limit).
+		Find(&stocks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to search watched stocks: %v", result.Error)
+	}
+	return stocks, nil
+}
+
+// IsWatched reports whether symbol is an active watched stock
+func (d *Database) IsWatched(ctx context.Context, symbol string) (bool, error) {
+	var count int64
+	result := d.db.WithContext(ctx).Model(&WatchedStock{}).Scopes(tenantScope(ctx)).
+		Where("symbol = ? AND is_active = ?", symbol, true).
+		Count(&count)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to query watched stock: %v", result.Error)
+	}
+	return count > 0, nil
+}
+
+// SetWatchedStockActive pauses or resumes collection for a watched stock.
+// Returns gorm.ErrRecordNotFound if the symbol isn't in the watchlist.
+func (d *Database) SetWatchedStockActive(ctx context.Context, symbol string, active bool) error {
+	result := d.db.WithContext(ctx).Model(&WatchedStock{}).Scopes(tenantScope(ctx)).
+		Where("symbol = ?", symbol).
+		Update("is_active", active)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update watched stock: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetWatchedStockName renames a watched stock. Returns gorm.ErrRecordNotFound
+// if the symbol isn't in the watchlist.
+func (d *Database) SetWatchedStockName(ctx context.Context, symbol, name string) error {
+	result := d.db.WithContext(ctx).Model(&WatchedStock{}).Scopes(tenantScope(ctx)).
+		Where("symbol = ?", symbol).
+		Update("name", name)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update watched stock: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetWatchedStockOrder persists display order: each symbol's sort_order becomes
+// its position in symbols (starting at 1). Symbols not listed keep their order.
+func (d *Database) SetWatchedStockOrder(ctx context.Context, symbols []string) error {
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, symbol := range symbols {
+			result := tx.Model(&WatchedStock{}).Scopes(tenantScope(ctx)).
+				Where("symbol = ?", symbol).
+				Update("sort_order", i+1)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update watched stock order: %v", err)
+	}
+	return nil
+}
+
+func (d *Database) UpdateLastSync(ctx context.Context, symbol string) error {
+	result := d.db.WithContext(ctx).Model(&WatchedStock{}).
 		Where("symbol = ?", symbol).
 		Update("last_sync", time.Now())
 	if result.Error != nil {
@@ -232,11 +902,19 @@ func (d *Database) UpdateLastSync(symbol string) error {
 }
 
 // Daily Summary operations
-func (d *Database) UpdateDailySummary(symbol string, bars []MinuteBar) error {
+func (d *Database) UpdateDailySummary(ctx context.Context, symbol string, bars []MinuteBar) error {
+	if d.regularSessionSummaries {
+		bars = regularSessionOnly(bars)
+	}
 	if len(bars) == 0 {
 		return nil
 	}
 
+	precision, err := d.pricePrecision(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
 	// Load US Eastern timezone for proper stock market date grouping
 	etLocation, err := time.LoadLocation("America/New_York")
 	if err != nil {
@@ -249,9 +927,8 @@ func (d *Database) UpdateDailySummary(symbol string, bars []MinuteBar) error {
 		// Convert to Eastern time for proper date grouping
 		etTime := bar.Timestamp.In(etLocation)
 
-		// Skip weekends (Saturday=6, Sunday=0) based on Eastern time
-		weekday := etTime.Weekday()
-		if weekday == time.Saturday || weekday == time.Sunday {
+		// Skip weekends and NYSE holidays based on Eastern time
+		if !isTradingDate(etTime) {
 			continue
 		}
 
@@ -282,6 +959,8 @@ func (d *Database) UpdateDailySummary(symbol string, bars []MinuteBar) error {
 
 		var high, low float64
 		var volume int64
+		var turnover float64 // sum of typical price * volume, for VWAP
+		barCount := 0
 
 		// Find proper open (first trade) and close (last trade)
 		open := firstBar.Open
@@ -296,6 +975,10 @@ func (d *Database) UpdateDailySummary(symbol string, bars []MinuteBar) error {
 				low = bar.Low
 			}
 			volume += bar.Volume
+			turnover += (bar.High + bar.Low + bar.Close) / 3 * float64(bar.Volume)
+			if sessionOrRegular(bar.Session) == SessionRegular {
+				barCount++
+			}
 		}
 
 		// Parse the date string for the summary date (use first bar's date, but set to start of day in UTC)
@@ -307,59 +990,144 @@ func (d *Database) UpdateDailySummary(symbol string, bars []MinuteBar) error {
 		summary := StockDailySummary{
 			Symbol: symbol,
 			Date:   parsedDate,
-			Open:   roundToDecimal(open, 2),
-			High:   roundToDecimal(high, 2),
-			Low:    roundToDecimal(low, 2),
-			Close:  roundToDecimal(close, 2),
+			Open:   roundPrice(open, precision),
+			High:   roundPrice(high, precision),
+			Low:    roundPrice(low, precision),
+			Close:  roundPrice(close, precision),
 			Volume: volume,
+
+			BarCount: barCount,
 		}
+		summary.VWAP = roundPrice(safeDiv(turnover, float64(volume)), precision)
 		summaries[date] = summary
 	}
 
-	// Insert daily summaries using GORM transaction
-	return d.db.Transaction(func(tx *gorm.DB) error {
-		for _, summary := range summaries {
-			// Use FirstOrCreate to handle INSERT OR REPLACE logic
-			result := tx.Where("symbol = ? AND date = ?", summary.Symbol, summary.Date).
-				FirstOrCreate(&summary, StockDailySummary{
-					Symbol: summary.Symbol,
-					Date:   summary.Date,
-					Open:   summary.Open,
-					High:   summary.High,
-					Low:    summary.Low,
-					Close:  summary.Close,
-					Volume: summary.Volume,
-				})
+	if len(summaries) == 0 {
+		return nil
+	}
 
-			if result.Error != nil {
-				return fmt.Errorf("failed to insert daily summary for %s: %v", summary.Date.Format("2006-01-02"), result.Error)
-			}
+	rows := make([]StockDailySummary, 0, len(summaries))
+	for _, summary := range summaries {
+		rows = append(rows, summary)
+	}
 
-			// If record already exists, update it
-			if result.RowsAffected == 0 {
-				updateResult := tx.Model(&StockDailySummary{}).
-					Where("symbol = ? AND date = ?", summary.Symbol, summary.Date).
-					Updates(map[string]interface{}{
-						"open":   summary.Open,
-						"high":   summary.High,
-						"low":    summary.Low,
-						"close":  summary.Close,
-						"volume": summary.Volume,
-					})
-				if updateResult.Error != nil {
-					return fmt.Errorf("failed to update daily summary for %s: %v", summary.Date.Format("2006-01-02"), updateResult.Error)
-				}
-			}
+	return d.upsertDailySummaries(ctx, symbol, rows)
+}
+
+// rebuildWindowDays is how many New York calendar days of minute bars
+// RebuildDailySummaries loads at a time
+const rebuildWindowDays = 30
+
+// RebuildDailySummaries regenerates the daily (and so weekly and monthly)
+// summaries of every day that has stored minute bars, e.g. after a fix to the
+// summary logic. Days without minute bars, such as ones from the full daily
+// history, are left alone. Safe to run repeatedly.
+func (d *Database) RebuildDailySummaries(ctx context.Context, symbol string) error {
+	count, earliest, latest, err := d.GetDataStats(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	// Windows start at New York midnight so no trading day is split across
+	// two windows, which would overwrite it with a partial summary
+	year, month, day := earliest.In(marketLocation).Date()
+	start := time.Date(year, month, day, 0, 0, 0, 0, marketLocation)
+	for !start.After(latest) {
+		end := start.AddDate(0, 0, rebuildWindowDays)
+		bars, err := d.GetMinuteData(ctx, symbol, start, end.Add(-time.Nanosecond))
+		if err != nil {
+			return err
+		}
+		if err := d.UpdateDailySummary(ctx, symbol, bars); err != nil {
+			return err
 		}
+		start = end
+	}
+	return nil
+}
+
+// StoreDailyBars writes daily bars, such as Yahoo's full daily history,
+// straight into the daily summaries keyed by their US Eastern trading date,
+// then rolls them up into weeks and months
+func (d *Database) StoreDailyBars(ctx context.Context, symbol string, bars []MinuteBar) error {
+	precision, err := d.pricePrecision(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
+	etLocation, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return fmt.Errorf("failed to load Eastern timezone: %v", err)
+	}
+
+	rows := make([]StockDailySummary, 0, len(bars))
+	for _, bar := range bars {
+		etTime := bar.Timestamp.In(etLocation)
+		rows = append(rows, StockDailySummary{
+			Symbol: symbol,
+			Date:   time.Date(etTime.Year(), etTime.Month(), etTime.Day(), 0, 0, 0, 0, time.UTC),
+			Open:   roundPrice(bar.Open, precision),
+			High:   roundPrice(bar.High, precision),
+			Low:    roundPrice(bar.Low, precision),
+			Close:  roundPrice(bar.Close, precision),
+			Volume: bar.Volume,
+		})
+	}
+
+	if len(rows) == 0 {
 		return nil
-	})
+	}
+	return d.upsertDailySummaries(ctx, symbol, rows)
 }
 
-func (d *Database) GetDailySummary(symbol string, days int) ([]DailySummaryAPI, error) {
+// upsertDailySummaries stores rows and refreshes the weekly and monthly
+// summaries for the days they touch
+func (d *Database) upsertDailySummaries(ctx context.Context, symbol string, rows []StockDailySummary) error {
+	// Upsert on (symbol, date) so concurrent updates can't race; batched to
+	// stay under SQLite's bound-variable limit for multi-year histories
+	result := d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "bar_count", "vwap", "updated_at"}),
+	}).CreateInBatches(&rows, 1000)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to upsert daily summary for %s: %v", symbol, result.Error)
+	}
+	d.notifyDataChanged(symbol)
+
+	// Roll the touched days up into their weeks and months
+	dates := make([]time.Time, 0, len(rows))
+	for _, row := range rows {
+		dates = append(dates, row.Date)
+	}
+	if err := d.UpdateWeeklySummary(ctx, symbol, dates); err != nil {
+		return err
+	}
+	return d.UpdateMonthlySummary(ctx, symbol, dates)
+}
+
+// UpdateWeeklySummary recomputes the weekly summaries for the weeks containing dates
+func (d *Database) UpdateWeeklySummary(ctx context.Context, symbol string, dates []time.Time) error {
+	return updatePeriodSummaries(d.db.WithContext(ctx), weeklyPeriod, symbol, dates)
+}
+
+// UpdateMonthlySummary recomputes the monthly summaries for the months containing dates
+func (d *Database) UpdateMonthlySummary(ctx context.Context, symbol string, dates []time.Time) error {
+	return updatePeriodSummaries(d.db.WithContext(ctx), monthlyPeriod, symbol, dates)
+}
+
+func (d *Database) GetDailySummary(ctx context.Context, symbol string, days int) ([]DailySummaryAPI, error) {
+	if summaries, ok := d.summaryCache.get(symbol, days); ok {
+		return summaries, nil
+	}
+
 	var stockSummaries []StockDailySummary
 	// Calculate the date threshold
 	thresholdDate := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
-	result := d.db.Where("symbol = ? AND date >= ?", symbol, thresholdDate).
+	result := d.db.WithContext(ctx).Where("symbol = ? AND date >= ?", symbol, thresholdDate).
 		Order("date DESC").
 		Find(&stockSummaries)
 
@@ -370,25 +1138,169 @@ func (d *Database) GetDailySummary(symbol string, days int) ([]DailySummaryAPI,
 	// Convert StockDailySummary to DailySummaryAPI for compatibility
 	var summaries []DailySummaryAPI
 	for _, stockSummary := range stockSummaries {
-		summaries = append(summaries, DailySummaryAPI{
-			ID:       int(stockSummary.ID),
-			Symbol:   stockSummary.Symbol,
-			Date:     stockSummary.Date,
-			Open:     stockSummary.Open,
-			High:     stockSummary.High,
-			Low:      stockSummary.Low,
-			Close:    stockSummary.Close,
-			Volume:   stockSummary.Volume,
-			CreateAt: stockSummary.CreatedAt,
-		})
+		summaries = append(summaries, toDailySummaryAPI(stockSummary))
+	}
+
+	d.summaryCache.put(symbol, days, summaries)
+	return summaries, nil
+}
+
+// GetDailySummaryMulti returns the last N days of daily summaries for each of
+// symbols, newest first, from a single query. Every symbol has an entry, empty
+// when nothing is stored for it.
+func (d *Database) GetDailySummaryMulti(ctx context.Context, symbols []string, days int) (map[string][]DailySummaryAPI, error) {
+	summaries := make(map[string][]DailySummaryAPI, len(symbols))
+	for _, symbol := range symbols {
+		summaries[symbol] = []DailySummaryAPI{}
+	}
+	if len(symbols) == 0 {
+		return summaries, nil
+	}
+
+	var stockSummaries []StockDailySummary
+	thresholdDate := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	result := d.db.WithContext(ctx).Where("symbol IN ? AND date >= ?", symbols, thresholdDate).
+		Order("symbol, date DESC").
+		Find(&stockSummaries)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query daily summaries: %v", result.Error)
+	}
+
+	for _, stockSummary := range stockSummaries {
+		summaries[stockSummary.Symbol] = append(summaries[stockSummary.Symbol], toDailySummaryAPI(stockSummary))
+	}
+	return summaries, nil
+}
+
+// GetWeeklySummary returns weekly summaries covering the last N days, newest first
+func (d *Database) GetWeeklySummary(ctx context.Context, symbol string, days int) ([]DailySummaryAPI, error) {
+	return d.getPeriodSummary(ctx, weeklyPeriod, symbol, days)
+}
+
+// GetMonthlySummary returns monthly summaries covering the last N days, newest first
+func (d *Database) GetMonthlySummary(ctx context.Context, symbol string, days int) ([]DailySummaryAPI, error) {
+	return d.getPeriodSummary(ctx, monthlyPeriod, symbol, days)
+}
+
+func (d *Database) getPeriodSummary(ctx context.Context, period summaryPeriod, symbol string, days int) ([]DailySummaryAPI, error) {
+	// The weekly and monthly tables share the daily summary's columns
+	var stockSummaries []StockDailySummary
+	threshold := period.start(time.Now().UTC().AddDate(0, 0, -days))
+	result := d.db.WithContext(ctx).Table(period.table).
+		Where("symbol = ? AND date >= ?", symbol, threshold).
+		Order("date DESC").
+		Find(&stockSummaries)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query %s: %v", period.table, result.Error)
+	}
+
+	var summaries []DailySummaryAPI
+	for _, stockSummary := range stockSummaries {
+		summaries = append(summaries, toDailySummaryAPI(stockSummary))
 	}
 
 	return summaries, nil
 }
 
-func (d *Database) GetLatestPrice(symbol string) (float64, time.Time, error) {
+// toDailySummaryAPI converts a stored summary row to its API representation
+func toDailySummaryAPI(stockSummary StockDailySummary) DailySummaryAPI {
+	return DailySummaryAPI{
+		ID:       int(stockSummary.ID),
+		Symbol:   stockSummary.Symbol,
+		Date:     stockSummary.Date,
+		Open:     stockSummary.Open.Float64(),
+		High:     stockSummary.High.Float64(),
+		Low:      stockSummary.Low.Float64(),
+		Close:    stockSummary.Close.Float64(),
+		Volume:   stockSummary.Volume,
+		BarCount: stockSummary.BarCount,
+		VWAP:     stockSummary.VWAP.Float64(),
+
+		Completeness: completeness(stockSummary.BarCount, stockSummary.Date),
+		CreateAt:     stockSummary.CreatedAt,
+	}
+}
+
+// completeness returns the share of the date's expected regular-session bars
+// that are present, capped at 1, or nil when the bar count isn't known
+func completeness(barCount int, date time.Time) *float64 {
+	expected := expectedBars(date)
+	if barCount <= 0 || expected == 0 {
+		return nil
+	}
+	score := float64(barCount) / float64(expected)
+	if score > 1 {
+		score = 1
+	}
+	return &score
+}
+
+// SaveCorporateEvents upserts dividends and earnings dates on (symbol, type, date)
+func (d *Database) SaveCorporateEvents(ctx context.Context, events []CorporateEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	result := d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}, {Name: "type"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"amount", "updated_at"}),
+	}).Create(&events)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save corporate events: %v", result.Error)
+	}
+	return nil
+}
+
+// GetCorporateEvents returns a symbol's stored events, newest first. Symbols
+// without events yield an empty (non-nil) slice.
+func (d *Database) GetCorporateEvents(ctx context.Context, symbol string) ([]CorporateEvent, error) {
+	events := []CorporateEvent{}
+	result := d.db.WithContext(ctx).Where("symbol = ?", symbol).
+		Order("date DESC").
+		Find(&events)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query corporate events: %v", result.Error)
+	}
+	return events, nil
+}
+
+// SaveRejectedBars records bars the anomaly filters dropped. A bar rejected
+// again on a later fetch replaces its earlier record.
+func (d *Database) SaveRejectedBars(ctx context.Context, bars []RejectedBar) error {
+	if len(bars) == 0 {
+		return nil
+	}
+
+	result := d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}, {Name: "timestamp"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "reason", "updated_at"}),
+	}).CreateInBatches(&bars, 1000)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save rejected bars: %v", result.Error)
+	}
+	return nil
+}
+
+// GetRejectedBars returns up to limit of symbol's rejected bars timestamped
+// at or after since, newest first. Symbols without any yield an empty
+// (non-nil) slice.
+func (d *Database) GetRejectedBars(ctx context.Context, symbol string, since time.Time, limit int) ([]RejectedBar, error) {
+	bars := []RejectedBar{}
+	result := d.db.WithContext(ctx).Where("symbol = ? AND timestamp >= ?", symbol, since.UTC()).
+		Order("timestamp DESC").
+		Limit(limit).
+		Find(&bars)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query rejected bars: %v", result.Error)
+	}
+	return bars, nil
+}
+
+func (d *Database) GetLatestPrice(ctx context.Context, symbol string) (float64, time.Time, error) {
 	var stockData StockMinuteData
-	result := d.db.Where("symbol = ?", symbol).
+	result := d.db.WithContext(ctx).Where("symbol = ?", symbol).
 		Order("timestamp DESC").
 		First(&stockData)
 
@@ -399,5 +1311,94 @@ func (d *Database) GetLatestPrice(symbol string) (float64, time.Time, error) {
 		return 0, time.Time{}, fmt.Errorf("failed to query latest price: %v", result.Error)
 	}
 
-	return stockData.Close, stockData.Timestamp, nil
+	return stockData.Close.Float64(), stockData.Timestamp, nil
+}
+
+// GetLatestBar returns symbol's most recent minute bar. Returns
+// gorm.ErrRecordNotFound if there is no minute data for it.
+func (d *Database) GetLatestBar(ctx context.Context, symbol string) (MinuteBar, error) {
+	var data StockMinuteData
+	result := d.db.WithContext(ctx).Where("symbol = ?", symbol).
+		Order("timestamp DESC").
+		First(&data)
+
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return MinuteBar{}, gorm.ErrRecordNotFound
+		}
+		return MinuteBar{}, fmt.Errorf("failed to query latest bar: %v", result.Error)
+	}
+
+	return data.MinuteBar(), nil
+}
+
+// GetLatestPrices returns the latest price of each symbol with its change
+// against the previous daily close, computed the same way as the summary
+// endpoint. Symbols without minute data are left out.
+func (d *Database) GetLatestPrices(ctx context.Context, symbols []string) ([]LatestPrice, error) {
+	return d.GetPriceChanges(ctx, symbols, 1)
+}
+
+// GetPriceChanges returns the latest price of each symbol with its change
+// against the daily close days summary rows back, or the oldest stored close
+// when there are fewer. It uses one grouped query for the latest bars and one
+// for the recent daily closes, regardless of how many symbols are asked for.
+// Symbols without minute data are left out.
+func (d *Database) GetPriceChanges(ctx context.Context, symbols []string, days int) ([]LatestPrice, error) {
+	if len(symbols) == 0 {
+		return []LatestPrice{}, nil
+	}
+
+	latestTimes := d.db.Model(&StockMinuteData{}).
+		Select("symbol, MAX(timestamp) AS timestamp").
+		Where("symbol IN ?", symbols).
+		Group("symbol")
+
+	var bars []StockMinuteData
+	result := d.db.WithContext(ctx).Model(&StockMinuteData{}).
+		Select("stock_minute_data.symbol, stock_minute_data.timestamp, stock_minute_data.close").
+		Joins("JOIN (?) latest ON latest.symbol = stock_minute_data.symbol AND latest.timestamp = stock_minute_data.timestamp", latestTimes).
+		Find(&bars)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query latest prices: %v", result.Error)
+	}
+
+	var closes []struct {
+		Symbol string
+		Close  Price
+		Rank   int
+	}
+	result = d.db.WithContext(ctx).Raw(`SELECT symbol, close, rank FROM (
+		SELECT symbol, close, ROW_NUMBER() OVER (PARTITION BY symbol ORDER BY date DESC) AS rank
+		FROM stock_daily_summary WHERE symbol IN ?
+	) WHERE rank <= ?`, symbols, days+1).Scan(&closes)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query previous closes: %v", result.Error)
+	}
+
+	// Like the summary, compare against the close days before the latest day,
+	// falling back to the oldest close stored
+	previousCloses := make(map[string]float64)
+	previousRanks := make(map[string]int)
+	for _, row := range closes {
+		if row.Rank > previousRanks[row.Symbol] {
+			previousCloses[row.Symbol] = row.Close.Float64()
+			previousRanks[row.Symbol] = row.Rank
+		}
+	}
+
+	prices := make([]LatestPrice, 0, len(bars))
+	for _, bar := range bars {
+		price := LatestPrice{
+			Symbol:    bar.Symbol,
+			Price:     bar.Close.Float64(),
+			Timestamp: bar.Timestamp,
+		}
+		if previousClose, ok := previousCloses[bar.Symbol]; ok {
+			price.Change = price.Price - previousClose
+			price.ChangePercent = safeDiv(price.Change, previousClose) * 100
+		}
+		prices = append(prices, price)
+	}
+	return prices, nil
 }
\ No newline at end of file
@@ -0,0 +1,838 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestDatabase opens a migrated database in a temporary directory
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	database, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// testBar returns a regular-session bar of symbol at t closing at price
+func testBar(symbol string, t time.Time, price float64, volume int64) MinuteBar {
+	return MinuteBar{
+		Symbol:    symbol,
+		Timestamp: t,
+		Open:      price,
+		High:      price,
+		Low:       price,
+		Close:     price,
+		Volume:    volume,
+		Currency:  "USD",
+		Session:   SessionRegular,
+	}
+}
+
+func TestConcurrentUpsertKeepsOneRow(t *testing.T) {
+	// 2024-03-05 10:00 New York
+	at := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		write func(d *Database, price float64) error
+		count func(d *Database) (int64, error)
+	}{
+		{
+			name: "minute bar",
+			write: func(d *Database, price float64) error {
+				return d.InsertMinuteData(ctx, []MinuteBar{testBar("AAPL", at, price, 100)})
+			},
+			count: func(d *Database) (int64, error) {
+				var n int64
+				err := d.db.Model(&StockMinuteData{}).Where("symbol = ? AND timestamp = ?", "AAPL", at).Count(&n).Error
+				return n, err
+			},
+		},
+		{
+			name: "daily summary",
+			write: func(d *Database, price float64) error {
+				return d.UpdateDailySummary(ctx, "AAPL", []MinuteBar{testBar("AAPL", at, price, 100)})
+			},
+			count: func(d *Database) (int64, error) {
+				var n int64
+				err := d.db.Model(&StockDailySummary{}).Where("symbol = ?", "AAPL").Count(&n).Error
+				return n, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = tt.write(database, 100+float64(i))
+				}(i)
+			}
+			wg.Wait()
+
+			for i, err := range errs {
+				if err != nil {
+					t.Fatalf("writer %d: %v", i, err)
+				}
+			}
+			n, err := tt.count(database)
+			if err != nil {
+				t.Fatalf("count: %v", err)
+			}
+			if n != 1 {
+				t.Errorf("got %d rows, want 1", n)
+			}
+		})
+	}
+}
+
+func TestDaysToFetch(t *testing.T) {
+	now := time.Date(2024, 3, 8, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		stored      []time.Time // bar timestamps already stored
+		initialDays int
+		want        int
+	}{
+		{name: "first collection uses the initial window", stored: nil, initialDays: 30, want: 30},
+		{name: "first collection with a configured window", stored: nil, initialDays: 10, want: 10},
+		{name: "incremental ignores the initial window", stored: []time.Time{now.Add(-3 * time.Hour)}, initialDays: 30, want: 1},
+		{name: "incremental counts from the latest bar", stored: []time.Time{now.AddDate(0, 0, -20), now.AddDate(0, 0, -4)}, initialDays: 10, want: 5},
+		{name: "other symbols don't count", stored: nil, initialDays: 7, want: 7},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			if err := database.InsertMinuteData(ctx, []MinuteBar{testBar("MSFT", now.Add(-time.Hour), 100, 10)}); err != nil {
+				t.Fatalf("InsertMinuteData: %v", err)
+			}
+			for _, at := range tt.stored {
+				if err := database.InsertMinuteData(ctx, []MinuteBar{testBar("AAPL", at, 100, 10)}); err != nil {
+					t.Fatalf("InsertMinuteData: %v", err)
+				}
+			}
+
+			got, err := database.DaysToFetch(ctx, "AAPL", tt.initialDays, now)
+			if err != nil {
+				t.Fatalf("DaysToFetch: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DaysToFetch = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncrementalDays(t *testing.T) {
+	now := time.Date(2024, 3, 8, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		latest time.Time
+		want   int
+	}{
+		{name: "same day re-fetches today", latest: now.Add(-2 * time.Hour), want: 1},
+		{name: "just now", latest: now, want: 1},
+		{name: "latest bar in the future", latest: now.Add(time.Hour), want: 1},
+		{name: "one day old re-fetches that day", latest: now.Add(-24 * time.Hour), want: 2},
+		{name: "just under a day old", latest: now.Add(-23 * time.Hour), want: 1},
+		{name: "multi-day gap", latest: now.AddDate(0, 0, -5), want: 6},
+		{name: "multi-day gap with a partial day", latest: now.AddDate(0, 0, -5).Add(-12 * time.Hour), want: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := incrementalDays(tt.latest, now); got != tt.want {
+				t.Errorf("incrementalDays(%s) = %d, want %d", tt.latest.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPricePrecision(t *testing.T) {
+	at := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		watched   bool
+		precision int
+		price     float64
+		want      float64
+	}{
+		{name: "four decimals kept", watched: true, precision: 4, price: 1.2345, want: 1.2345},
+		{name: "three decimals", watched: true, precision: 3, price: 1.2345, want: 1.235},
+		{name: "default two decimals for a watched stock", watched: true, precision: defaultPricePrecision, price: 1.2345, want: 1.23},
+		{name: "default two decimals for an unwatched symbol", watched: false, price: 1.2345, want: 1.23},
+		{name: "whole units", watched: true, precision: 0, price: 1.2345, want: 1},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			if tt.watched {
+				if _, err := database.AddWatchedStock(ctx, "EURUSD", "Euro"); err != nil {
+					t.Fatalf("AddWatchedStock: %v", err)
+				}
+				if err := database.SetPricePrecision(ctx, "EURUSD", tt.precision); err != nil {
+					t.Fatalf("SetPricePrecision: %v", err)
+				}
+			}
+
+			bars := []MinuteBar{testBar("EURUSD", at, tt.price, 10)}
+			if err := database.InsertWithSummary(ctx, "EURUSD", bars); err != nil {
+				t.Fatalf("InsertWithSummary: %v", err)
+			}
+
+			stored, err := database.GetLatestBar(ctx, "EURUSD")
+			if err != nil {
+				t.Fatalf("GetLatestBar: %v", err)
+			}
+			if stored.Close != tt.want || stored.Open != tt.want {
+				t.Errorf("stored minute bar open %v close %v, want %v", stored.Open, stored.Close, tt.want)
+			}
+
+			var summary StockDailySummary
+			if err := database.db.Where("symbol = ?", "EURUSD").First(&summary).Error; err != nil {
+				t.Fatalf("query daily summary: %v", err)
+			}
+			if got := summary.Close.Float64(); got != tt.want {
+				t.Errorf("daily summary close %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDailySummarySessions(t *testing.T) {
+	pre := testBar("AAPL", newYork(2024, 3, 5, 8, 0), 90, 5)
+	pre.Session = SessionPre
+	regular := testBar("AAPL", newYork(2024, 3, 5, 10, 0), 100, 10)
+	post := testBar("AAPL", newYork(2024, 3, 5, 17, 0), 110, 20)
+	post.Session = SessionPost
+
+	tests := []struct {
+		name         string
+		regularOnly  bool
+		wantOpen     float64
+		wantClose    float64
+		wantVolume   int64
+		wantBarCount int
+	}{
+		{name: "regular session only", regularOnly: true, wantOpen: 100, wantClose: 100, wantVolume: 10, wantBarCount: 1},
+		{name: "all sessions", regularOnly: false, wantOpen: 90, wantClose: 110, wantVolume: 35, wantBarCount: 1},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			database.SetRegularSessionSummaries(tt.regularOnly)
+			if err := database.UpdateDailySummary(ctx, "AAPL", []MinuteBar{pre, regular, post}); err != nil {
+				t.Fatalf("UpdateDailySummary: %v", err)
+			}
+
+			var summary StockDailySummary
+			if err := database.db.Where("symbol = ?", "AAPL").First(&summary).Error; err != nil {
+				t.Fatalf("query daily summary: %v", err)
+			}
+			if summary.Open.Float64() != tt.wantOpen || summary.Close.Float64() != tt.wantClose ||
+				summary.Volume != tt.wantVolume || summary.BarCount != tt.wantBarCount {
+				t.Errorf("summary open %v close %v volume %d bars %d, want %v %v %d %d",
+					summary.Open.Float64(), summary.Close.Float64(), summary.Volume, summary.BarCount,
+					tt.wantOpen, tt.wantClose, tt.wantVolume, tt.wantBarCount)
+			}
+		})
+	}
+}
+
+func TestDailySummaryVWAP(t *testing.T) {
+	ohlcv := func(at time.Time, high, low, close float64, volume int64, session string) MinuteBar {
+		return MinuteBar{Symbol: "AAPL", Timestamp: at, Open: close, High: high, Low: low, Close: close, Volume: volume, Session: session}
+	}
+	open := newYork(2024, 3, 5, 9, 30)
+
+	tests := []struct {
+		name         string
+		bars         []MinuteBar
+		wantBarCount int
+	}{
+		{
+			name: "three regular bars",
+			bars: []MinuteBar{
+				ohlcv(open, 101, 99, 100, 100, SessionRegular),
+				ohlcv(open.Add(time.Minute), 103, 100, 102, 300, SessionRegular),
+				ohlcv(open.Add(2*time.Minute), 102, 98, 99, 600, SessionRegular),
+			},
+			wantBarCount: 3,
+		},
+		{
+			name: "extended bars weigh in but aren't counted",
+			bars: []MinuteBar{
+				ohlcv(open.Add(-time.Hour), 95, 94, 94.5, 50, SessionPre),
+				ohlcv(open, 101, 99, 100, 100, SessionRegular),
+				ohlcv(newYork(2024, 3, 5, 17, 0), 106, 104, 105, 25, SessionPost),
+			},
+			wantBarCount: 1,
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			if _, err := database.AddWatchedStock(ctx, "AAPL", "Apple Inc."); err != nil {
+				t.Fatalf("AddWatchedStock: %v", err)
+			}
+			if err := database.SetPricePrecision(ctx, "AAPL", 4); err != nil {
+				t.Fatalf("SetPricePrecision: %v", err)
+			}
+			if err := database.UpdateDailySummary(ctx, "AAPL", tt.bars); err != nil {
+				t.Fatalf("UpdateDailySummary: %v", err)
+			}
+
+			// VWAP by hand: typical price (H+L+C)/3 weighted by volume
+			var turnover float64
+			var volume int64
+			for _, bar := range tt.bars {
+				turnover += (bar.High + bar.Low + bar.Close) / 3 * float64(bar.Volume)
+				volume += bar.Volume
+			}
+			want := math.Round(turnover/float64(volume)*10000) / 10000
+
+			var summary StockDailySummary
+			if err := database.db.Where("symbol = ?", "AAPL").First(&summary).Error; err != nil {
+				t.Fatalf("query daily summary: %v", err)
+			}
+			if got := summary.VWAP.Float64(); got != want {
+				t.Errorf("VWAP = %v, want %v", got, want)
+			}
+			if summary.BarCount != tt.wantBarCount {
+				t.Errorf("bar count = %d, want %d", summary.BarCount, tt.wantBarCount)
+			}
+		})
+	}
+}
+
+func TestGetDailySummaryMulti(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	// Daily bars at New York noon so the stored date is the day they fall on
+	noon := func(daysAgo int) time.Time {
+		year, month, day := time.Now().In(marketLocation).AddDate(0, 0, -daysAgo).Date()
+		return time.Date(year, month, day, 12, 0, 0, 0, marketLocation)
+	}
+	stored := map[string][]int{"AAPL": {1, 3, 10}, "MSFT": {2, 40}}
+	for symbol, daysAgo := range stored {
+		var bars []MinuteBar
+		for _, n := range daysAgo {
+			bars = append(bars, testBar(symbol, noon(n), 100+float64(n), 10))
+		}
+		if err := database.StoreDailyBars(ctx, symbol, bars); err != nil {
+			t.Fatalf("StoreDailyBars(%s): %v", symbol, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		symbols []string
+		days    int
+		want    map[string][]int // days ago of the summaries returned, newest first
+	}{
+		{name: "a week", symbols: []string{"AAPL", "MSFT", "TSLA"}, days: 7, want: map[string][]int{"AAPL": {1, 3}, "MSFT": {2}, "TSLA": {}}},
+		{name: "a month", symbols: []string{"AAPL", "MSFT"}, days: 30, want: map[string][]int{"AAPL": {1, 3, 10}, "MSFT": {2}}},
+		{name: "two months", symbols: []string{"MSFT"}, days: 60, want: map[string][]int{"MSFT": {2, 40}}},
+		{name: "no symbols", symbols: nil, days: 30, want: map[string][]int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := database.GetDailySummaryMulti(ctx, tt.symbols, tt.days)
+			if err != nil {
+				t.Fatalf("GetDailySummaryMulti: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d symbols, want %d", len(got), len(tt.want))
+			}
+			for symbol, wantDays := range tt.want {
+				summaries, ok := got[symbol]
+				if !ok {
+					t.Errorf("%s missing", symbol)
+					continue
+				}
+				if len(summaries) != len(wantDays) {
+					t.Errorf("%s has %d summaries, want %d", symbol, len(summaries), len(wantDays))
+					continue
+				}
+				for i, n := range wantDays {
+					if summaries[i].Symbol != symbol || summaries[i].Close != 100+float64(n) {
+						t.Errorf("%s summary %d = %s close %v, want the one from %d days ago",
+							symbol, i, summaries[i].Symbol, summaries[i].Close, n)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSeedWatchlist(t *testing.T) {
+	seed := []WatchedStock{{Symbol: "TSLA", Name: "Tesla, Inc."}, {Symbol: "MSFT"}}
+
+	tests := []struct {
+		name        string
+		setup       func(ctx context.Context, d *Database) error
+		wantSeeded  bool
+		wantSymbols []string
+	}{
+		{
+			name:        "empty table is seeded",
+			wantSeeded:  true,
+			wantSymbols: []string{"MSFT", "TSLA"},
+		},
+		{
+			name: "existing stock is kept alone",
+			setup: func(ctx context.Context, d *Database) error {
+				_, err := d.AddWatchedStock(ctx, "AAPL", "Apple Inc.")
+				return err
+			},
+			wantSymbols: []string{"AAPL"},
+		},
+		{
+			name: "inactive stock counts as existing",
+			setup: func(ctx context.Context, d *Database) error {
+				if _, err := d.AddWatchedStock(ctx, "AAPL", "Apple Inc."); err != nil {
+					return err
+				}
+				return d.SetWatchedStockActive(ctx, "AAPL", false)
+			},
+			wantSymbols: []string{"AAPL"},
+		},
+		{
+			name: "another tenant's stocks don't count",
+			setup: func(ctx context.Context, d *Database) error {
+				_, err := d.AddWatchedStock(WithTenant(ctx, "other"), "AAPL", "Apple Inc.")
+				return err
+			},
+			wantSeeded:  true,
+			wantSymbols: []string{"MSFT", "TSLA"},
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			if tt.setup != nil {
+				if err := tt.setup(ctx, database); err != nil {
+					t.Fatalf("setup: %v", err)
+				}
+			}
+
+			seeded, err := database.SeedWatchlist(ctx, seed)
+			if err != nil {
+				t.Fatalf("SeedWatchlist: %v", err)
+			}
+			if seeded != tt.wantSeeded {
+				t.Errorf("seeded = %v, want %v", seeded, tt.wantSeeded)
+			}
+
+			stocks, err := database.GetWatchedStocks(ctx, WatchlistAll)
+			if err != nil {
+				t.Fatalf("GetWatchedStocks: %v", err)
+			}
+			var symbols []string
+			for _, stock := range stocks {
+				symbols = append(symbols, stock.Symbol)
+			}
+			sort.Strings(symbols)
+			if !reflect.DeepEqual(symbols, tt.wantSymbols) {
+				t.Errorf("watchlist = %v, want %v", symbols, tt.wantSymbols)
+			}
+
+			// Seeding is once only
+			if again, err := database.SeedWatchlist(ctx, seed); err != nil || again {
+				t.Errorf("second SeedWatchlist = %v, %v; want false", again, err)
+			}
+		})
+	}
+}
+
+func TestRenameSymbolConflicts(t *testing.T) {
+	// 2024-03-05 10:00-10:02 New York; FB has the first two minutes, META
+	// the last two, so 10:01 collides
+	start := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	minute := func(i int) time.Time { return start.Add(time.Duration(i) * time.Minute) }
+
+	tests := []struct {
+		name      string
+		newerSide string // which symbol's rows were updated last
+		wantClose []float64
+	}{
+		{name: "old symbol newer", newerSide: "FB", wantClose: []float64{100, 101, 202}},
+		{name: "new symbol newer", newerSide: "META", wantClose: []float64{100, 201, 202}},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			for _, symbol := range []string{"FB", "META"} {
+				if _, err := database.AddWatchedStock(ctx, symbol, symbol+" name"); err != nil {
+					t.Fatalf("AddWatchedStock: %v", err)
+				}
+			}
+			fb := []MinuteBar{testBar("FB", minute(0), 100, 10), testBar("FB", minute(1), 101, 10)}
+			meta := []MinuteBar{testBar("META", minute(1), 201, 10), testBar("META", minute(2), 202, 10)}
+			for _, bars := range [][]MinuteBar{fb, meta} {
+				if err := database.InsertWithSummary(ctx, bars[0].Symbol, bars); err != nil {
+					t.Fatalf("InsertWithSummary: %v", err)
+				}
+			}
+			for _, table := range []string{"stock_minute_data", "watched_stocks"} {
+				err := database.db.Exec("UPDATE "+table+" SET updated_at = ? WHERE symbol = ?", time.Now().Add(time.Hour), tt.newerSide).Error
+				if err != nil {
+					t.Fatalf("touch %s: %v", table, err)
+				}
+			}
+
+			if err := database.RenameSymbol(ctx, "FB", "META"); err != nil {
+				t.Fatalf("RenameSymbol: %v", err)
+			}
+
+			bars, err := database.GetMinuteData(ctx, "META", minute(0), minute(2))
+			if err != nil {
+				t.Fatalf("GetMinuteData: %v", err)
+			}
+			var closes []float64
+			for _, bar := range bars {
+				closes = append(closes, bar.Close)
+			}
+			if !reflect.DeepEqual(closes, tt.wantClose) {
+				t.Errorf("META closes = %v, want %v", closes, tt.wantClose)
+			}
+
+			counts := map[string]int64{}
+			for _, table := range []string{"stock_minute_data", "stock_daily_summary", "watched_stocks"} {
+				var n int64
+				if err := database.db.Table(table).Where("symbol = ?", "FB").Count(&n).Error; err != nil {
+					t.Fatalf("count %s: %v", table, err)
+				}
+				if n != 0 {
+					t.Errorf("%s has %d FB rows left", table, n)
+				}
+				if err := database.db.Table(table).Where("symbol = ?", "META").Count(&n).Error; err != nil {
+					t.Fatalf("count %s: %v", table, err)
+				}
+				counts[table] = n
+			}
+			want := map[string]int64{"stock_minute_data": 3, "stock_daily_summary": 1, "watched_stocks": 1}
+			if !reflect.DeepEqual(counts, want) {
+				t.Errorf("META rows = %v, want %v", counts, want)
+			}
+
+			// The merged day is summarized once, from the merged bars
+			summaries, err := database.GetDailySummaryMulti(ctx, []string{"META"}, 3650)
+			if err != nil {
+				t.Fatalf("GetDailySummaryMulti: %v", err)
+			}
+			if day := summaries["META"]; len(day) != 1 || day[0].Volume != 30 {
+				t.Errorf("META summaries = %+v, want one day with volume 30", day)
+			}
+		})
+	}
+}
+
+func TestRenameSymbolToItself(t *testing.T) {
+	if err := newTestDatabase(t).RenameSymbol(context.Background(), "META", "META"); err == nil {
+		t.Error("renaming a symbol to itself: want an error")
+	}
+}
+
+func TestOnDataChanged(t *testing.T) {
+	// 2024-03-05 10:00 New York
+	at := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		setup   func(d *Database) error
+		write   func(d *Database) error
+		wantErr bool
+		want    []string
+	}{
+		{
+			name: "minute data of two symbols",
+			write: func(d *Database) error {
+				return d.InsertMinuteData(ctx, []MinuteBar{testBar("AAPL", at, 100, 10), testBar("MSFT", at, 300, 10)})
+			},
+			want: []string{"AAPL", "MSFT"},
+		},
+		{
+			name: "daily summary",
+			write: func(d *Database) error {
+				return d.UpdateDailySummary(ctx, "AAPL", []MinuteBar{testBar("AAPL", at, 100, 10)})
+			},
+			want: []string{"AAPL"},
+		},
+		{
+			name: "bars with summary",
+			write: func(d *Database) error {
+				return d.InsertWithSummary(ctx, "AAPL", []MinuteBar{testBar("AAPL", at, 100, 10)})
+			},
+			want: []string{"AAPL"},
+		},
+		{
+			name: "daily history",
+			write: func(d *Database) error {
+				return d.StoreDailyBars(ctx, "AAPL", []MinuteBar{testBar("AAPL", at, 100, 10)})
+			},
+			want: []string{"AAPL"},
+		},
+		{
+			name:  "rename",
+			setup: func(d *Database) error { return d.InsertMinuteData(ctx, []MinuteBar{testBar("FB", at, 100, 10)}) },
+			write: func(d *Database) error { return d.RenameSymbol(ctx, "FB", "META") },
+			want:  []string{"FB", "META"},
+		},
+		{
+			name:    "failed insert",
+			setup:   func(d *Database) error { return d.db.Exec("DROP TABLE stock_minute_data").Error },
+			write:   func(d *Database) error { return d.InsertMinuteData(ctx, []MinuteBar{testBar("AAPL", at, 100, 10)}) },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			if tt.setup != nil {
+				if err := tt.setup(database); err != nil {
+					t.Fatalf("setup: %v", err)
+				}
+			}
+
+			// Every observer hears about every change
+			var mu sync.Mutex
+			notified := [2]map[string]bool{{}, {}}
+			for i := range notified {
+				database.OnDataChanged(func(symbol string) {
+					mu.Lock()
+					defer mu.Unlock()
+					notified[i][symbol] = true
+				})
+			}
+
+			err := tt.write(database)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("write error = %v, want error %v", err, tt.wantErr)
+			}
+			for i, symbols := range notified {
+				var got []string
+				for symbol := range symbols {
+					got = append(got, symbol)
+				}
+				sort.Strings(got)
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("observer %d notified of %v, want %v", i, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamMinuteData(t *testing.T) {
+	const total = 20000
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	minute := func(i int) time.Time { return start.Add(time.Duration(i) * time.Minute) }
+
+	database := newTestDatabase(t)
+	ctx := context.Background()
+	bars := make([]MinuteBar, 0, total+1)
+	for i := range total {
+		bars = append(bars, testBar("AAPL", minute(i), 100+float64(i%100)/100, int64(i)))
+	}
+	bars = append(bars, testBar("MSFT", minute(10), 300, 1))
+	if err := database.InsertMinuteData(ctx, bars); err != nil {
+		t.Fatalf("InsertMinuteData: %v", err)
+	}
+
+	errStop := errors.New("stop")
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		from, to  time.Time
+		stopAfter int // fn fails with errStop on this call; 0 never
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "whole range", ctx: ctx, from: minute(0), to: minute(total), wantCalls: total},
+		{name: "bounds inclusive", ctx: ctx, from: minute(100), to: minute(199), wantCalls: 100},
+		{name: "empty range", ctx: ctx, from: minute(total + 1), to: minute(total + 100), wantCalls: 0},
+		{name: "fn error stops early", ctx: ctx, from: minute(0), to: minute(total), stopAfter: 100, wantErr: true, wantCalls: 100},
+		{name: "cancelled context", ctx: cancelled, from: minute(0), to: minute(total), wantErr: true, wantCalls: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			var last time.Time
+			err := database.StreamMinuteData(tt.ctx, "AAPL", tt.from, tt.to, func(bar MinuteBar) error {
+				calls++
+				if bar.Symbol != "AAPL" {
+					t.Fatalf("streamed a %s bar", bar.Symbol)
+				}
+				if !bar.Timestamp.After(last) {
+					t.Fatalf("bar at %s after %s: not oldest first", bar.Timestamp, last)
+				}
+				last = bar.Timestamp
+				if calls == tt.stopAfter {
+					return errStop
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StreamMinuteData error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.stopAfter > 0 && err != errStop {
+				t.Errorf("fn's error came back as %v, want it as is", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("fn called %d times, want %d", calls, tt.wantCalls)
+			}
+
+			// The cursor is closed, so the database is free for writes
+			if err := database.InsertMinuteData(ctx, []MinuteBar{testBar("MSFT", minute(11), 300, 1)}); err != nil {
+				t.Errorf("insert after streaming: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetWatchedStocksStatus(t *testing.T) {
+	database := newTestDatabase(t)
+	ctx := context.Background()
+	for _, symbol := range []string{"AAPL", "MSFT", "TSLA"} {
+		if _, err := database.AddWatchedStock(ctx, symbol, ""); err != nil {
+			t.Fatalf("AddWatchedStock: %v", err)
+		}
+	}
+	if err := database.SetWatchedStockActive(ctx, "MSFT", false); err != nil {
+		t.Fatalf("SetWatchedStockActive: %v", err)
+	}
+
+	tests := []struct {
+		status string
+		want   []string
+	}{
+		{status: WatchlistActive, want: []string{"AAPL", "TSLA"}},
+		{status: WatchlistInactive, want: []string{"MSFT"}},
+		{status: WatchlistAll, want: []string{"AAPL", "MSFT", "TSLA"}},
+		{status: "", want: []string{"AAPL", "TSLA"}},
+	}
+
+	for _, tt := range tests {
+		stocks, err := database.GetWatchedStocks(ctx, tt.status)
+		if err != nil {
+			t.Fatalf("GetWatchedStocks(%q): %v", tt.status, err)
+		}
+		var got []string
+		for _, stock := range stocks {
+			got = append(got, stock.Symbol)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetWatchedStocks(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestConflictStrategy(t *testing.T) {
+	// 2024-03-05 10:00 and 10:01 New York
+	at := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	first := []MinuteBar{testBar("AAPL", at, 100, 10)}
+	// A revision of the stored bar and a new one
+	refetch := []MinuteBar{testBar("AAPL", at, 101, 20), testBar("AAPL", at.Add(time.Minute), 102, 5)}
+
+	tests := []struct {
+		name       string
+		strategy   string
+		wantClose  float64
+		wantVolume int64 // of the first bar
+	}{
+		{name: "default replaces", strategy: "", wantClose: 101, wantVolume: 20},
+		{name: "replace", strategy: ConflictReplace, wantClose: 101, wantVolume: 20},
+		{name: "ignore keeps the first", strategy: ConflictIgnore, wantClose: 100, wantVolume: 10},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, write := range []struct {
+				name       string
+				fn         func(d *Database, bars []MinuteBar) error
+				summarizes bool
+			}{
+				{"InsertMinuteData", func(d *Database, bars []MinuteBar) error { return d.InsertMinuteData(ctx, bars) }, false},
+				{"InsertWithSummary", func(d *Database, bars []MinuteBar) error { return d.InsertWithSummary(ctx, "AAPL", bars) }, true},
+			} {
+				database := newTestDatabase(t)
+				database.SetConflictStrategy(tt.strategy)
+				if err := write.fn(database, first); err != nil {
+					t.Fatalf("%s: %v", write.name, err)
+				}
+				if err := write.fn(database, refetch); err != nil {
+					t.Fatalf("%s again: %v", write.name, err)
+				}
+
+				bars, err := database.GetMinuteData(ctx, "AAPL", at, at.Add(time.Minute))
+				if err != nil {
+					t.Fatalf("GetMinuteData: %v", err)
+				}
+				if len(bars) != 2 {
+					t.Fatalf("%s: stored %d bars, want 2", write.name, len(bars))
+				}
+				if bars[0].Close != tt.wantClose || bars[0].Volume != tt.wantVolume {
+					t.Errorf("%s: first bar close %v volume %d, want %v and %d", write.name, bars[0].Close, bars[0].Volume, tt.wantClose, tt.wantVolume)
+				}
+				if bars[1].Close != 102 {
+					t.Errorf("%s: new bar close %v, want 102", write.name, bars[1].Close)
+				}
+
+				// The summary follows the stored bars, not the fetched ones
+				if !write.summarizes {
+					continue
+				}
+				summaries, err := database.GetDailySummaryMulti(ctx, []string{"AAPL"}, 3650)
+				if err != nil {
+					t.Fatalf("GetDailySummaryMulti: %v", err)
+				}
+				if day := summaries["AAPL"]; len(day) != 1 || day[0].Volume != tt.wantVolume+5 || day[0].Open != tt.wantClose {
+					t.Errorf("summary = %+v, want open %v volume %d", day, tt.wantClose, tt.wantVolume+5)
+				}
+			}
+		})
+	}
+}
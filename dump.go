@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dumpVersion identifies the layout of ExportAll documents
+const dumpVersion = 1
+
+// dumpBatchSize is how many rows ImportAll upserts at a time
+const dumpBatchSize = 1000
+
+// ExportAll streams a JSON dump of the watchlist and daily summaries, and the
+// minute bars when includeMinute is set, to w. Rows are written one at a time
+// from a database cursor, so the dump never has to fit in memory. Prices are
+// the stored fixed-point integers (see Price), so a round trip is lossless.
+// Weekly and monthly summaries are left out; ImportAll rebuilds them.
+func (d *Database) ExportAll(ctx context.Context, w io.Writer, includeMinute bool) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `{"version":%d,"exportedAt":%q`, dumpVersion, time.Now().UTC().Format(time.RFC3339))
+
+	if err := d.dumpTable(ctx, bw, "watchedStocks", &WatchedStock{}, func() interface{} { return &WatchedStock{} }); err != nil {
+		return err
+	}
+	if err := d.dumpTable(ctx, bw, "dailySummaries", &StockDailySummary{}, func() interface{} { return &StockDailySummary{} }); err != nil {
+		return err
+	}
+	if includeMinute {
+		if err := d.dumpTable(ctx, bw, "minuteData", &StockMinuteData{}, func() interface{} { return &StockMinuteData{} }); err != nil {
+			return err
+		}
+	}
+
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// dumpTable writes model's table as the JSON array member key, scanning each
+// row into a fresh newRow value
+func (d *Database) dumpTable(ctx context.Context, w *bufio.Writer, key string, model interface{}, newRow func() interface{}) error {
+	rows, err := d.db.WithContext(ctx).Model(model).Order("id").Rows()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", key, err)
+	}
+	defer rows.Close()
+
+	fmt.Fprintf(w, ",%q:[", key)
+	first := true
+	for rows.Next() {
+		row := newRow()
+		if err := d.db.ScanRows(rows, row); err != nil {
+			return fmt.Errorf("failed to read %s: %v", key, err)
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %v", key, err)
+		}
+
+		if !first {
+			w.WriteByte(',')
+		}
+		first = false
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to write %s: %v", key, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", key, err)
+	}
+
+	w.WriteByte(']')
+	return nil
+}
+
+// ImportAll restores a dump written by ExportAll, upserting each row on its
+// natural key (symbol, plus date or timestamp) so it can be loaded into a
+// fresh or an existing database. The document is decoded as it streams in and
+// applied in one transaction; weekly and monthly summaries are rebuilt from
+// the daily ones afterwards.
+func (d *Database) ImportAll(ctx context.Context, r io.Reader) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	symbols := make(map[string]bool)
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for dec.More() {
+			token, err := dec.Token()
+			if err != nil {
+				return fmt.Errorf("failed to read dump: %v", err)
+			}
+			key, _ := token.(string)
+
+			switch key {
+			case "version":
+				var version int
+				if err := dec.Decode(&version); err != nil {
+					return fmt.Errorf("failed to read dump version: %v", err)
+				}
+				if version != dumpVersion {
+					return fmt.Errorf("unsupported dump version %d, expected %d", version, dumpVersion)
+				}
+
+			case "watchedStocks":
+				err = decodeArray(dec, func() error {
+					var stock WatchedStock
+					if err := dec.Decode(&stock); err != nil {
+						return err
+					}
+					symbols[stock.Symbol] = true
+					return restoreWatchedStock(tx, stock)
+				})
+
+			case "dailySummaries":
+				var batch []StockDailySummary
+				err = decodeArray(dec, func() error {
+					var summary StockDailySummary
+					if err := dec.Decode(&summary); err != nil {
+						return err
+					}
+					summary.ID = 0
+					symbols[summary.Symbol] = true
+					batch = append(batch, summary)
+					if len(batch) < dumpBatchSize {
+						return nil
+					}
+					err := upsertDumpRows(tx, &batch, "symbol", "date")
+					batch = batch[:0]
+					return err
+				})
+				if err == nil && len(batch) > 0 {
+					err = upsertDumpRows(tx, &batch, "symbol", "date")
+				}
+
+			case "minuteData":
+				var batch []StockMinuteData
+				err = decodeArray(dec, func() error {
+					var bar StockMinuteData
+					if err := dec.Decode(&bar); err != nil {
+						return err
+					}
+					bar.ID = 0
+					symbols[bar.Symbol] = true
+					batch = append(batch, bar)
+					if len(batch) < dumpBatchSize {
+						return nil
+					}
+					err := upsertDumpRows(tx, &batch, "symbol", "timestamp")
+					batch = batch[:0]
+					return err
+				})
+				if err == nil && len(batch) > 0 {
+					err = upsertDumpRows(tx, &batch, "symbol", "timestamp")
+				}
+
+			default:
+				// exportedAt, and members from newer writers
+				var skip json.RawMessage
+				err = dec.Decode(&skip)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to import %s: %v", key, err)
+			}
+		}
+
+		if err := expectDelim(dec, '}'); err != nil {
+			return err
+		}
+		return backfillPeriodSummaries(tx)
+	})
+
+	for symbol := range symbols {
+		d.notifyDataChanged(symbol)
+	}
+	return err
+}
+
+// restoreWatchedStock writes stock over its tenant's watchlist entry for its
+// symbol, or adds it. Dumps from before tenants existed restore into the
+// default tenant.
+func restoreWatchedStock(tx *gorm.DB, stock WatchedStock) error {
+	if stock.Tenant == "" {
+		stock.Tenant = DefaultTenant
+	}
+	var existing WatchedStock
+	err := tx.Where("tenant = ? AND symbol = ?", stock.Tenant, stock.Symbol).Limit(1).Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	stock.ID = existing.ID
+	if stock.ID == 0 {
+		// Create swaps a false IsActive for the column default, so an
+		// inactive entry is deactivated afterwards
+		active := stock.IsActive
+		if err := tx.Create(&stock).Error; err != nil {
+			return err
+		}
+		if !active {
+			return tx.Model(&stock).Update("is_active", false).Error
+		}
+		return nil
+	}
+	return tx.Save(&stock).Error
+}
+
+// upsertDumpRows inserts rows (a pointer to a slice of models), replacing
+// existing rows that match on keys
+func upsertDumpRows(tx *gorm.DB, rows interface{}, keys ...string) error {
+	columns := make([]clause.Column, 0, len(keys))
+	for _, key := range keys {
+		columns = append(columns, clause.Column{Name: key})
+	}
+	return tx.Clauses(clause.OnConflict{Columns: columns, UpdateAll: true}).Create(rows).Error
+}
+
+// decodeArray reads the JSON array next in dec, calling each to decode every
+// element in turn
+func decodeArray(dec *json.Decoder, each func() error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		if err := each(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// expectDelim reads the next token from dec and fails unless it is delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to read dump: %v", err)
+	}
+	if token != delim {
+		return fmt.Errorf("malformed dump: expected %q, got %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dumpSnapshot lists the rows a dump carries or rebuilds, one string each,
+// in a stable order
+func dumpSnapshot(t *testing.T, d *Database) map[string][]string {
+	t.Helper()
+	snapshot := map[string][]string{}
+
+	var stocks []WatchedStock
+	if err := d.db.Order("tenant, symbol").Find(&stocks).Error; err != nil {
+		t.Fatalf("load watchlist: %v", err)
+	}
+	for _, s := range stocks {
+		snapshot["watchlist"] = append(snapshot["watchlist"],
+			fmt.Sprintf("%s %s %q active=%v order=%d precision=%d", s.Tenant, s.Symbol, s.Name, s.IsActive, s.SortOrder, s.Precision))
+	}
+
+	var daily []StockDailySummary
+	if err := d.db.Order("symbol, date").Find(&daily).Error; err != nil {
+		t.Fatalf("load daily summaries: %v", err)
+	}
+	for _, s := range daily {
+		snapshot["daily"] = append(snapshot["daily"],
+			fmt.Sprintf("%s %s %v %v %v %v %d", s.Symbol, s.Date.UTC().Format("2006-01-02"), s.Open, s.High, s.Low, s.Close, s.Volume))
+	}
+
+	var minute []StockMinuteData
+	if err := d.db.Order("symbol, timestamp").Find(&minute).Error; err != nil {
+		t.Fatalf("load minute data: %v", err)
+	}
+	for _, m := range minute {
+		snapshot["minute"] = append(snapshot["minute"],
+			fmt.Sprintf("%s %s %v %d %s", m.Symbol, m.Timestamp.UTC().Format(time.RFC3339), m.Close, m.Volume, m.Session))
+	}
+
+	var weeks int64
+	if err := d.db.Model(&StockWeeklySummary{}).Count(&weeks).Error; err != nil {
+		t.Fatalf("count weekly summaries: %v", err)
+	}
+	snapshot["weekly"] = []string{fmt.Sprint(weeks)}
+	return snapshot
+}
+
+// firstDifference describes where got first departs from want
+func firstDifference(got, want []string) string {
+	for i := range min(len(got), len(want)) {
+		if got[i] != want[i] {
+			return fmt.Sprintf("row %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+	return fmt.Sprintf("%d rows, want %d", len(got), len(want))
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := newTestDatabase(t)
+
+	// Watchlist entries in two tenants, one inactive, one at 4 decimals
+	if _, err := source.AddWatchedStock(ctx, "AAPL", "Apple Inc."); err != nil {
+		t.Fatalf("AddWatchedStock: %v", err)
+	}
+	if _, err := source.AddWatchedStock(ctx, "MSFT", "Microsoft"); err != nil {
+		t.Fatalf("AddWatchedStock: %v", err)
+	}
+	if err := source.SetWatchedStockActive(ctx, "MSFT", false); err != nil {
+		t.Fatalf("SetWatchedStockActive: %v", err)
+	}
+	if _, err := source.AddWatchedStock(WithTenant(ctx, "other"), "PENNY", "Penny Corp"); err != nil {
+		t.Fatalf("AddWatchedStock: %v", err)
+	}
+	if err := source.SetPricePrecision(ctx, "PENNY", 4); err != nil {
+		t.Fatalf("SetPricePrecision: %v", err)
+	}
+
+	// More minute bars than one import batch, over a few days from
+	// 2024-03-05 10:00 New York
+	start := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	var bars []MinuteBar
+	for i := range dumpBatchSize + 3 {
+		bars = append(bars, testBar("AAPL", start.Add(time.Duration(i)*time.Minute), 100+float64(i%50)/100, int64(i+1)))
+	}
+	if err := source.InsertWithSummary(ctx, "AAPL", bars); err != nil {
+		t.Fatalf("InsertWithSummary: %v", err)
+	}
+	penny := []MinuteBar{testBar("PENNY", start, 0.1234, 500)}
+	if err := source.InsertWithSummary(ctx, "PENNY", penny); err != nil {
+		t.Fatalf("InsertWithSummary: %v", err)
+	}
+	want := dumpSnapshot(t, source)
+
+	tests := []struct {
+		name          string
+		includeMinute bool
+	}{
+		{name: "with minute data", includeMinute: true},
+		{name: "summaries only", includeMinute: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dump bytes.Buffer
+			if err := source.ExportAll(ctx, &dump, tt.includeMinute); err != nil {
+				t.Fatalf("ExportAll: %v", err)
+			}
+
+			wantHere := want
+			if !tt.includeMinute {
+				wantHere = map[string][]string{}
+				for k, v := range want {
+					wantHere[k] = v
+				}
+				delete(wantHere, "minute")
+			}
+
+			restored := newTestDatabase(t)
+			// Importing twice must upsert rather than duplicate
+			for i := range 2 {
+				if err := restored.ImportAll(ctx, bytes.NewReader(dump.Bytes())); err != nil {
+					t.Fatalf("ImportAll #%d: %v", i+1, err)
+				}
+				got := dumpSnapshot(t, restored)
+				for key, rows := range wantHere {
+					if !reflect.DeepEqual(got[key], rows) {
+						t.Errorf("after import #%d, %s differs:\n%s", i+1, key, firstDifference(got[key], rows))
+					}
+				}
+				if _, ok := got["minute"]; ok && !tt.includeMinute {
+					t.Errorf("after import #%d, minute data restored from a dump without it", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestImportAllRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+	}{
+		{name: "newer version", dump: `{"version":99,"watchedStocks":[]}`},
+		{name: "not an object", dump: `[]`},
+		{name: "truncated", dump: `{"version":1,"watchedStocks":[{"symbol":"AAPL"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			if err := database.ImportAll(context.Background(), strings.NewReader(tt.dump)); err == nil {
+				t.Fatal("ImportAll: want an error")
+			}
+			stocks, err := database.GetWatchedStocks(context.Background(), WatchlistAll)
+			if err != nil {
+				t.Fatalf("GetWatchedStocks: %v", err)
+			}
+			if len(stocks) != 0 {
+				t.Errorf("a failed import left %d watched stocks", len(stocks))
+			}
+		})
+	}
+}
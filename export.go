@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Export formats supported by the export endpoint
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatTSV     = "tsv"
+	ExportFormatJSON    = "json"
+	ExportFormatJSONL   = "jsonl"
+	ExportFormatParquet = "parquet"
+)
+
+// exportColumns is the header row of delimited exports
+var exportColumns = []string{"symbol", "timestamp", "open", "high", "low", "close", "volume", "currency"}
+
+// BarSource calls fn with each bar of an export in order, stopping at and
+// returning the first error fn returns. Database.StreamMinuteData is one, so
+// exports can be written without loading the whole range.
+type BarSource func(fn func(MinuteBar) error) error
+
+// writeDelimited writes the bars from source as CSV (comma ',') or TSV
+// (comma '\t') with a header row. Timestamps are RFC 3339.
+func writeDelimited(w io.Writer, source BarSource, comma rune) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+
+	if err := writer.Write(exportColumns); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+
+	err := source(func(bar MinuteBar) error {
+		record := []string{
+			bar.Symbol,
+			bar.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
+			strconv.FormatFloat(bar.Open, 'f', -1, 64),
+			strconv.FormatFloat(bar.High, 'f', -1, 64),
+			strconv.FormatFloat(bar.Low, 'f', -1, 64),
+			strconv.FormatFloat(bar.Close, 'f', -1, 64),
+			strconv.FormatInt(bar.Volume, 10),
+			bar.Currency,
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write row: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// parquetBar is the Parquet row layout: timestamp as int64 Unix milliseconds
+// annotated as a timestamp so Spark and pandas load it as a datetime column
+type parquetBar struct {
+	Symbol    string  `parquet:"symbol,dict"`
+	Timestamp int64   `parquet:"timestamp,timestamp(millisecond)"`
+	Open      float64 `parquet:"open"`
+	High      float64 `parquet:"high"`
+	Low       float64 `parquet:"low"`
+	Close     float64 `parquet:"close"`
+	Volume    int64   `parquet:"volume"`
+	Currency  string  `parquet:"currency,dict"`
+}
+
+// parquetWriteBatch is how many rows writeParquet hands the writer at a time
+const parquetWriteBatch = 1024
+
+// writeParquet writes the bars from source as a single Parquet file
+func writeParquet(w io.Writer, source BarSource) error {
+	writer := parquet.NewGenericWriter[parquetBar](w)
+	rows := make([]parquetBar, 0, parquetWriteBatch)
+	flush := func() error {
+		if _, err := writer.Write(rows); err != nil {
+			return fmt.Errorf("failed to write parquet rows: %v", err)
+		}
+		rows = rows[:0]
+		return nil
+	}
+
+	err := source(func(bar MinuteBar) error {
+		rows = append(rows, parquetBar{
+			Symbol:    bar.Symbol,
+			Timestamp: bar.Timestamp.UnixMilli(),
+			Open:      bar.Open,
+			High:      bar.High,
+			Low:       bar.Low,
+			Close:     bar.Close,
+			Volume:    bar.Volume,
+			Currency:  bar.Currency,
+		})
+		if len(rows) < parquetWriteBatch {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil && len(rows) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish parquet file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// sliceSource is a BarSource over bars
+func sliceSource(bars []MinuteBar) BarSource {
+	return func(fn func(MinuteBar) error) error {
+		for _, bar := range bars {
+			if err := fn(bar); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// exportBars returns n minute bars of AAPL from 2024-03-05 14:30 UTC with
+// four-decimal prices
+func exportBars(n int) []MinuteBar {
+	start := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+	bars := make([]MinuteBar, n)
+	for i := range bars {
+		price := 170 + float64(i)/10000
+		bars[i] = MinuteBar{
+			Symbol:    "AAPL",
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Open:      price,
+			High:      price + 0.5,
+			Low:       price - 0.5,
+			Close:     price + 0.25,
+			Volume:    int64(1000 + i),
+			Currency:  "USD",
+		}
+	}
+	return bars
+}
+
+func TestWriteParquetRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		bars []MinuteBar
+	}{
+		{name: "empty", bars: nil},
+		{name: "a few bars", bars: exportBars(3)},
+		{name: "more than one write batch", bars: exportBars(2*parquetWriteBatch + 7)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeParquet(&buf, sliceSource(tt.bars)); err != nil {
+				t.Fatalf("writeParquet: %v", err)
+			}
+
+			rows, err := parquet.Read[parquetBar](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("read parquet: %v", err)
+			}
+			if len(rows) != len(tt.bars) {
+				t.Fatalf("read %d rows, want %d", len(rows), len(tt.bars))
+			}
+			for i, bar := range tt.bars {
+				row := rows[i]
+				got := MinuteBar{
+					Symbol:    row.Symbol,
+					Timestamp: time.UnixMilli(row.Timestamp).UTC(),
+					Open:      row.Open,
+					High:      row.High,
+					Low:       row.Low,
+					Close:     row.Close,
+					Volume:    row.Volume,
+					Currency:  row.Currency,
+				}
+				if got != bar {
+					t.Fatalf("row %d = %+v, want %+v", i, got, bar)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteDelimited(t *testing.T) {
+	bars := exportBars(2)
+
+	tests := []struct {
+		name  string
+		comma rune
+		want  string
+	}{
+		{
+			name:  "csv",
+			comma: ',',
+			want: "symbol,timestamp,open,high,low,close,volume,currency\n" +
+				"AAPL,2024-03-05T14:30:00Z,170,170.5,169.5,170.25,1000,USD\n" +
+				"AAPL,2024-03-05T14:31:00Z,170.0001,170.5001,169.5001,170.2501,1001,USD\n",
+		},
+		{
+			name:  "tsv",
+			comma: '\t',
+			want: "symbol\ttimestamp\topen\thigh\tlow\tclose\tvolume\tcurrency\n" +
+				"AAPL\t2024-03-05T14:30:00Z\t170\t170.5\t169.5\t170.25\t1000\tUSD\n" +
+				"AAPL\t2024-03-05T14:31:00Z\t170.0001\t170.5001\t169.5001\t170.2501\t1001\tUSD\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf strings.Builder
+			if err := writeDelimited(&buf, sliceSource(bars), tt.comma); err != nil {
+				t.Fatalf("writeDelimited: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -14,11 +14,13 @@ type StockMinuteData struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	Symbol    string    `gorm:"index:idx_symbol;not null" json:"symbol"`
 	Timestamp time.Time `gorm:"index:idx_timestamp;not null" json:"timestamp"`
-	Open      float64   `gorm:"not null" json:"open"`
-	High      float64   `gorm:"not null" json:"high"`
-	Low       float64   `gorm:"not null" json:"low"`
-	Close     float64   `gorm:"not null" json:"close"`
+	Open      Price     `gorm:"not null" json:"open"`
+	High      Price     `gorm:"not null" json:"high"`
+	Low       Price     `gorm:"not null" json:"low"`
+	Close     Price     `gorm:"not null" json:"close"`
 	Volume    int64     `gorm:"not null" json:"volume"`
+	Currency  string    `gorm:"default:USD" json:"currency"`
+	Session   string    `gorm:"default:regular;not null" json:"session"` // regular, pre or post (New York time)
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 }
@@ -37,14 +39,34 @@ func (StockMinuteData) TableName() string {
 	return "stock_minute_data"
 }
 
+// MinuteBar converts the stored row to the MinuteBar the rest of the code uses
+func (s StockMinuteData) MinuteBar() MinuteBar {
+	return MinuteBar{
+		Symbol:    s.Symbol,
+		Timestamp: s.Timestamp,
+		Open:      s.Open.Float64(),
+		High:      s.High.Float64(),
+		Low:       s.Low.Float64(),
+		Close:     s.Close.Float64(),
+		Volume:    s.Volume,
+		Currency:  s.Currency,
+		Session:   s.Session,
+	}
+}
+
 // WatchedStock represents stocks that are being monitored
 type WatchedStock struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	Symbol    string    `gorm:"uniqueIndex;index:idx_watched_stocks_symbol;not null" json:"symbol"`
+	Tenant    string    `gorm:"uniqueIndex:idx_watched_stocks_tenant_symbol,priority:1;not null;default:default" json:"tenant"`
+	Symbol    string    `gorm:"uniqueIndex:idx_watched_stocks_tenant_symbol,priority:2;not null" json:"symbol"`
 	Name      string    `gorm:"" json:"name"`
+	Exchange  string    `gorm:"" json:"exchange"`
+	Currency  string    `gorm:"default:USD" json:"currency"`
 	AddedAt   time.Time `gorm:"autoCreateTime" json:"addedAt"`
 	LastSync  *time.Time `gorm:"" json:"lastSync"`
 	IsActive  bool      `gorm:"default:true;not null" json:"isActive"`
+	SortOrder int       `gorm:"default:0;not null" json:"sortOrder"`
+	Precision int       `gorm:"default:2;not null" json:"precision"` // Decimal places stored prices are rounded to
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 }
@@ -59,23 +81,106 @@ type StockDailySummary struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	Symbol    string    `gorm:"index:idx_daily_summary_symbol_date;not null" json:"symbol"`
 	Date      time.Time `gorm:"index:idx_daily_summary_symbol_date;not null" json:"date"`
+	Open      Price     `gorm:"not null" json:"open"`
+	High      Price     `gorm:"not null" json:"high"`
+	Low       Price     `gorm:"not null" json:"low"`
+	Close     Price     `gorm:"not null" json:"close"`
+	Volume    int64     `gorm:"not null" json:"volume"`
+	BarCount  int       `gorm:"default:0;not null" json:"barCount"` // regular-session minute bars; 0 for rows from daily history
+	VWAP      Price     `gorm:"column:vwap;default:0;not null" json:"vwap"` // volume-weighted typical price of the day's bars; 0 when unknown
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName specifies the table name for StockDailySummary
+func (StockDailySummary) TableName() string {
+	return "stock_daily_summary"
+}
+
+// StockWeeklySummary represents weekly (Monday–Friday) OHLCV rolled up from daily summaries
+type StockWeeklySummary struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Symbol    string    `gorm:"index:idx_weekly_summary_symbol_date;not null" json:"symbol"`
+	Date      time.Time `gorm:"index:idx_weekly_summary_symbol_date;not null" json:"date"` // Monday of the week
+	Open      Price     `gorm:"not null" json:"open"`
+	High      Price     `gorm:"not null" json:"high"`
+	Low       Price     `gorm:"not null" json:"low"`
+	Close     Price     `gorm:"not null" json:"close"`
+	Volume    int64     `gorm:"not null" json:"volume"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName specifies the table name for StockWeeklySummary
+func (StockWeeklySummary) TableName() string {
+	return "stock_weekly_summary"
+}
+
+// StockMonthlySummary represents calendar-month OHLCV rolled up from daily summaries
+type StockMonthlySummary struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Symbol    string    `gorm:"index:idx_monthly_summary_symbol_date;not null" json:"symbol"`
+	Date      time.Time `gorm:"index:idx_monthly_summary_symbol_date;not null" json:"date"` // First day of the month
+	Open      Price     `gorm:"not null" json:"open"`
+	High      Price     `gorm:"not null" json:"high"`
+	Low       Price     `gorm:"not null" json:"low"`
+	Close     Price     `gorm:"not null" json:"close"`
+	Volume    int64     `gorm:"not null" json:"volume"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName specifies the table name for StockMonthlySummary
+func (StockMonthlySummary) TableName() string {
+	return "stock_monthly_summary"
+}
+
+// CorporateEvent represents a dividend or earnings date for a symbol
+type CorporateEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Symbol    string    `gorm:"index:idx_corporate_events_symbol_type_date;not null" json:"symbol"`
+	Type      string    `gorm:"index:idx_corporate_events_symbol_type_date;not null" json:"type"` // dividend or earnings
+	Date      time.Time `gorm:"index:idx_corporate_events_symbol_type_date;not null" json:"date"`
+	Amount    float64   `gorm:"" json:"amount"` // Dividend per share; zero for earnings
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName specifies the table name for CorporateEvent
+func (CorporateEvent) TableName() string {
+	return "corporate_events"
+}
+
+// RejectedBar is a minute bar the anomaly filters dropped, kept with the
+// reason so over-aggressive filtering can be audited. Prices are Yahoo's raw
+// values, which may be implausible by definition.
+type RejectedBar struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Symbol    string    `gorm:"index:idx_rejected_bars_symbol_timestamp;not null" json:"symbol"`
+	Timestamp time.Time `gorm:"index:idx_rejected_bars_symbol_timestamp;not null" json:"timestamp"`
 	Open      float64   `gorm:"not null" json:"open"`
 	High      float64   `gorm:"not null" json:"high"`
 	Low       float64   `gorm:"not null" json:"low"`
 	Close     float64   `gorm:"not null" json:"close"`
 	Volume    int64     `gorm:"not null" json:"volume"`
+	Reason    string    `gorm:"not null" json:"reason"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 }
 
-// TableName specifies the table name for StockDailySummary
-func (StockDailySummary) TableName() string {
-	return "stock_daily_summary"
+// TableName specifies the table name for RejectedBar
+func (RejectedBar) TableName() string {
+	return "rejected_bars"
 }
 
-// Get all model types for auto migration
+// allModels lists every model; once all migrations have run, the database has
+// a table with a column for each of their fields
 var allModels = []interface{}{
 	&StockMinuteData{},
 	&WatchedStock{},
 	&StockDailySummary{},
+	&StockWeeklySummary{},
+	&StockMonthlySummary{},
+	&CorporateEvent{},
+	&RejectedBar{},
 }
\ No newline at end of file
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+)
+
+// GraphQL objects mirror the REST JSON field names, so the default resolver
+// reads the API structs through their json tags. Volumes are Float because
+// GraphQL Int is 32-bit.
+var (
+	dailySummaryGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "DailySummary",
+		Fields: graphql.Fields{
+			"symbol": &graphql.Field{Type: graphql.String},
+			"date":   &graphql.Field{Type: graphql.DateTime},
+			"open":   &graphql.Field{Type: graphql.Float},
+			"high":   &graphql.Field{Type: graphql.Float},
+			"low":    &graphql.Field{Type: graphql.Float},
+			"close":  &graphql.Field{Type: graphql.Float},
+			"volume": &graphql.Field{Type: graphql.Float},
+		},
+	})
+
+	minuteBarGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "MinuteBar",
+		Fields: graphql.Fields{
+			"symbol":    &graphql.Field{Type: graphql.String},
+			"timestamp": &graphql.Field{Type: graphql.DateTime},
+			"open":      &graphql.Field{Type: graphql.Float},
+			"high":      &graphql.Field{Type: graphql.Float},
+			"low":       &graphql.Field{Type: graphql.Float},
+			"close":     &graphql.Field{Type: graphql.Float},
+			"volume":    &graphql.Field{Type: graphql.Float},
+			"currency":  &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	stockSummaryGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "StockSummary",
+		Fields: graphql.Fields{
+			"symbol":        &graphql.Field{Type: graphql.String},
+			"name":          &graphql.Field{Type: graphql.String},
+			"currency":      &graphql.Field{Type: graphql.String},
+			"currentPrice":  &graphql.Field{Type: graphql.Float},
+			"change":        &graphql.Field{Type: graphql.Float},
+			"changePercent": &graphql.Field{Type: graphql.Float},
+			"lastUpdate":    &graphql.Field{Type: graphql.DateTime},
+			"dailyData":     &graphql.Field{Type: graphql.NewList(dailySummaryGraphQLType)},
+		},
+	})
+)
+
+// summaryArgs are shared by Query.summary and WatchedStock.summary
+var summaryArgs = graphql.FieldConfigArgument{
+	"days":        &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 30},
+	"granularity": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: GranularityDaily},
+}
+
+// newGraphQLSchema builds the read-only schema served at /graphql. Resolvers
+// call the same Database and summary code as the REST handlers.
+func (ws *WebServer) newGraphQLSchema() (graphql.Schema, error) {
+	resolveSummary := func(p graphql.ResolveParams, symbol string) (interface{}, error) {
+		days, _ := p.Args["days"].(int)
+		granularity, _ := p.Args["granularity"].(string)
+		if days <= 0 {
+			return nil, fmt.Errorf("days must be positive")
+		}
+		if !isValidGranularity(granularity) {
+			return nil, fmt.Errorf("invalid granularity, expected daily, weekly or monthly")
+		}
+		return ws.buildStockSummary(p.Context, symbol, days, granularity)
+	}
+
+	watchedStockType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "WatchedStock",
+		Fields: graphql.Fields{
+			"id":                  &graphql.Field{Type: graphql.Int},
+			"symbol":              &graphql.Field{Type: graphql.String},
+			"name":                &graphql.Field{Type: graphql.String},
+			"exchange":            &graphql.Field{Type: graphql.String},
+			"currency":            &graphql.Field{Type: graphql.String},
+			"addedAt":             &graphql.Field{Type: graphql.DateTime},
+			"lastSync":            &graphql.Field{Type: graphql.DateTime},
+			"isActive":            &graphql.Field{Type: graphql.Boolean},
+			"sortOrder":           &graphql.Field{Type: graphql.Int},
+			"precision":           &graphql.Field{Type: graphql.Int},
+			"isStale":             &graphql.Field{Type: graphql.Boolean},
+			"recordCount":         &graphql.Field{Type: graphql.Int},
+			"latestDataTimestamp": &graphql.Field{Type: graphql.DateTime},
+			"summary": &graphql.Field{
+				Type: stockSummaryGraphQLType,
+				Args: summaryArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveSummary(p, p.Source.(WatchedStockAPI).Symbol)
+				},
+			},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"watchedStocks": &graphql.Field{
+				Type: graphql.NewList(watchedStockType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					stocks, err := ws.collector.database.GetWatchedStocksWithStats(p.Context, WatchlistActive)
+					if err != nil {
+						return nil, err
+					}
+					return ws.toWatchedStocksAPI(stocks), nil
+				},
+			},
+			"summary": &graphql.Field{
+				Type: stockSummaryGraphQLType,
+				Args: graphql.FieldConfigArgument{
+					"symbol":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"days":        summaryArgs["days"],
+					"granularity": summaryArgs["granularity"],
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return resolveSummary(p, strings.ToUpper(p.Args["symbol"].(string)))
+				},
+			},
+			"minuteBars": &graphql.Field{
+				Type: graphql.NewList(minuteBarGraphQLType),
+				Args: graphql.FieldConfigArgument{
+					"symbol": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"days":   &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					days, _ := p.Args["days"].(int)
+					if days <= 0 {
+						return nil, fmt.Errorf("days must be positive")
+					}
+					symbol := strings.ToUpper(p.Args["symbol"].(string))
+					return ws.collector.GetDataForAnalysis(p.Context, symbol, days)
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query" form:"query"`
+	OperationName string                 `json:"operationName" form:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphqlQuery executes a GraphQL query from a JSON POST body or the query
+// string of a GET. Resolver errors are reported in the response's errors
+// array with a 200, as GraphQL clients expect.
+func (ws *WebServer) graphqlQuery(c *gin.Context) {
+	var req graphQLRequest
+	var err error
+	if c.Request.Method == http.MethodGet {
+		err = c.ShouldBindQuery(&req)
+	} else {
+		err = c.ShouldBindJSON(&req)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         ws.graphqlSchema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        c.Request.Context(),
+	})
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	stockpb "stock-data-collector/proto"
+)
+
+// grpcService implements stockpb.StockCollectorServer on top of the same
+// collector and summary code as the REST handlers
+type grpcService struct {
+	stockpb.UnimplementedStockCollectorServer
+	ws *WebServer
+}
+
+func (g *grpcService) Collect(ctx context.Context, req *stockpb.CollectRequest) (*stockpb.CollectResponse, error) {
+	symbol := strings.ToUpper(req.GetSymbol())
+	if !isValidSymbol(symbol) {
+		return nil, status.Error(codes.InvalidArgument, "invalid symbol")
+	}
+	if req.GetDays() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "days must be positive")
+	}
+
+	if err := g.ws.collector.TryCollectHistoricalData(ctx, symbol, int(req.GetDays()), syncLockWait); err != nil {
+		return nil, grpcError(err)
+	}
+
+	count, earliest, latest, err := g.ws.collector.database.GetDataStats(ctx, symbol)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &stockpb.CollectResponse{
+		Symbol:       symbol,
+		TotalRecords: int64(count),
+		Earliest:     timestamppb.New(earliest),
+		Latest:       timestamppb.New(latest),
+	}, nil
+}
+
+func (g *grpcService) GetSummary(ctx context.Context, req *stockpb.GetSummaryRequest) (*stockpb.GetSummaryResponse, error) {
+	symbol := strings.ToUpper(req.GetSymbol())
+	if symbol == "" {
+		return nil, status.Error(codes.InvalidArgument, "symbol is required")
+	}
+
+	days := int(req.GetDays())
+	if days == 0 {
+		days = 30
+	}
+	if days < 0 {
+		return nil, status.Error(codes.InvalidArgument, "days must be positive")
+	}
+
+	granularity := req.GetGranularity()
+	if granularity == "" {
+		granularity = GranularityDaily
+	}
+	if !isValidGranularity(granularity) {
+		return nil, status.Error(codes.InvalidArgument, "invalid granularity, expected daily, weekly or monthly")
+	}
+
+	summary, err := g.ws.buildStockSummary(ctx, symbol, days, granularity)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &stockpb.GetSummaryResponse{
+		Symbol:        summary.Symbol,
+		Name:          summary.Name,
+		Currency:      summary.Currency,
+		CurrentPrice:  summary.CurrentPrice,
+		Change:        summary.Change,
+		ChangePercent: summary.ChangePercent,
+	}
+	if !summary.LastUpdate.IsZero() {
+		resp.LastUpdate = timestamppb.New(summary.LastUpdate)
+	}
+	for _, bar := range summary.DailyData {
+		resp.Bars = append(resp.Bars, &stockpb.SummaryBar{
+			Date:   timestamppb.New(bar.Date),
+			Open:   bar.Open,
+			High:   bar.High,
+			Low:    bar.Low,
+			Close:  bar.Close,
+			Volume: bar.Volume,
+		})
+	}
+
+	return resp, nil
+}
+
+// GetData sends one message per bar so large ranges never exceed the
+// message size limit
+func (g *grpcService) GetData(req *stockpb.GetDataRequest, stream stockpb.StockCollector_GetDataServer) error {
+	ctx := stream.Context()
+
+	symbol := strings.ToUpper(req.GetSymbol())
+	if symbol == "" {
+		return status.Error(codes.InvalidArgument, "symbol is required")
+	}
+	if req.GetStart() == nil {
+		return status.Error(codes.InvalidArgument, "start is required")
+	}
+
+	start := req.GetStart().AsTime()
+	end := time.Now()
+	if req.GetEnd() != nil {
+		end = req.GetEnd().AsTime()
+	}
+	if end.Before(start) {
+		return status.Error(codes.InvalidArgument, "end must not be before start")
+	}
+
+	// Bars are sent as they are read, so a long range is never held in memory
+	var sendErr error
+	err := g.ws.collector.database.StreamMinuteData(ctx, symbol, start, end, func(bar MinuteBar) error {
+		sendErr = stream.Send(&stockpb.MinuteBar{
+			Symbol:    bar.Symbol,
+			Timestamp: timestamppb.New(bar.Timestamp),
+			Open:      bar.Open,
+			High:      bar.High,
+			Low:       bar.Low,
+			Close:     bar.Close,
+			Volume:    bar.Volume,
+			Currency:  bar.Currency,
+		})
+		return sendErr
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return grpcError(err)
+	}
+	return nil
+}
+
+// grpcError maps collector errors onto gRPC status codes, matching the REST
+// handlers' 404/409/429/504 handling
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidSymbol):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrRateLimited):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, ErrSyncInProgress):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// newGRPCServer registers the StockCollector service backed by ws
+func newGRPCServer(ws *WebServer) *grpc.Server {
+	server := grpc.NewServer()
+	stockpb.RegisterStockCollectorServer(server, &grpcService{ws: ws})
+	return server
+}
+
+// RunGRPC serves the gRPC API on addr until Close is called
+func (ws *WebServer) RunGRPC(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("gRPC server starting on %s", addr)
+	return ws.grpcServer.Serve(listener)
+}
@@ -1,249 +1,2100 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// getWatchedStocks lists the active watched stocks, with
+// ?includeInactive=true the paused ones too, or with ?includeInactive=only
+// just the paused ones
 func (ws *WebServer) getWatchedStocks(c *gin.Context) {
-	stocks, err := ws.collector.database.GetWatchedStocks()
+	ctx := c.Request.Context()
+
+	var status string
+	switch c.DefaultQuery("includeInactive", "false") {
+	case "false":
+		status = WatchlistActive
+	case "true":
+		status = WatchlistAll
+	case "only":
+		status = WatchlistInactive
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid includeInactive, expected true, false or only"})
+		return
+	}
+
+	stocks, err := ws.collector.database.GetWatchedStocksWithStats(ctx, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ws.toWatchedStocksAPI(stocks))
+}
+
+// toWatchedStocksAPI converts GORM models to API models, flagging stocks that
+// haven't synced within StaleAfter
+func (ws *WebServer) toWatchedStocksAPI(stocks []WatchedStockStats) []WatchedStockAPI {
+	now := time.Now()
+	var apiStocks []WatchedStockAPI
+	for _, stock := range stocks {
+		isStale := stock.LastSync == nil
+		if stock.LastSync != nil && ws.config.StaleAfter > 0 {
+			isStale = now.Sub(*stock.LastSync) > ws.config.StaleAfter
+		}
+
+		apiStocks = append(apiStocks, WatchedStockAPI{
+			ID:                  int(stock.ID),
+			Symbol:              stock.Symbol,
+			Name:                stock.Name,
+			Exchange:            stock.Exchange,
+			Currency:            stock.Currency,
+			AddedAt:             stock.AddedAt,
+			LastSync:            stock.LastSync,
+			IsActive:            stock.IsActive,
+			SortOrder:           stock.SortOrder,
+			Precision:           stock.Precision,
+			IsStale:             isStale,
+			RecordCount:         stock.RecordCount,
+			LatestDataTimestamp: stock.LatestDataTimestamp,
+		})
+	}
+	return apiStocks
+}
+
+// getLatestPrices returns the latest price and daily change of every active
+// watched stock, in watchlist order, so the dashboard needs one request
+// instead of one summary per stock
+func (ws *WebServer) getLatestPrices(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	stocks, err := ws.collector.database.GetWatchedStocks(ctx, WatchlistActive)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	symbols := make([]string, 0, len(stocks))
+	for _, stock := range stocks {
+		symbols = append(symbols, stock.Symbol)
+	}
+
+	prices, err := ws.collector.database.GetLatestPrices(ctx, symbols)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	bySymbol := make(map[string]LatestPrice, len(prices))
+	for _, price := range prices {
+		bySymbol[price.Symbol] = price
+	}
+	ordered := make([]LatestPrice, 0, len(prices))
+	for _, symbol := range symbols {
+		if price, ok := bySymbol[symbol]; ok {
+			ordered = append(ordered, price)
+		}
+	}
+
+	c.JSON(http.StatusOK, ordered)
+}
+
+// defaultMoversLimit and maxMoversLimit bound each list from getMovers
+const (
+	defaultMoversLimit = 10
+	maxMoversLimit     = 100
+)
+
+// getSpotPrice returns the live price from Yahoo without syncing minute data
+func (ws *WebServer) getSpotPrice(c *gin.Context) {
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if !isValidSymbol(symbol) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock symbol"})
+		return
+	}
+
+	cacheKey := "spot:" + symbol
+	if ws.serveCachedFor(c, cacheKey, ws.config.SpotCacheTTL) {
+		return
+	}
+
+	spot, err := ws.collector.yahooClient.GetSpotPrice(c.Request.Context(), symbol)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	ws.respondCachedFor(c, cacheKey, spot, ws.config.SpotCacheTTL)
+}
+
+// getMovers returns the watched stocks with the biggest percentage gains and
+// losses between the close days trading days ago and the latest price
+func (ws *WebServer) getMovers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	days := 1
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	limit := defaultMoversLimit
+	if limitQuery := c.Query("limit"); limitQuery != "" {
+		l, err := parseDays(limitQuery)
+		if err != nil || l <= 0 || l > maxMoversLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxMoversLimit)})
+			return
+		}
+		limit = l
+	}
+
+	stocks, err := ws.collector.database.GetWatchedStocks(ctx, WatchlistActive)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	symbols := make([]string, 0, len(stocks))
+	for _, stock := range stocks {
+		symbols = append(symbols, stock.Symbol)
+	}
+
+	prices, err := ws.collector.database.GetPriceChanges(ctx, symbols, days)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].ChangePercent > prices[j].ChangePercent
+	})
+
+	response := MoversResponse{Days: days, Gainers: []LatestPrice{}, Losers: []LatestPrice{}}
+	for _, price := range prices {
+		if price.ChangePercent > 0 && len(response.Gainers) < limit {
+			response.Gainers = append(response.Gainers, price)
+		}
+	}
+	for i := len(prices) - 1; i >= 0; i-- {
+		if prices[i].ChangePercent < 0 && len(response.Losers) < limit {
+			response.Losers = append(response.Losers, prices[i])
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (ws *WebServer) addWatchedStock(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req AddStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	symbol, aliased := ws.aliases.Resolve(req.Symbol)
+	if !isValidSymbol(symbol) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock symbol"})
+		return
+	}
+	if !isValidPrecision(req.Precision) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("precision must be between 0 and %d", priceDecimals)})
+		return
+	}
+
+	// Look up name, exchange and currency; a failure here shouldn't block adding
+	meta, metaErr := ws.collector.yahooClient.GetQuoteMeta(ctx, symbol)
+	if metaErr != nil {
+		log.Printf("Warning: failed to fetch metadata for %s: %v", symbol, metaErr)
+	}
+
+	name := req.Name
+	if name == "" {
+		name = meta.Name
+	}
+
+	// Add to watched stocks; a stock already there keeps its entry, renamed
+	// when the request gives a name
+	created, err := ws.collector.database.AddWatchedStock(ctx, symbol, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !created && req.Name != "" {
+		if err := ws.collector.database.SetWatchedStockName(ctx, symbol, req.Name); err != nil {
+			respondServerError(c, err)
+			return
+		}
+	}
+
+	if req.Precision != nil {
+		if err := ws.collector.database.SetPricePrecision(ctx, symbol, *req.Precision); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if metaErr == nil {
+		if err := ws.collector.database.UpdateWatchedStockMeta(ctx, symbol, meta); err != nil {
+			log.Printf("Warning: failed to store metadata for %s: %v", symbol, err)
+		}
+	}
+
+	if err := invalidateWatchedSearch(ctx, ws.collector.cache, TenantFromContext(ctx)); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	message := "Stock added successfully"
+	if !created {
+		message = "Stock already in watchlist"
+	}
+	response := gin.H{
+		"message": message,
+		"symbol":  symbol,
+		"created": created,
+	}
+	if aliased {
+		response["resolvedFrom"] = req.Symbol
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (ws *WebServer) addWatchedStocksBatch(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req BatchAddStocksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate up front so only well-formed, distinct symbols reach the database
+	results := make([]BatchAddResult, len(req.Symbols))
+	var toAdd []WatchedStock
+	var toAddIndex []int
+	seen := make(map[string]bool)
+	for i, item := range req.Symbols {
+		symbol := strings.ToUpper(strings.TrimSpace(item.Symbol))
+		results[i] = BatchAddResult{Symbol: symbol}
+
+		switch {
+		case !isValidSymbol(symbol):
+			results[i].Status = BatchStatusInvalid
+			results[i].Reason = "Invalid stock symbol"
+		case !isValidPrecision(item.Precision):
+			results[i].Status = BatchStatusInvalid
+			results[i].Reason = fmt.Sprintf("precision must be between 0 and %d", priceDecimals)
+		case seen[symbol]:
+			results[i].Status = BatchStatusSkipped
+			results[i].Reason = "Duplicate in request"
+		default:
+			seen[symbol] = true
+			toAdd = append(toAdd, WatchedStock{Symbol: symbol, Name: item.Name})
+			toAddIndex = append(toAddIndex, i)
+		}
+	}
+
+	created, err := ws.collector.database.AddWatchedStocks(ctx, toAdd)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	added := 0
+	for j, isNew := range created {
+		i := toAddIndex[j]
+		if isNew {
+			results[i].Status = BatchStatusAdded
+			added++
+			if precision := req.Symbols[i].Precision; precision != nil {
+				if err := ws.collector.database.SetPricePrecision(ctx, results[i].Symbol, *precision); err != nil {
+					log.Printf("Warning: failed to set precision for %s: %v", results[i].Symbol, err)
+				}
+			}
+		} else {
+			results[i].Status = BatchStatusSkipped
+			results[i].Reason = "Already in watchlist"
+		}
+	}
+
+	if added > 0 {
+		if err := invalidateWatchedSearch(ctx, ws.collector.cache, TenantFromContext(ctx)); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Added %d of %d stocks", added, len(req.Symbols)),
+		"results": results,
+	})
+}
+
+func (ws *WebServer) updateWatchedStock(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
+		return
+	}
+
+	var req UpdateStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ws.collector.database.SetWatchedStockActive(ctx, symbol, *req.IsActive); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found in watchlist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Stock updated successfully",
+		"symbol":   symbol,
+		"isActive": *req.IsActive,
+	})
+}
+
+func (ws *WebServer) reorderWatchedStocks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req ReorderStocksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	symbols := make([]string, 0, len(req.Symbols))
+	for _, symbol := range req.Symbols {
+		symbols = append(symbols, strings.ToUpper(symbol))
+	}
+
+	if err := ws.collector.database.SetWatchedStockOrder(ctx, symbols); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Watchlist order updated successfully"})
+}
+
+func (ws *WebServer) removeWatchedStock(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
+		return
+	}
+
+	if err := ws.collector.database.RemoveWatchedStock(ctx, symbol); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := invalidateWatchedSearch(ctx, ws.collector.cache, TenantFromContext(ctx)); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock removed successfully"})
+}
+
+func (ws *WebServer) getStockSummary(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
+		return
+	}
+
+	// Range and bucket size, defaulting to 30 days of daily data
+	days := 30
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	granularity := c.DefaultQuery("granularity", GranularityDaily)
+	if !isValidGranularity(granularity) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid granularity, expected daily, weekly or monthly"})
+		return
+	}
+
+	unit, ok := parseDayUnit(c)
+	if !ok {
+		return
+	}
+	if unit == DayUnitTrading {
+		days = tradingToCalendarDays(time.Now(), days)
+	}
+
+	loc, ok := parseTimeZone(c)
+	if !ok {
+		return
+	}
+
+	// A stale answer is built fresh and not cached, so the summary cached
+	// once the background sync lands is the current one
+	stale := ws.refreshIfStale(ctx, symbol)
+
+	// The name and currency come from the tenant's watchlist entry, so each
+	// tenant gets its own cache entry
+	variant := fmt.Sprintf("%d:%s:%s:%s", days, granularity, loc, TenantFromContext(ctx))
+	cacheKey := symbolCacheKey("summary", symbol, variant)
+	if !stale && ws.serveCached(c, cacheKey) {
+		return
+	}
+
+	summary, err := ws.buildStockSummary(ctx, symbol, days, granularity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	summary.DailyData = summariesInLocation(summary.DailyData, loc)
+	summary.LastUpdate = summary.LastUpdate.In(loc)
+	summary.Timezone = loc.String()
+
+	if stale {
+		summary.Stale = true
+		c.JSON(http.StatusOK, summary)
+		return
+	}
+	ws.respondCached(c, cacheKey, summary)
+}
+
+// staleRefreshTimeout bounds a background sync started by refreshIfStale
+const staleRefreshTimeout = 2 * time.Minute
+
+// refreshIfStale reports whether symbol's latest stored bar is older than
+// RefreshStaleAfter while the US market is open, and if so starts a background
+// sync of it when it is watched. Syncs take the symbol's lock without waiting
+// and one is started per symbol at most once every RefreshStaleAfter, so a
+// burst of requests for a stale symbol triggers a single sync.
+func (ws *WebServer) refreshIfStale(ctx context.Context, symbol string) bool {
+	threshold := ws.config.RefreshStaleAfter
+	now := ws.now()
+	if threshold <= 0 || !IsMarketOpen(now) {
+		return false
+	}
+
+	latest, err := ws.collector.database.GetLatestTimestamp(ctx, symbol)
+	if err != nil || latest.IsZero() || now.Sub(latest) <= threshold {
+		return false
+	}
+
+	isWatched, err := ws.collector.database.IsWatched(ctx, symbol)
+	if err != nil || !isWatched {
+		return true
+	}
+	if last, ok := ws.staleRefreshes.Load(symbol); ok && now.Sub(last.(time.Time)) < threshold {
+		return true
+	}
+	ws.staleRefreshes.Store(symbol, now)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), staleRefreshTimeout)
+		defer cancel()
+
+		err := ws.collector.TryCollectHistoricalData(ctx, symbol, 1, 0)
+		if errors.Is(err, ErrSyncInProgress) {
+			return
+		}
+		if err != nil {
+			log.Printf("Background refresh of stale %s failed: %v", symbol, err)
+			return
+		}
+		if err := ws.collector.database.UpdateLastSync(ctx, symbol); err != nil {
+			log.Printf("Warning: failed to update last sync time for %s: %v", symbol, err)
+		}
+	}()
+	return true
+}
+
+// buildStockSummary assembles the summary for symbol: name and currency from
+// the watchlist, the last N days of bars at granularity, and the latest price
+// with its change against the previous period's close
+func (ws *WebServer) buildStockSummary(ctx context.Context, symbol string, days int, granularity string) (StockSummary, error) {
+	// Look up the stock's name and currency
+	stock, err := ws.collector.database.GetWatchedStock(ctx, symbol)
+	if err != nil {
+		return StockSummary{}, err
+	}
+
+	var stockName string
+	currency := defaultCurrency
+	if stock != nil {
+		stockName = stock.Name
+		if stock.Currency != "" {
+			currency = stock.Currency
+		}
+	}
+
+	var dailyData []DailySummaryAPI
+	switch granularity {
+	case GranularityDaily:
+		dailyData, err = ws.collector.database.GetDailySummary(ctx, symbol, days)
+	case GranularityWeekly:
+		dailyData, err = ws.collector.database.GetWeeklySummary(ctx, symbol, days)
+	case GranularityMonthly:
+		dailyData, err = ws.collector.database.GetMonthlySummary(ctx, symbol, days)
+	default:
+		return StockSummary{}, fmt.Errorf("invalid granularity %q", granularity)
+	}
+	if err != nil {
+		return StockSummary{}, err
+	}
+
+	// Get latest price
+	currentPrice, lastUpdate, err := ws.collector.database.GetLatestPrice(ctx, symbol)
+	if err != nil {
+		// If no price data, return just the daily data
+		return StockSummary{
+			Symbol:    symbol,
+			Name:      stockName,
+			Currency:  currency,
+			DailyData: dailyData,
+			IsActive:  true,
+		}, nil
+	}
+
+	// Calculate change from previous period's close (day or week)
+	var change float64
+	var changePercent float64
+	if len(dailyData) > 0 {
+		previousClose := dailyData[0].Close // Most recent period
+		if len(dailyData) > 1 {
+			previousClose = dailyData[1].Close // Previous period
+		}
+		change = currentPrice - previousClose
+		changePercent = safeDiv(change, previousClose) * 100
+	}
+
+	return StockSummary{
+		Symbol:        symbol,
+		Name:          stockName,
+		Currency:      currency,
+		CurrentPrice:  currentPrice,
+		Change:        change,
+		ChangePercent: changePercent,
+		LastUpdate:    lastUpdate,
+		DailyData:     dailyData,
+		IsActive:      true,
+	}, nil
+}
+
+func (ws *WebServer) getStockData(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	symbol := strings.ToUpper(c.Param("symbol"))
+	days := 30
+
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		if d, err := parseDays(daysQuery); err == nil {
+			days = d
+		}
+	}
+
+	unit, ok := parseDayUnit(c)
+	if !ok {
+		return
+	}
+
+	// ?extendedHours=false drops pre/post-market bars
+	extendedHours := c.Query("extendedHours") != "false"
+
+	loc, ok := parseTimeZone(c)
+	if !ok {
+		return
+	}
+
+	variant := strconv.Itoa(days)
+	if unit == DayUnitTrading {
+		variant += ":trading"
+	}
+	if !extendedHours {
+		variant += ":regular"
+	}
+	variant += ":" + loc.String()
+
+	cacheKey := symbolCacheKey("data", symbol, variant)
+	if ws.serveCached(c, cacheKey) {
+		return
+	}
+
+	startTime := time.Now().AddDate(0, 0, -days)
+	if unit == DayUnitTrading {
+		startTime = tradingDaysStart(time.Now(), days)
+	}
+
+	bars, err := ws.collector.GetDataSince(ctx, symbol, startTime)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+	if !extendedHours {
+		bars = regularSessionOnly(bars)
+	}
+
+	currency := defaultCurrency
+	if len(bars) > 0 && bars[len(bars)-1].Currency != "" {
+		currency = bars[len(bars)-1].Currency
+	}
+
+	ws.respondCached(c, cacheKey, gin.H{
+		"symbol":   symbol,
+		"currency": currency,
+		"timezone": loc.String(),
+		"days":     days,
+		"unit":     unit,
+		"count":    len(bars),
+		"data":     barsInLocation(bars, loc),
+	})
+}
+
+// getLatestBar returns the most recent stored minute bar with its full OHLCV
+func (ws *WebServer) getLatestBar(c *gin.Context) {
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
+		return
+	}
+
+	loc, ok := parseTimeZone(c)
+	if !ok {
+		return
+	}
+
+	bar, err := ws.collector.database.GetLatestBar(c.Request.Context(), symbol)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No data found for " + symbol})
+			return
+		}
+		respondServerError(c, err)
+		return
+	}
+	bar.Timestamp = bar.Timestamp.In(loc)
+
+	c.JSON(http.StatusOK, bar)
+}
+
+// maxRangeSpan caps how much minute data one /range request may cover
+const maxRangeSpan = 31 * 24 * time.Hour
+
+// getStockRange returns the stored minute bars between the RFC3339 start and
+// end timestamps (both inclusive), e.g. for zooming a chart
+func (ws *WebServer) getStockRange(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, c.Query("start"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start must be an RFC3339 timestamp, e.g. 2024-01-02T09:30:00Z"})
+		return
+	}
+	end, err := time.Parse(time.RFC3339, c.Query("end"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end must be an RFC3339 timestamp, e.g. 2024-01-05T16:00:00Z"})
+		return
+	}
+	if !end.After(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end must be after start"})
+		return
+	}
+	if end.Sub(start) > maxRangeSpan {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Range must not exceed %d days", int(maxRangeSpan.Hours()/24))})
+		return
+	}
+
+	loc, ok := parseTimeZone(c)
+	if !ok {
+		return
+	}
+
+	bars, err := ws.collector.database.GetMinuteData(ctx, symbol, start, end)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	// ?extendedHours=false drops pre/post-market bars
+	if c.Query("extendedHours") == "false" {
+		bars = regularSessionOnly(bars)
+	}
+
+	currency := defaultCurrency
+	if len(bars) > 0 && bars[len(bars)-1].Currency != "" {
+		currency = bars[len(bars)-1].Currency
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"symbol":   symbol,
+		"currency": currency,
+		"timezone": loc.String(),
+		"start":    start.In(loc),
+		"end":      end.In(loc),
+		"count":    len(bars),
+		"data":     barsInLocation(bars, loc),
+	})
+}
+
+// getOHLC returns bars as column arrays, oldest first. Daily, weekly and
+// monthly bars come from the summary tables; minute bars from raw data.
+func (ws *WebServer) getOHLC(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
+		return
+	}
+
+	days := 90
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	granularity := c.DefaultQuery("granularity", GranularityDaily)
+	series := OHLCSeries{
+		Symbol:      symbol,
+		Granularity: granularity,
+		T:           []int64{},
+		O:           []float64{},
+		H:           []float64{},
+		L:           []float64{},
+		C:           []float64{},
+		V:           []int64{},
+	}
+
+	if granularity == GranularityMinute {
+		bars, err := ws.collector.GetDataForAnalysis(ctx, symbol, days)
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+		for _, bar := range bars {
+			series.T = append(series.T, bar.Timestamp.Unix())
+			series.O = append(series.O, bar.Open)
+			series.H = append(series.H, bar.High)
+			series.L = append(series.L, bar.Low)
+			series.C = append(series.C, bar.Close)
+			series.V = append(series.V, bar.Volume)
+		}
+		c.JSON(http.StatusOK, series)
+		return
+	}
+
+	var summaries []DailySummaryAPI
+	var err error
+	switch granularity {
+	case GranularityDaily:
+		summaries, err = ws.collector.database.GetDailySummary(ctx, symbol, days)
+	case GranularityWeekly:
+		summaries, err = ws.collector.database.GetWeeklySummary(ctx, symbol, days)
+	case GranularityMonthly:
+		summaries, err = ws.collector.database.GetMonthlySummary(ctx, symbol, days)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid granularity, expected minute, daily, weekly or monthly"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Summaries are newest first
+	for i := len(summaries) - 1; i >= 0; i-- {
+		bar := summaries[i]
+		series.T = append(series.T, bar.Date.Unix())
+		series.O = append(series.O, bar.Open)
+		series.H = append(series.H, bar.High)
+		series.L = append(series.L, bar.Low)
+		series.C = append(series.C, bar.Close)
+		series.V = append(series.V, bar.Volume)
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// exportStockData downloads the last N days of minute bars as CSV, TSV, JSON,
+// JSON lines or Parquet. All but JSON are streamed from the database a row at
+// a time; Parquet is written to a temp file first so a failed write can still
+// be reported as an error response.
+func (ws *WebServer) exportStockData(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if !isValidSymbol(symbol) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid symbol format"})
+		return
+	}
+
+	days := 30
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", ExportFormatCSV))
+	switch format {
+	case ExportFormatCSV, ExportFormatTSV, ExportFormatJSON, ExportFormatJSONL, ExportFormatParquet:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected csv, tsv, json, jsonl or parquet"})
+		return
+	}
+
+	// The route isn't wrapped in timeoutMiddleware: a deadline hit while
+	// streaming would cut the body short after a 200 had already gone out.
+	// json and parquet are built before anything is written, so they get the
+	// request timeout here; the streamed formats run as long as rows arrive.
+	if format == ExportFormatJSON || format == ExportFormatParquet {
+		if ws.config.RequestTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, ws.config.RequestTimeout)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+	} else if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Warning: failed to clear write deadline for %s export: %v", symbol, err)
+	}
+
+	end := time.Now()
+	source := BarSource(func(fn func(MinuteBar) error) error {
+		return ws.collector.database.StreamMinuteData(ctx, symbol, end.AddDate(0, 0, -days), end, fn)
+	})
+
+	filename := fmt.Sprintf("%s_%dd.%s", symbol, days, format)
+	switch format {
+	case ExportFormatJSON:
+		bars, err := ws.collector.GetDataForAnalysis(ctx, symbol, days)
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.JSON(http.StatusOK, bars)
+
+	case ExportFormatJSONL:
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+
+		// One object per line; Encode terminates each with a newline
+		enc := json.NewEncoder(c.Writer)
+		err := source(func(bar MinuteBar) error {
+			return enc.Encode(bar)
+		})
+		if err != nil {
+			log.Printf("Warning: export of %s interrupted: %v", symbol, err)
+		}
+
+	case ExportFormatParquet:
+		file, err := os.CreateTemp("", "export-*.parquet")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer os.Remove(file.Name())
+
+		err = writeParquet(file, source)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+		c.FileAttachment(file.Name(), filename)
+
+	default:
+		comma, contentType := ',', "text/csv; charset=utf-8"
+		if format == ExportFormatTSV {
+			comma, contentType = '\t', "text/tab-separated-values; charset=utf-8"
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Header("Content-Type", contentType)
+		c.Status(http.StatusOK)
+		if err := writeDelimited(c.Writer, source, comma); err != nil {
+			log.Printf("Warning: export of %s interrupted: %v", symbol, err)
+		}
+	}
+}
+
+func (ws *WebServer) syncStockData(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	symbol, aliased := ws.aliases.Resolve(c.Param("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
+		return
+	}
+
+	// Check if stock is being watched
+	isWatched, err := ws.collector.database.IsWatched(ctx, symbol)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	// With ?autoAdd=true an unwatched symbol is added first, provided Yahoo
+	// recognises it
+	added := false
+	if !isWatched {
+		if c.Query("autoAdd") != "true" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found in watchlist"})
+			return
+		}
+		if !isValidSymbol(symbol) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock symbol"})
+			return
+		}
+		if err := ws.autoAddStock(ctx, symbol); err != nil {
+			ws.respondSyncError(c, symbol, err)
+			return
+		}
+		added = true
+	}
+
+	initialDays, ok := parseInitialDays(c)
+	if !ok {
+		return
+	}
+
+	// Sync data (initial window the first time, then incremental)
+	days, err := ws.collector.database.DaysToFetch(ctx, symbol, ws.collector.initialWindow(initialDays), time.Now())
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	err = ws.collector.TryCollectHistoricalData(ctx, symbol, initialDays, syncLockWait)
+	if err != nil {
+		ws.respondSyncError(c, symbol, err)
+		return
+	}
+
+	// Update last sync time
+	if err := ws.collector.database.UpdateLastSync(ctx, symbol); err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	// Get latest timestamp after sync
+	latestTimestamp, _ := ws.collector.database.GetLatestTimestamp(ctx, symbol)
+
+	response := SyncResponse{
+		Success:     true,
+		Message:     "Data synchronized successfully",
+		RecordsAdded: days,
+		LatestDate:  latestTimestamp.Format("2006-01-02 15:04:05"),
+		Added:       added,
+	}
+	if aliased {
+		response.Symbol = symbol
+		response.ResolvedFrom = c.Param("symbol")
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// streamSyncStockData runs the same sync as syncStockData but streams
+// server-sent events as Yahoo batches complete: "progress" per batch,
+// then "done" with the latest date or "error" on failure.
+func (ws *WebServer) streamSyncStockData(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	requested := c.Param("symbol")
+	symbol, aliased := ws.aliases.Resolve(requested)
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
+		return
+	}
+
+	isWatched, err := ws.collector.database.IsWatched(ctx, symbol)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !isWatched {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found in watchlist"})
+		return
+	}
+
+	initialDays, ok := parseInitialDays(c)
+	if !ok {
+		return
+	}
+
+	// The stream runs as long as the sync does, so it can't share the
+	// server-wide write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Warning: failed to clear write deadline for %s stream: %v", symbol, err)
+	}
+
+	type sseEvent struct {
+		name string
+		data interface{}
+	}
+	events := make(chan sseEvent)
+
+	// send gives up if the client disconnects so the sync goroutine never blocks
+	send := func(name string, data interface{}) {
+		select {
+		case events <- sseEvent{name: name, data: data}:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(events)
+
+		// The collector switches to an incremental fetch when data already exists
+		err := ws.collector.TryCollectHistoricalData(ctx, symbol, initialDays, syncLockWait, func(batch, totalBatches, barsSoFar int) {
+			send("progress", gin.H{
+				"batch":        batch,
+				"totalBatches": totalBatches,
+				"bars":         barsSoFar,
+			})
+		})
+		if err != nil {
+			send("error", gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := ws.collector.database.UpdateLastSync(ctx, symbol); err != nil {
+			send("error", gin.H{"error": err.Error()})
+			return
+		}
+
+		latestTimestamp, _ := ws.collector.database.GetLatestTimestamp(ctx, symbol)
+		done := gin.H{
+			"message":    "Data synchronized successfully",
+			"latestDate": latestTimestamp.Format("2006-01-02 15:04:05"),
+		}
+		if aliased {
+			done["symbol"] = symbol
+			done["resolvedFrom"] = requested
+		}
+		send("done", done)
+	}()
+
+	c.Stream(func(w io.Writer) bool {
+		event, ok := <-events
+		if !ok {
+			return false
+		}
+		c.SSEvent(event.name, event.data)
+		return true
+	})
+}
+
+// parseDayUnit reads ?unit=calendar|trading, defaulting to calendar. On an
+// invalid value it writes a 400 and returns false.
+func parseDayUnit(c *gin.Context) (string, bool) {
+	unit := c.DefaultQuery("unit", DayUnitCalendar)
+	if unit != DayUnitCalendar && unit != DayUnitTrading {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unit, expected calendar or trading"})
+		return "", false
+	}
+	return unit, true
+}
+
+// parseTimeZone reads the optional ?tz= IANA zone API times are expressed in,
+// defaulting to UTC. On an unknown zone it writes a 400 and returns false.
+func parseTimeZone(c *gin.Context) (*time.Location, bool) {
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown time zone %q", c.Query("tz"))})
+		return nil, false
+	}
+	return loc, true
+}
+
+// barsInLocation returns a copy of bars with timestamps expressed in loc
+func barsInLocation(bars []MinuteBar, loc *time.Location) []MinuteBar {
+	converted := make([]MinuteBar, len(bars))
+	for i, bar := range bars {
+		bar.Timestamp = bar.Timestamp.In(loc)
+		converted[i] = bar
+	}
+	return converted
+}
+
+// summariesInLocation returns a copy of summaries whose dates are midnight of
+// the same market date in loc. Dates are calendar days, so they are relabelled
+// rather than converted, which would move them to the previous evening.
+func summariesInLocation(summaries []DailySummaryAPI, loc *time.Location) []DailySummaryAPI {
+	converted := make([]DailySummaryAPI, len(summaries))
+	for i, summary := range summaries {
+		year, month, day := summary.Date.Date()
+		summary.Date = time.Date(year, month, day, 0, 0, 0, 0, loc)
+		summary.CreateAt = summary.CreateAt.In(loc)
+		converted[i] = summary
+	}
+	return converted
+}
+
+// isValidPrecision reports whether an optional price precision is in range
+func isValidPrecision(precision *int) bool {
+	return precision == nil || (*precision >= 0 && *precision <= priceDecimals)
+}
+
+// parseInitialDays reads the optional ?initialDays= first-collection window,
+// returning 0 when absent so the configured default applies. On an invalid
+// value it writes a 400 and returns false.
+func parseInitialDays(c *gin.Context) (int, bool) {
+	query := c.Query("initialDays")
+	if query == "" {
+		return 0, true
+	}
+
+	days, err := strconv.Atoi(query)
+	if err != nil || days < 1 || days > maxInitialDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("initialDays must be between 1 and %d", maxInitialDays)})
+		return 0, false
+	}
+	return days, true
+}
+
+// syncLockWait is how long a manual sync waits for a running collection of the
+// same symbol (e.g. the scheduler's) before answering 409
+const syncLockWait = 2 * time.Second
+
+// maxSymbolSuggestions caps the close matches offered for an unknown symbol
+const maxSymbolSuggestions = 5
+
+// respondSyncError is respondServerError for a failed sync, adding bundled
+// tickers close to symbol when Yahoo says it doesn't exist. Transient failures
+// (timeouts, rate limits, outages) get no suggestions.
+func (ws *WebServer) respondSyncError(c *gin.Context, symbol string, err error) {
+	if !errors.Is(err, ErrInvalidSymbol) || ws.search == nil || c.Request.Context().Err() != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{
+		"error":       err.Error(),
+		"suggestions": ws.search.Suggest(symbol, maxSymbolSuggestions),
+	})
+}
+
+// autoAddStock adds symbol to the watchlist for a sync with autoAdd. Unlike
+// addWatchedStock the metadata lookup must succeed, since it is what confirms
+// Yahoo has data for the symbol.
+func (ws *WebServer) autoAddStock(ctx context.Context, symbol string) error {
+	meta, err := ws.collector.yahooClient.GetQuoteMeta(ctx, symbol)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ws.collector.database.AddWatchedStock(ctx, symbol, meta.Name); err != nil {
+		return err
+	}
+
+	if err := ws.collector.database.UpdateWatchedStockMeta(ctx, symbol, meta); err != nil {
+		log.Printf("Warning: failed to store metadata for %s: %v", symbol, err)
+	}
+	return nil
+}
+
+func (ws *WebServer) runBacktest(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req BacktestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Defaults for omitted parameters
+	if req.Fast == 0 {
+		req.Fast = 10
+	}
+	if req.Slow == 0 {
+		req.Slow = 30
+	}
+	if req.Days == 0 {
+		req.Days = 365
+	}
+	if req.InitialCash == 0 {
+		req.InitialCash = 10000
+	}
+
+	if req.Fast < 1 || req.Slow <= req.Fast {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fast must be positive and less than slow"})
+		return
+	}
+	if req.Days < 1 || req.InitialCash < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days and initialCash must be positive"})
+		return
+	}
+
+	symbol := strings.ToUpper(req.Symbol)
+	bars, err := ws.collector.database.GetDailySummary(ctx, symbol, req.Days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(bars) <= req.Slow {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": fmt.Sprintf("Insufficient data: need more than %d daily bars, have %d", req.Slow, len(bars)),
+		})
+		return
+	}
+
+	result := Backtest(bars, BacktestParams{
+		Fast:        req.Fast,
+		Slow:        req.Slow,
+		InitialCash: req.InitialCash,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"symbol": symbol,
+		"fast":   req.Fast,
+		"slow":   req.Slow,
+		"days":   req.Days,
+		"bars":   len(bars),
+		"result": result,
+	})
+}
+
+func (ws *WebServer) getVolatility(c *gin.Context) {
+	ctx := c.Request.Context()
+	symbol := strings.ToUpper(c.Param("symbol"))
+
+	window := 20
+	if windowQuery := c.Query("window"); windowQuery != "" {
+		w, err := parseDays(windowQuery)
+		if err != nil || w < 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "window must be at least 2"})
+			return
+		}
+		window = w
+	}
+
+	days := 180
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	dailyData, err := ws.collector.database.GetDailySummary(ctx, symbol, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Daily summaries come newest first; the calculation needs oldest first
+	closes := make([]float64, len(dailyData))
+	for i, day := range dailyData {
+		closes[len(dailyData)-1-i] = day.Close
+	}
+	volatility := AnnualizedVolatility(closes, window, ws.tradingDaysPerYear())
+
+	series := []gin.H{}
+	for i := window; i < len(closes); i++ {
+		series = append(series, gin.H{
+			"date":       dailyData[len(dailyData)-1-i].Date.Format("2006-01-02"),
+			"volatility": roundToDecimal(volatility[i], 4),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"symbol": symbol,
+		"window": window,
+		"days":   days,
+		"data":   series,
+	})
+}
+
+// getRiskMetrics reports a symbol's annualized return and volatility, Sharpe
+// ratio against ?riskFree= (an annual rate, default 0) and max drawdown over
+// the last ?days=252 of daily closes
+func (ws *WebServer) getRiskMetrics(c *gin.Context) {
+	ctx := c.Request.Context()
+	symbol := strings.ToUpper(c.Param("symbol"))
+
+	days := 252
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	var riskFree float64
+	if rfQuery := c.Query("riskFree"); rfQuery != "" {
+		rf, err := strconv.ParseFloat(rfQuery, 64)
+		if err != nil || !isFinite(rf) || rf < -1 || rf > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "riskFree must be an annual rate between -1 and 1, e.g. 0.04"})
+			return
+		}
+		riskFree = rf
+	}
+
+	dailyData, err := ws.collector.database.GetDailySummary(ctx, symbol, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Daily summaries come newest first; the calculation needs oldest first
+	closes := make([]float64, len(dailyData))
+	for i, day := range dailyData {
+		closes[len(dailyData)-1-i] = day.Close
+	}
+
+	returns := DailyReturns(closes)
+	if len(returns) < 2 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": fmt.Sprintf("Insufficient data: need at least 3 daily closes, have %d", len(closes)),
+		})
+		return
+	}
+
+	tradingDays := ws.tradingDaysPerYear()
+	_, sd := meanStdDev(returns)
+
+	c.JSON(http.StatusOK, gin.H{
+		"symbol":               symbol,
+		"days":                 days,
+		"observations":         len(closes),
+		"riskFree":             riskFree,
+		"annualizedReturn":     roundToDecimal(AnnualizedReturn(closes, tradingDays), 4),
+		"annualizedVolatility": roundToDecimal(sd*math.Sqrt(float64(tradingDays)), 4),
+		"sharpeRatio":          roundToDecimal(SharpeRatio(returns, riskFree, tradingDays), 4),
+		"maxDrawdown":          roundToDecimal(MaxDrawdown(closes), 4),
+	})
+}
+
+// getBeta reports a symbol's beta against the ?index=SPY benchmark from the
+// daily returns of the last ?days=252, over the dates both have closes for.
+// The benchmark is read from stored summaries like any symbol, so it has to be
+// collected too.
+func (ws *WebServer) getBeta(c *gin.Context) {
+	ctx := c.Request.Context()
+	symbol := strings.ToUpper(c.Param("symbol"))
+
+	index := strings.ToUpper(c.DefaultQuery("index", "SPY"))
+	if !isValidSymbol(index) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid index symbol"})
+		return
+	}
+
+	days := 252
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	summaries, err := ws.collector.database.GetDailySummaryMulti(ctx, []string{symbol, index}, days)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+	if len(summaries[index]) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("No data collected for index %s; add it to the watchlist and sync it first", index),
+		})
+		return
+	}
+
+	dates, closes := alignDailyCloses(summaries)
+	stockReturns, indexReturns := pairedReturns(closes[symbol], closes[index])
+	if len(stockReturns) < 2 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": fmt.Sprintf("Insufficient data: need at least 3 daily closes shared with %s, have %d", index, len(dates)),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"symbol":       symbol,
+		"index":        index,
+		"days":         days,
+		"observations": len(stockReturns),
+		"startDate":    dates[0].Format("2006-01-02"),
+		"endDate":      dates[len(dates)-1].Format("2006-01-02"),
+		"beta":         roundToDecimal(Beta(stockReturns, indexReturns), 4),
+	})
+}
+
+// getSeasonality breaks a symbol's daily returns over the last ?days=365 down
+// by weekday, and with ?byMonth=true by calendar month too
+func (ws *WebServer) getSeasonality(c *gin.Context) {
+	ctx := c.Request.Context()
+	symbol := strings.ToUpper(c.Param("symbol"))
+
+	days := 365
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	dailyData, err := ws.collector.database.GetDailySummary(ctx, symbol, days)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Convert GORM models to API models
-	var apiStocks []WatchedStockAPI
-	for _, stock := range stocks {
-		apiStocks = append(apiStocks, WatchedStockAPI{
-			ID:       int(stock.ID),
-			Symbol:   stock.Symbol,
-			Name:     stock.Name,
-			AddedAt:  stock.AddedAt,
-			LastSync: stock.LastSync,
-			IsActive: stock.IsActive,
-		})
+	response := gin.H{
+		"symbol":   symbol,
+		"days":     days,
+		"weekdays": weekdaySeasonality(dailyData),
+	}
+	if c.Query("byMonth") == "true" {
+		response["months"] = monthSeasonality(dailyData)
 	}
+	c.JSON(http.StatusOK, response)
+}
 
-	c.JSON(http.StatusOK, apiStocks)
+// tradingDaysPerYear returns the configured trading days per year used to
+// annualize daily statistics, or the US equity default
+func (ws *WebServer) tradingDaysPerYear() int {
+	if ws.config.TradingDaysPerYear > 0 {
+		return ws.config.TradingDaysPerYear
+	}
+	return defaultTradingDaysPerYear
 }
 
-func (ws *WebServer) addWatchedStock(c *gin.Context) {
-	var req AddStockRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// Indicator types served by /stocks/:symbol/indicators
+const IndicatorATR = "atr"
+
+// getIndicator computes an indicator over daily summaries, ?type=atr (Average
+// True Range) for now, with ?period=14 over the last ?days=180
+func (ws *WebServer) getIndicator(c *gin.Context) {
+	ctx := c.Request.Context()
+	symbol := strings.ToUpper(c.Param("symbol"))
+
+	indicator := c.Query("type")
+	if indicator != IndicatorATR {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type, expected atr"})
 		return
 	}
 
-	symbol := strings.ToUpper(req.Symbol)
-	if !isValidSymbol(symbol) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock symbol"})
-		return
+	period := 14
+	if periodQuery := c.Query("period"); periodQuery != "" {
+		p, err := strconv.Atoi(periodQuery)
+		if err != nil || p < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "period must be a positive number"})
+			return
+		}
+		period = p
+	}
+
+	days := 180
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
 	}
 
-	// Add to watched stocks
-	if err := ws.collector.database.AddWatchedStock(symbol, req.Name); err != nil {
+	dailyData, err := ws.collector.database.GetDailySummary(ctx, symbol, days)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Daily summaries come newest first; the calculation needs oldest first
+	n := len(dailyData)
+	highs, lows, closes := make([]float64, n), make([]float64, n), make([]float64, n)
+	for i, day := range dailyData {
+		highs[n-1-i], lows[n-1-i], closes[n-1-i] = day.High, day.Low, day.Close
+	}
+	values := ATR(highs, lows, closes, period)
+
+	series := []gin.H{}
+	for i := period - 1; i < n; i++ {
+		series = append(series, gin.H{
+			"date":  dailyData[n-1-i].Date.Format("2006-01-02"),
+			"value": roundToDecimal(values[i], 4),
+		})
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Stock added successfully",
-		"symbol":  symbol,
+		"symbol": symbol,
+		"type":   indicator,
+		"period": period,
+		"days":   days,
+		"data":   series,
 	})
 }
 
-func (ws *WebServer) removeWatchedStock(c *gin.Context) {
+// getVolumeAnomalies flags days in the last N whose volume is more than zscore
+// standard deviations above the mean of the window trading days before them
+func (ws *WebServer) getVolumeAnomalies(c *gin.Context) {
+	ctx := c.Request.Context()
 	symbol := strings.ToUpper(c.Param("symbol"))
-	if symbol == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
-		return
+
+	days := 90
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	window := 20
+	if windowQuery := c.Query("window"); windowQuery != "" {
+		w, err := parseDays(windowQuery)
+		if err != nil || w < 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "window must be at least 2"})
+			return
+		}
+		window = w
 	}
 
-	if err := ws.collector.database.RemoveWatchedStock(symbol); err != nil {
+	threshold := 2.0
+	if zQuery := c.Query("zscore"); zQuery != "" {
+		z, err := strconv.ParseFloat(zQuery, 64)
+		if err != nil || z <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "zscore must be a positive number"})
+			return
+		}
+		threshold = z
+	}
+
+	// Load enough days before the range for the first day's trailing window
+	dailyData, err := ws.collector.database.GetDailySummary(ctx, symbol, days+2*window)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Stock removed successfully"})
+	// Daily summaries come newest first; the calculation needs oldest first
+	volumes := make([]float64, len(dailyData))
+	for i, day := range dailyData {
+		volumes[len(dailyData)-1-i] = float64(day.Volume)
+	}
+	zscores := RollingZScore(volumes, window)
+
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	anomalies := []gin.H{}
+	for i := window; i < len(volumes); i++ {
+		day := dailyData[len(dailyData)-1-i]
+		if day.Date.Before(since) || zscores[i] <= threshold {
+			continue
+		}
+		anomalies = append(anomalies, gin.H{
+			"date":   day.Date.Format("2006-01-02"),
+			"volume": day.Volume,
+			"zscore": roundToDecimal(zscores[i], 2),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"symbol":    symbol,
+		"days":      days,
+		"window":    window,
+		"threshold": threshold,
+		"data":      anomalies,
+	})
 }
 
-func (ws *WebServer) getStockSummary(c *gin.Context) {
-	symbol := strings.ToUpper(c.Param("symbol"))
-	if symbol == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
+// maxCompareSymbols caps how many series a single compare request can return
+const maxCompareSymbols = 10
+
+func (ws *WebServer) compareStocks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	symbols := parseSymbolList(c.Query("symbols"))
+	if len(symbols) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'symbols' is required"})
+		return
+	}
+	if len(symbols) > maxCompareSymbols {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d symbols can be compared", maxCompareSymbols)})
 		return
 	}
 
-	// Get watched stocks to find stock name
-	watchedStocks, err := ws.collector.database.GetWatchedStocks()
+	days := 90
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	base := 100.0
+	if baseQuery := c.Query("base"); baseQuery != "" {
+		b, err := strconv.ParseFloat(baseQuery, 64)
+		if err != nil || b <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid base parameter"})
+			return
+		}
+		base = b
+	}
+
+	summaries, err := ws.collector.database.GetDailySummaryMulti(ctx, symbols, days)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	var stockName string
-	for _, stock := range watchedStocks {
-		if stock.Symbol == symbol {
-			stockName = stock.Name
-			break
+	// Rebase each symbol's closes over the dates they all share
+	dates, closes := alignDailyCloses(summaries)
+	series := make(map[string][]ComparePoint)
+	for _, symbol := range symbols {
+		points := []ComparePoint{}
+		for i, value := range Rebase(closes[symbol], base) {
+			points = append(points, ComparePoint{
+				Date:  dates[i].Format("2006-01-02"),
+				Value: roundToDecimal(value, 2),
+			})
 		}
+		series[symbol] = points
 	}
 
-	// Get daily summary for last 30 days
-	dailyData, err := ws.collector.database.GetDailySummary(symbol, 30)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	response := gin.H{
+		"symbols": symbols,
+		"days":    days,
+		"base":    base,
+		"series":  series,
+	}
+	if len(dates) > 0 {
+		response["startDate"] = dates[0].Format("2006-01-02")
+		response["endDate"] = dates[len(dates)-1].Format("2006-01-02")
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// maxSummarySymbols caps how many symbols a single bulk summary request can return
+const maxSummarySymbols = 50
+
+// getSummaries returns the daily summaries of several symbols keyed by symbol,
+// so multi-stock views need one request instead of one per symbol
+func (ws *WebServer) getSummaries(c *gin.Context) {
+	symbols := parseSymbolList(c.Query("symbols"))
+	if len(symbols) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'symbols' is required"})
+		return
+	}
+	if len(symbols) > maxSummarySymbols {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d symbols can be requested", maxSummarySymbols)})
 		return
 	}
 
-	// Get latest price
-	currentPrice, lastUpdate, err := ws.collector.database.GetLatestPrice(symbol)
+	days := 30
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	summaries, err := ws.collector.database.GetDailySummaryMulti(c.Request.Context(), symbols, days)
 	if err != nil {
-		// If no price data, return just the daily data
-		c.JSON(http.StatusOK, StockSummary{
-			Symbol:     symbol,
-			Name:       stockName,
-			DailyData:  dailyData,
-			IsActive:   true,
-		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Calculate change from previous day's close
-	var change float64
-	var changePercent float64
-	if len(dailyData) > 0 {
-		previousClose := dailyData[0].Close // Most recent day
-		if len(dailyData) > 1 {
-			previousClose = dailyData[1].Close // Previous day
+	c.JSON(http.StatusOK, summaries)
+}
+
+// parseSymbolList splits a comma-separated symbols parameter, upper-casing and
+// dropping blanks and duplicates while keeping the order given
+func parseSymbolList(raw string) []string {
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, symbol := range strings.Split(raw, ",") {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" || seen[symbol] {
+			continue
 		}
-		change = currentPrice - previousClose
-		if previousClose > 0 {
-			changePercent = (change / previousClose) * 100
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+func (ws *WebServer) getCorporateEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	symbol := strings.ToUpper(c.Param("symbol"))
+
+	// Optionally fetch fresh events before reading
+	if c.Query("refresh") == "true" {
+		events, err := ws.collector.yahooClient.GetEvents(ctx, symbol)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		if err := ws.collector.database.SaveCorporateEvents(ctx, events); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 	}
 
-	summary := StockSummary{
-		Symbol:        symbol,
-		Name:          stockName,
-		CurrentPrice:  currentPrice,
-		Change:        change,
-		ChangePercent: changePercent,
-		LastUpdate:    lastUpdate,
-		DailyData:     dailyData,
-		IsActive:      true,
+	events, err := ws.collector.database.GetCorporateEvents(ctx, symbol)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, summary)
+	c.JSON(http.StatusOK, gin.H{
+		"symbol": symbol,
+		"count":  len(events),
+		"events": events,
+	})
 }
 
-func (ws *WebServer) getStockData(c *gin.Context) {
+// maxRejectedBars caps the rows /stocks/:symbol/rejected returns
+const maxRejectedBars = 1000
+
+// getRejectedBars lists the bars the anomaly filters dropped for a symbol over
+// the last ?days=7, newest first, up to ?limit=100. Rejections are only
+// recorded with -quarantine-rejected.
+func (ws *WebServer) getRejectedBars(c *gin.Context) {
+	ctx := c.Request.Context()
 	symbol := strings.ToUpper(c.Param("symbol"))
-	days := 30
 
+	days := 7
 	if daysQuery := c.Query("days"); daysQuery != "" {
-		if d, err := parseDays(daysQuery); err == nil {
-			days = d
+		d, err := parseDays(daysQuery)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
+		}
+		days = d
+	}
+
+	limit := 100
+	if limitQuery := c.Query("limit"); limitQuery != "" {
+		l, err := strconv.Atoi(limitQuery)
+		if err != nil || l < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
 		}
+		limit = min(l, maxRejectedBars)
 	}
 
-	bars, err := ws.collector.GetDataForAnalysis(symbol, days)
+	bars, err := ws.collector.database.GetRejectedBars(ctx, symbol, time.Now().AddDate(0, 0, -days), limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondServerError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"symbol": symbol,
-		"days":   days,
-		"count":  len(bars),
-		"data":   bars,
+		"symbol":      symbol,
+		"days":        days,
+		"quarantined": ws.config.QuarantineRejected,
+		"count":       len(bars),
+		"rejected":    bars,
 	})
 }
 
-func (ws *WebServer) syncStockData(c *gin.Context) {
+// readinessTimeout bounds the database and Yahoo checks in readiness
+const readinessTimeout = 10 * time.Second
+
+// readiness reports whether the database and the Yahoo data provider are both
+// usable, returning 503 with the failing check's error otherwise
+func (ws *WebServer) readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	ready := true
+	database := gin.H{"ok": true}
+	if err := ws.collector.database.Ping(ctx); err != nil {
+		ready = false
+		database = gin.H{"ok": false, "error": err.Error()}
+	}
+
+	ping, err := ws.collector.yahooClient.Ping(ctx)
+	yahoo := gin.H{
+		"ok":         err == nil,
+		"host":       ping.Host,
+		"latencyMs":  ping.LatencyMs,
+		"crumbValid": ping.CrumbValid,
+	}
+	if err != nil {
+		ready = false
+		yahoo["error"] = err.Error()
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{
+		"ready":    ready,
+		"database": database,
+		"yahoo":    yahoo,
+		"build":    buildInfo(),
+	})
+}
+
+// getVersion reports the version, commit and build time baked in with
+// -ldflags, and the Go runtime version
+func (ws *WebServer) getVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, buildInfo())
+}
+
+// rebuildSummary regenerates a symbol's daily, weekly and monthly summaries
+// from its stored minute data
+func (ws *WebServer) rebuildSummary(c *gin.Context) {
+	ctx := c.Request.Context()
+
 	symbol := strings.ToUpper(c.Param("symbol"))
-	if symbol == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Symbol is required"})
+	if !isValidSymbol(symbol) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid symbol format"})
 		return
 	}
 
-	// Check if stock is being watched
-	watchedStocks, err := ws.collector.database.GetWatchedStocks()
+	start := time.Now()
+	if err := ws.collector.database.RebuildDailySummaries(ctx, symbol); err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Summaries rebuilt successfully",
+		"symbol":   symbol,
+		"duration": time.Since(start).String(),
+	})
+}
+
+func (ws *WebServer) vacuumDatabase(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// VACUUM rewrites the whole file, which on a large database takes longer
+	// than the server-wide write timeout allows
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Warning: failed to clear write deadline for vacuum: %v", err)
+	}
+
+	sizeBefore, err := ws.collector.database.FileSize()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	isWatched := false
-	for _, stock := range watchedStocks {
-		if stock.Symbol == symbol {
-			isWatched = true
-			break
-		}
+	start := time.Now()
+	if err := ws.collector.database.Vacuum(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	if !isWatched {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found in watchlist"})
+	sizeAfter, err := ws.collector.database.FileSize()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Sync data (30 days for initial, then incremental)
-	days := 30
-	latestTimestamp, _ := ws.collector.database.GetLatestTimestamp(symbol)
-	if !latestTimestamp.IsZero() {
-		// Calculate how many days we need to fetch
-		// Add 1 to ensure we re-fetch the last day completely (in case it was incomplete)
-		daysSinceLatest := int(time.Since(latestTimestamp).Hours()/24) + 1
-
-		// If the last data is very recent (less than 1 day old), check if it's a trading day
-		now := time.Now()
-		if daysSinceLatest == 1 {
-			// Check if we're on the same calendar day (in any timezone)
-			if latestTimestamp.Year() == now.Year() &&
-			   latestTimestamp.YearDay() == now.YearDay() {
-				// Same day - always re-fetch to ensure completeness
-				days = 1
-			} else {
-				// Different day - fetch since the day of latest data
-				days = daysSinceLatest
-			}
-		} else if daysSinceLatest <= 0 {
-			// This shouldn't happen with the +1 above, but keep as safety check
-			days = 1
-		} else {
-			days = daysSinceLatest
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Database vacuumed successfully",
+		"sizeBefore": sizeBefore,
+		"sizeAfter":  sizeAfter,
+		"duration":   time.Since(start).String(),
+	})
+}
+
+func (ws *WebServer) pruneMinuteData(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	symbol := strings.ToUpper(c.Query("symbol"))
+
+	// Default to the configured retention window
+	days := ws.config.RetentionDays
+	if daysQuery := c.Query("days"); daysQuery != "" {
+		d, err := parseDays(daysQuery)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+			return
 		}
+		days = d
+	}
+
+	if days <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Retention days must be positive"})
+		return
+	}
+
+	// Pruning years of minute data can outlast the server-wide write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Warning: failed to clear write deadline for prune: %v", err)
 	}
 
-	err = ws.collector.CollectHistoricalData(symbol, days)
+	cutoff := time.Now().AddDate(0, 0, -days)
+	deleted, err := ws.collector.database.PruneMinuteData(ctx, symbol, cutoff)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Update last sync time
-	if err := ws.collector.database.UpdateLastSync(symbol); err != nil {
+	if deleted > 0 {
+		if err := invalidateSymbol(ctx, ws.collector.cache, symbol); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Minute data pruned successfully",
+		"symbol":      symbol,
+		"olderThan":   cutoff.Format("2006-01-02 15:04:05"),
+		"rowsDeleted": deleted,
+	})
+}
+
+// writeJSONBody writes body, an encoded JSON response, with a 200 and a weak
+// ETag hashed from it. If the request's If-None-Match matches, it writes a 304
+// instead. Hashing the body itself means any change to the data behind it,
+// whichever path wrote it, changes the ETag.
+func writeJSONBody(c *gin.Context, body []byte) {
+	hash := fnv.New64a()
+	hash.Write(body)
+	etag := fmt.Sprintf(`W/"%x"`, hash.Sum64())
+	c.Header("ETag", etag)
+
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// serveCached writes the cached response for key and reports whether it did.
+// Cache errors are logged and treated as a miss.
+func (ws *WebServer) serveCached(c *gin.Context, key string) bool {
+	return ws.serveCachedFor(c, key, ws.config.CacheTTL)
+}
+
+// serveCachedFor is serveCached for responses cached with their own ttl
+// rather than CacheTTL; nothing is served when ttl disables caching
+func (ws *WebServer) serveCachedFor(c *gin.Context, key string, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+
+	body, ok, err := ws.collector.cache.Get(c.Request.Context(), key)
+	if err != nil {
+		log.Printf("Warning: cache get %s failed: %v", key, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	c.Header("X-Cache", "HIT")
+	writeJSONBody(c, body)
+	return true
+}
+
+// respondCached writes response as JSON with a 200 and stores it under key
+func (ws *WebServer) respondCached(c *gin.Context, key string, response interface{}) {
+	ws.respondCachedFor(c, key, response, ws.config.CacheTTL)
+}
+
+// respondCachedFor is respondCached with a TTL of its own instead of CacheTTL;
+// 0 stores nothing
+func (ws *WebServer) respondCachedFor(c *gin.Context, key string, response interface{}, ttl time.Duration) {
+	body, err := json.Marshal(response)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Get latest timestamp after sync
-	latestTimestamp, _ = ws.collector.database.GetLatestTimestamp(symbol)
-
-	response := SyncResponse{
-		Success:     true,
-		Message:     "Data synchronized successfully",
-		RecordsAdded: days,
-		LatestDate:  latestTimestamp.Format("2006-01-02 15:04:05"),
+	if ttl > 0 {
+		if err := ws.collector.cache.Set(c.Request.Context(), key, body, ttl); err != nil {
+			log.Printf("Warning: cache set %s failed: %v", key, err)
+		}
+		c.Header("X-Cache", "MISS")
 	}
+	writeJSONBody(c, body)
+}
 
-	c.JSON(http.StatusOK, response)
+// respondServerError writes a 504 if the request deadline expired while the
+// handler was working, a 404 or 429 for Yahoo symbol and rate-limit errors,
+// or a 500 with the error message otherwise
+func respondServerError(c *gin.Context, err error) {
+	if c.Request.Context().Err() == context.DeadlineExceeded {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+		return
+	}
+	if errors.Is(err, ErrInvalidSymbol) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrRateLimited) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrSyncInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 }
 
 func isValidSymbol(symbol string) bool {
@@ -264,6 +2115,12 @@ func parseDays(s string) (int, error) {
 	return days, err
 }
 
+// Result counts for /api/search: ?limit= defaults to 15 and is capped at 50
+const (
+	defaultSearchLimit = 15
+	maxSearchLimit     = 50
+)
+
 func (ws *WebServer) searchStocks(c *gin.Context) {
 	query := c.Query("q")
 	if query == "" {
@@ -271,22 +2128,66 @@ func (ws *WebServer) searchStocks(c *gin.Context) {
 		return
 	}
 
-	// 初始化搜索服务
-	searchService, err := NewStockSearchService()
-	if err != nil {
-		fmt.Printf("Error initializing search service: %v\n", err)
+	limit := defaultSearchLimit
+	if limitQuery := c.Query("limit"); limitQuery != "" {
+		l, err := strconv.Atoi(limitQuery)
+		if err != nil || l < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+		limit = min(l, maxSearchLimit)
+	}
+
+	// Very short queries match most of the list, so below the configured
+	// minimum answer with nothing rather than noise
+	minLength := max(ws.config.SearchMinLength, 1)
+	if utf8.RuneCountInString(strings.TrimSpace(query)) < minLength {
+		c.JSON(http.StatusOK, gin.H{
+			"query":   query,
+			"results": []StockSearchResult{},
+			"count":   0,
+			"message": fmt.Sprintf("Query must be at least %d characters", minLength),
+		})
+		return
+	}
+
+	// ?includeWatched=true also searches the watchlist, so tickers added
+	// outside the bundled CSV show up with their fetched names
+	includeWatched := c.Query("includeWatched") == "true"
+
+	cacheKey := fmt.Sprintf("search:%d:", limit) + strings.ToLower(query)
+	if includeWatched {
+		cacheKey = watchedSearchCacheKey(TenantFromContext(c.Request.Context()), fmt.Sprintf("%d:", limit)+strings.ToLower(query))
+	}
+	if ws.serveCached(c, cacheKey) {
+		return
+	}
+
+	searchService := ws.search
+	if searchService == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize search service"})
 		return
 	}
 
-	fmt.Printf("Search service initialized successfully, loaded %d stocks\n", len(searchService.stocks))
+	// 执行搜索，最多返回 limit 个结果
+	results, err := searchService.Search(c.Request.Context(), query, limit)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
 
-	// 执行搜索，最多返回15个结果
-	results := searchService.Search(query, 15)
+	if includeWatched {
+		watched, err := ws.collector.database.SearchWatchedStocks(c.Request.Context(), strings.TrimSpace(query), limit)
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+		results = searchService.mergeWatchedResults(watched, results, limit)
+	}
 
-	fmt.Printf("Search for '%s' returned %d results\n", query, len(results))
+	log.Printf("Search for '%s' returned %d results", query, len(results))
 
-	c.JSON(http.StatusOK, gin.H{
+	ws.respondCached(c, cacheKey, gin.H{
 		"query":   query,
 		"results": results,
 		"count":   len(results),
@@ -0,0 +1,742 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestWebServer builds a web server on a database in a temporary directory
+func newTestWebServer(t *testing.T, cfg Config) *WebServer {
+	t.Helper()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	ws, err := NewWebServer(cfg)
+	if err != nil {
+		t.Fatalf("NewWebServer: %v", err)
+	}
+	t.Cleanup(ws.collector.Close)
+	return ws
+}
+
+// newStubbedWebServer is newTestWebServer with Yahoo served by a stub server
+// passing chart requests to handler
+func newStubbedWebServer(t *testing.T, cfg Config, handler http.HandlerFunc) *WebServer {
+	t.Helper()
+	stub := newStubYahooServer(t, handler)
+	cfg.YahooHosts = []string{stub.URL}
+	ws := newTestWebServer(t, cfg)
+	ws.collector.yahooClient.cookieURL = stub.URL + "/cookie"
+	return ws
+}
+
+// serve sends a request through ws's router; headers are name, value pairs
+func serve(ws *WebServer, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	ws.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSummaryCache(t *testing.T) {
+	ws := newTestWebServer(t, Config{CacheTTL: time.Minute})
+	database := ws.collector.database
+	ctx := context.Background()
+
+	if _, err := database.AddWatchedStock(ctx, "AAPL", "Apple Inc."); err != nil {
+		t.Fatalf("AddWatchedStock: %v", err)
+	}
+	newBar := func(daysAgo int) []MinuteBar {
+		return []MinuteBar{testBar("AAPL", time.Now().AddDate(0, 0, -daysAgo), 100, 10)}
+	}
+
+	steps := []struct {
+		name      string
+		before    func() error
+		path      string
+		wantCache string
+	}{
+		{name: "first request misses", path: "/api/stocks/AAPL/summary", wantCache: "MISS"},
+		{name: "repeat hits", path: "/api/stocks/AAPL/summary", wantCache: "HIT"},
+		{name: "other days miss", path: "/api/stocks/AAPL/summary?days=7", wantCache: "MISS"},
+		{
+			name:      "new data invalidates",
+			before:    func() error { return database.InsertMinuteData(ctx, newBar(1)) },
+			path:      "/api/stocks/AAPL/summary",
+			wantCache: "MISS",
+		},
+		{name: "cached again", path: "/api/stocks/AAPL/summary", wantCache: "HIT"},
+		{
+			name:      "summary update invalidates",
+			before:    func() error { return database.UpdateDailySummary(ctx, "AAPL", newBar(2)) },
+			path:      "/api/stocks/AAPL/summary",
+			wantCache: "MISS",
+		},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			if err := step.before(); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+		}
+		w := serve(ws, http.MethodGet, step.path, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", step.name, w.Code, w.Body)
+		}
+		if got := w.Header().Get("X-Cache"); got != step.wantCache {
+			t.Errorf("%s: X-Cache = %q, want %q", step.name, got, step.wantCache)
+		}
+	}
+}
+
+func TestOHLCArraysAligned(t *testing.T) {
+	ws := newTestWebServer(t, Config{})
+	database := ws.collector.database
+	ctx := context.Background()
+
+	// Three minute bars an hour ago and three daily bars, stored newest first
+	// to check the ordering, each with distinct prices
+	now := time.Now().UTC().Truncate(time.Minute)
+	var minuteBars, dailyBars []MinuteBar
+	for i := 2; i >= 0; i-- {
+		minuteBars = append(minuteBars, testBar("AAPL", now.Add(-time.Hour+time.Duration(i)*time.Minute), 100+float64(i), int64(10+i)))
+		dailyBars = append(dailyBars, testBar("AAPL", now.AddDate(0, 0, i-5), 200+float64(i), int64(20+i)))
+	}
+	if err := database.InsertMinuteData(ctx, minuteBars); err != nil {
+		t.Fatalf("InsertMinuteData: %v", err)
+	}
+	if err := database.StoreDailyBars(ctx, "AAPL", dailyBars); err != nil {
+		t.Fatalf("StoreDailyBars: %v", err)
+	}
+
+	tests := []struct {
+		granularity string
+		wantLen     int
+		base        float64 // price of the oldest bar, rising by 1 per bar
+		baseVolume  int64
+	}{
+		{granularity: GranularityMinute, wantLen: 3, base: 100, baseVolume: 10},
+		{granularity: GranularityDaily, wantLen: 3, base: 200, baseVolume: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.granularity, func(t *testing.T) {
+			w := serve(ws, http.MethodGet, "/api/stocks/AAPL/ohlc?days=30&granularity="+tt.granularity, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			var series OHLCSeries
+			if err := json.Unmarshal(w.Body.Bytes(), &series); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			lengths := []int{len(series.T), len(series.O), len(series.H), len(series.L), len(series.C), len(series.V)}
+			for _, n := range lengths {
+				if n != tt.wantLen {
+					t.Fatalf("array lengths t,o,h,l,c,v = %v, want all %d", lengths, tt.wantLen)
+				}
+			}
+			for i := 0; i < tt.wantLen; i++ {
+				if i > 0 && series.T[i] <= series.T[i-1] {
+					t.Errorf("t[%d] = %d is not after t[%d] = %d", i, series.T[i], i-1, series.T[i-1])
+				}
+				price := tt.base + float64(i)
+				if series.O[i] != price || series.H[i] != price || series.L[i] != price || series.C[i] != price || series.V[i] != tt.baseVolume+int64(i) {
+					t.Errorf("index %d = o %v h %v l %v c %v v %v, want price %v volume %v",
+						i, series.O[i], series.H[i], series.L[i], series.C[i], series.V[i], price, tt.baseVolume+int64(i))
+				}
+			}
+		})
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	for _, path := range []string{"/api/stocks/AAPL/summary", "/api/stocks/AAPL/data?days=7"} {
+		t.Run(path, func(t *testing.T) {
+			ws := newTestWebServer(t, Config{CacheTTL: time.Minute})
+			database := ws.collector.database
+			ctx := context.Background()
+			if _, err := database.AddWatchedStock(ctx, "AAPL", "Apple Inc."); err != nil {
+				t.Fatalf("AddWatchedStock: %v", err)
+			}
+			if err := database.InsertWithSummary(ctx, "AAPL", []MinuteBar{testBar("AAPL", time.Now().Add(-2*time.Hour), 100, 10)}); err != nil {
+				t.Fatalf("InsertWithSummary: %v", err)
+			}
+
+			first := serve(ws, http.MethodGet, path, "")
+			etag := first.Header().Get("ETag")
+			if first.Code != http.StatusOK || etag == "" {
+				t.Fatalf("first request: status %d, ETag %q", first.Code, etag)
+			}
+
+			steps := []struct {
+				name        string
+				before      func() error
+				ifNoneMatch string
+				want        int
+			}{
+				{name: "matching ETag", ifNoneMatch: etag, want: http.StatusNotModified},
+				{name: "ETag in a list", ifNoneMatch: `W/"0", ` + etag, want: http.StatusNotModified},
+				{name: "wildcard", ifNoneMatch: "*", want: http.StatusNotModified},
+				{name: "other ETag", ifNoneMatch: `W/"0"`, want: http.StatusOK},
+				{name: "no ETag", ifNoneMatch: "", want: http.StatusOK},
+				{
+					name: "new data changes the ETag",
+					before: func() error {
+						return database.InsertWithSummary(ctx, "AAPL", []MinuteBar{testBar("AAPL", time.Now().Add(-time.Hour), 101, 10)})
+					},
+					ifNoneMatch: etag,
+					want:        http.StatusOK,
+				},
+			}
+
+			for _, step := range steps {
+				if step.before != nil {
+					if err := step.before(); err != nil {
+						t.Fatalf("%s: %v", step.name, err)
+					}
+				}
+				w := serve(ws, http.MethodGet, path, "", "If-None-Match", step.ifNoneMatch)
+				if w.Code != step.want {
+					t.Errorf("%s: status = %d, want %d", step.name, w.Code, step.want)
+				}
+				if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+					t.Errorf("%s: 304 carried a %d byte body", step.name, w.Body.Len())
+				}
+				if w.Header().Get("ETag") == "" {
+					t.Errorf("%s: no ETag", step.name)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSymbolList(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{raw: "AAPL,MSFT", want: []string{"AAPL", "MSFT"}},
+		{raw: " aapl , msft ,", want: []string{"AAPL", "MSFT"}},
+		{raw: "MSFT,aapl,MSFT", want: []string{"MSFT", "AAPL"}},
+		{raw: "", want: nil},
+		{raw: ",,", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := parseSymbolList(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSymbolList(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var chartRequests atomic.Int32
+	ws := newStubbedWebServer(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		chartRequests.Add(1)
+		w.Write(chartJSON(t, "AAPL", stubBar{at: time.Now().Add(-time.Hour).Truncate(time.Minute), price: 100, volume: 10}))
+	})
+	if _, err := ws.collector.database.AddWatchedStock(context.Background(), "AAPL", "Apple Inc."); err != nil {
+		t.Fatalf("AddWatchedStock: %v", err)
+	}
+
+	first := serve(ws, http.MethodPost, "/api/stocks/AAPL/sync", "", "Idempotency-Key", "sync-1")
+	if first.Code != http.StatusOK {
+		t.Fatalf("first sync: status = %d, body %s", first.Code, first.Body)
+	}
+	collected := chartRequests.Load()
+	if collected == 0 {
+		t.Fatal("first sync made no chart requests")
+	}
+
+	tests := []struct {
+		name         string
+		key          string
+		wantReplayed bool
+	}{
+		{name: "same key replays", key: "sync-1", wantReplayed: true},
+		{name: "same key again", key: "sync-1", wantReplayed: true},
+		{name: "another key collects", key: "sync-2"},
+		{name: "no key collects", key: ""},
+	}
+
+	for _, tt := range tests {
+		before := chartRequests.Load()
+		w := serve(ws, http.MethodPost, "/api/stocks/AAPL/sync", "", "Idempotency-Key", tt.key)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", tt.name, w.Code, w.Body)
+		}
+		replayed := w.Header().Get("Idempotent-Replayed") == "true"
+		if replayed != tt.wantReplayed {
+			t.Errorf("%s: replayed = %v, want %v", tt.name, replayed, tt.wantReplayed)
+		}
+		collectedAgain := chartRequests.Load() != before
+		if collectedAgain == tt.wantReplayed {
+			t.Errorf("%s: collected = %v, want %v", tt.name, collectedAgain, !tt.wantReplayed)
+		}
+		if tt.wantReplayed && w.Body.String() != first.Body.String() {
+			t.Errorf("%s: body = %s, want the first response %s", tt.name, w.Body, first.Body)
+		}
+	}
+}
+
+func TestLatestBar(t *testing.T) {
+	ws := newTestWebServer(t, Config{})
+	ctx := context.Background()
+
+	// 2024-03-05 10:00-10:02 New York, stored out of order
+	start := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	latest := MinuteBar{
+		Symbol: "AAPL", Timestamp: start.Add(2 * time.Minute),
+		Open: 101, High: 103.5, Low: 100.25, Close: 102, Volume: 4200,
+		Currency: "USD", Session: SessionRegular,
+	}
+	bars := []MinuteBar{testBar("AAPL", start.Add(time.Minute), 100, 10), latest, testBar("AAPL", start, 99, 10)}
+	if err := ws.collector.database.InsertMinuteData(ctx, bars); err != nil {
+		t.Fatalf("InsertMinuteData: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantOffset string // of the returned timestamp
+	}{
+		{name: "latest bar", path: "/api/stocks/AAPL/latest", wantStatus: http.StatusOK, wantOffset: "Z"},
+		{name: "lower-case symbol", path: "/api/stocks/aapl/latest", wantStatus: http.StatusOK, wantOffset: "Z"},
+		{name: "in a time zone", path: "/api/stocks/AAPL/latest?tz=America/New_York", wantStatus: http.StatusOK, wantOffset: "-05:00"},
+		{name: "no data", path: "/api/stocks/MSFT/latest", wantStatus: http.StatusNotFound},
+		{name: "unknown time zone", path: "/api/stocks/AAPL/latest?tz=Nowhere/City", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(ws, http.MethodGet, tt.path, "")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var raw struct {
+				Timestamp string `json:"timestamp"`
+			}
+			var got MinuteBar
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			json.Unmarshal(w.Body.Bytes(), &raw)
+			if !strings.HasSuffix(raw.Timestamp, tt.wantOffset) {
+				t.Errorf("timestamp %s, want offset %s", raw.Timestamp, tt.wantOffset)
+			}
+			got.Timestamp = got.Timestamp.UTC()
+			if got != latest {
+				t.Errorf("bar = %+v, want %+v", got, latest)
+			}
+		})
+	}
+}
+
+func TestSearchLimits(t *testing.T) {
+	ws := newTestWebServer(t, Config{SearchMinLength: 2})
+	ws.search = newTestSearchService(numberedStocks(2 * maxSearchLimit)...)
+
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantCount   int
+		wantMessage bool
+	}{
+		{name: "default limit", query: "q=test", wantStatus: http.StatusOK, wantCount: defaultSearchLimit},
+		{name: "smaller limit", query: "q=test&limit=5", wantStatus: http.StatusOK, wantCount: 5},
+		{name: "limit capped", query: "q=test&limit=1000", wantStatus: http.StatusOK, wantCount: maxSearchLimit},
+		{name: "zero limit", query: "q=test&limit=0", wantStatus: http.StatusBadRequest},
+		{name: "non-numeric limit", query: "q=test&limit=all", wantStatus: http.StatusBadRequest},
+		{name: "too short", query: "q=t", wantStatus: http.StatusOK, wantCount: 0, wantMessage: true},
+		{name: "too short once trimmed", query: "q=%20t%20", wantStatus: http.StatusOK, wantCount: 0, wantMessage: true},
+		{name: "minimum length", query: "q=t0", wantStatus: http.StatusOK, wantCount: defaultSearchLimit},
+		{name: "missing query", query: "", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(ws, http.MethodGet, "/api/search?"+tt.query, "")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Results []StockSearchResult `json:"results"`
+				Count   int                 `json:"count"`
+				Message string              `json:"message"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Count != tt.wantCount || len(body.Results) != tt.wantCount {
+				t.Errorf("count = %d with %d results, want %d", body.Count, len(body.Results), tt.wantCount)
+			}
+			if (body.Message != "") != tt.wantMessage {
+				t.Errorf("message = %q, want one: %v", body.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestStaleSummaryTriggersSync(t *testing.T) {
+	// Tuesday 2024-03-05, 11:00 New York with the market open, and the
+	// following Saturday
+	open := time.Date(2024, 3, 5, 16, 0, 0, 0, time.UTC)
+	weekend := time.Date(2024, 3, 9, 16, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		barAge    time.Duration
+		requests  int
+		wantStale bool
+		wantSyncs int32
+	}{
+		{name: "stale during market hours", now: open, barAge: time.Hour, requests: 1, wantStale: true, wantSyncs: 1},
+		{name: "burst of requests syncs once", now: open, barAge: time.Hour, requests: 3, wantStale: true, wantSyncs: 1},
+		{name: "fresh", now: open, barAge: 5 * time.Minute, requests: 1},
+		{name: "market closed", now: weekend, barAge: 100 * time.Hour, requests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The stored bar is years old, so a sync fetches many batches;
+			// each sync's first asks for the newest one, ending now
+			var syncs atomic.Int32
+			ws := newStubbedWebServer(t, Config{RefreshStaleAfter: 10 * time.Minute, CacheTTL: time.Minute}, func(w http.ResponseWriter, r *http.Request) {
+				to, _ := strconv.ParseInt(r.URL.Query().Get("period2"), 10, 64)
+				if time.Since(time.Unix(to, 0)) < time.Minute {
+					syncs.Add(1)
+				}
+				w.Write(chartJSON(t, "AAPL", stubBar{time.Now().Add(-time.Hour).Truncate(time.Minute), 100, 10}))
+			})
+			ws.now = func() time.Time { return tt.now }
+
+			database := ws.collector.database
+			ctx := context.Background()
+			if _, err := database.AddWatchedStock(ctx, "AAPL", "Apple Inc."); err != nil {
+				t.Fatalf("AddWatchedStock: %v", err)
+			}
+			if err := database.InsertWithSummary(ctx, "AAPL", []MinuteBar{testBar("AAPL", tt.now.Add(-tt.barAge), 100, 10)}); err != nil {
+				t.Fatalf("InsertWithSummary: %v", err)
+			}
+
+			for i := range tt.requests {
+				w := serve(ws, http.MethodGet, "/api/stocks/AAPL/summary", "")
+				if w.Code != http.StatusOK {
+					t.Fatalf("request %d: status = %d, body %s", i+1, w.Code, w.Body)
+				}
+				var summary StockSummary
+				if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if summary.Stale != tt.wantStale {
+					t.Errorf("request %d: stale = %v, want %v", i+1, summary.Stale, tt.wantStale)
+				}
+			}
+
+			// A background sync ends by recording the sync time
+			deadline := time.Now().Add(5 * time.Second)
+			for tt.wantSyncs > 0 {
+				stock, err := database.GetWatchedStock(ctx, "AAPL")
+				if err != nil {
+					t.Fatalf("GetWatchedStock: %v", err)
+				}
+				if stock.LastSync != nil {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("background sync didn't finish")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			time.Sleep(50 * time.Millisecond)
+			if got := syncs.Load(); got != tt.wantSyncs {
+				t.Errorf("%d syncs, want %d", got, tt.wantSyncs)
+			}
+		})
+	}
+}
+
+func TestListWatchedStocksInactive(t *testing.T) {
+	ws := newTestWebServer(t, Config{})
+	database := ws.collector.database
+	ctx := context.Background()
+	for _, symbol := range []string{"AAPL", "MSFT", "TSLA"} {
+		if _, err := database.AddWatchedStock(ctx, symbol, ""); err != nil {
+			t.Fatalf("AddWatchedStock: %v", err)
+		}
+	}
+	if err := database.SetWatchedStockActive(ctx, "MSFT", false); err != nil {
+		t.Fatalf("SetWatchedStockActive: %v", err)
+	}
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		want       []string
+	}{
+		{query: "", wantStatus: http.StatusOK, want: []string{"AAPL", "TSLA"}},
+		{query: "?includeInactive=false", wantStatus: http.StatusOK, want: []string{"AAPL", "TSLA"}},
+		{query: "?includeInactive=true", wantStatus: http.StatusOK, want: []string{"AAPL", "MSFT", "TSLA"}},
+		{query: "?includeInactive=only", wantStatus: http.StatusOK, want: []string{"MSFT"}},
+		{query: "?includeInactive=yes", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := serve(ws, http.MethodGet, "/api/stocks"+tt.query, "")
+		if w.Code != tt.wantStatus {
+			t.Errorf("%q: status = %d, want %d", tt.query, w.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var stocks []WatchedStockAPI
+		if err := json.Unmarshal(w.Body.Bytes(), &stocks); err != nil {
+			t.Fatalf("%q: decode: %v", tt.query, err)
+		}
+		var got []string
+		for _, stock := range stocks {
+			got = append(got, stock.Symbol)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: stocks = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestAddExistingStock(t *testing.T) {
+	ws := newStubbedWebServer(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(chartJSON(t, "AAPL"))
+	})
+	database := ws.collector.database
+	ctx := context.Background()
+
+	steps := []struct {
+		name        string
+		body        string
+		wantCreated bool
+		wantMessage string
+		wantName    string
+	}{
+		{name: "new stock", body: `{"symbol":"AAPL","name":"Apple Inc."}`, wantCreated: true, wantMessage: "Stock added successfully", wantName: "Apple Inc."},
+		{name: "same symbol again", body: `{"symbol":"aapl"}`, wantMessage: "Stock already in watchlist", wantName: "Apple Inc."},
+		{name: "again with a name", body: `{"symbol":"AAPL","name":"Apple"}`, wantMessage: "Stock already in watchlist", wantName: "Apple"},
+	}
+
+	for _, step := range steps {
+		w := serve(ws, http.MethodPost, "/api/stocks", step.body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", step.name, w.Code, w.Body)
+		}
+		var response struct {
+			Message string `json:"message"`
+			Symbol  string `json:"symbol"`
+			Created *bool  `json:"created"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: decode: %v", step.name, err)
+		}
+		if response.Created == nil || *response.Created != step.wantCreated {
+			t.Errorf("%s: created = %v, want %v", step.name, response.Created, step.wantCreated)
+		}
+		if response.Message != step.wantMessage || response.Symbol != "AAPL" {
+			t.Errorf("%s: message %q for %s, want %q for AAPL", step.name, response.Message, response.Symbol, step.wantMessage)
+		}
+
+		stocks, err := database.GetWatchedStocks(ctx, WatchlistAll)
+		if err != nil {
+			t.Fatalf("GetWatchedStocks: %v", err)
+		}
+		if len(stocks) != 1 || stocks[0].Name != step.wantName {
+			t.Errorf("%s: watchlist = %+v, want one AAPL named %q", step.name, stocks, step.wantName)
+		}
+	}
+}
+
+func TestStreamedExportOutlivesWriteTimeout(t *testing.T) {
+	ws := newTestWebServer(t, Config{GzipMinLength: 1024})
+	ctx := context.Background()
+
+	const n = 5000
+	start := time.Now().Add(-n * time.Minute).Truncate(time.Minute)
+	bars := make([]MinuteBar, n)
+	for i := range bars {
+		bars[i] = testBar("AAPL", start.Add(time.Duration(i)*time.Minute), 100+float64(i)/100, int64(i))
+	}
+	if err := ws.collector.database.InsertMinuteData(ctx, bars); err != nil {
+		t.Fatalf("InsertMinuteData: %v", err)
+	}
+
+	// The deadline passes long before the rows are streamed, so the body
+	// only arrives whole if the export cleared it through the gzip writer
+	server := httptest.NewUnstartedServer(ws.router)
+	server.Config.WriteTimeout = time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		format    string
+		wantLines int
+	}{
+		{format: ExportFormatCSV, wantLines: n + 1},
+		{format: ExportFormatTSV, wantLines: n + 1},
+		{format: ExportFormatJSONL, wantLines: n},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// The default transport asks for gzip and decompresses the body
+			resp, err := server.Client().Get(server.URL + "/api/stocks/AAPL/export?days=7&format=" + tt.format)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("read body: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			if !resp.Uncompressed {
+				t.Errorf("response wasn't gzipped")
+			}
+			if lines := strings.Count(string(body), "\n"); lines != tt.wantLines {
+				t.Errorf("got %d lines, want %d", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestMaintenanceOutlivesWriteTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "vacuum", path: "/api/maintenance/vacuum"},
+		{name: "prune", path: "/api/maintenance/prune"},
+		{name: "v2 vacuum", path: "/api/v2/maintenance/vacuum"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newTestWebServer(t, Config{GzipMinLength: 1024, RetentionDays: 30})
+
+			// A year-old backlog gives prune rows to delete and vacuum a
+			// file worth rewriting
+			const n = 20000
+			start := time.Now().AddDate(-1, 0, 0).Truncate(time.Minute)
+			bars := make([]MinuteBar, n)
+			for i := range bars {
+				bars[i] = testBar("AAPL", start.Add(time.Duration(i)*time.Minute), 100, int64(i))
+			}
+			if err := ws.collector.database.InsertMinuteData(context.Background(), bars); err != nil {
+				t.Fatalf("InsertMinuteData: %v", err)
+			}
+
+			// The deadline passes before the maintenance work is done, so
+			// the response only arrives if the handler cleared it
+			server := httptest.NewUnstartedServer(ws.router)
+			server.Config.WriteTimeout = time.Millisecond
+			server.Start()
+			defer server.Close()
+
+			resp, err := server.Client().Post(server.URL+tt.path, "application/json", nil)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var body map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want %d: %v", resp.StatusCode, http.StatusOK, body)
+			}
+		})
+	}
+}
+
+func TestWatchlistChangesInvalidateSearch(t *testing.T) {
+	ws := newStubbedWebServer(t, Config{CacheTTL: time.Minute, SearchMinLength: 2}, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	ws.search = newTestSearchService()
+
+	// Each step changes the watchlist, then searches it again; the search
+	// before the first step caches an empty result
+	steps := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		wantSymbols []string
+	}{
+		{name: "add", method: http.MethodPost, path: "/api/stocks", body: `{"symbol":"ZZA"}`, wantSymbols: []string{"ZZA"}},
+		{name: "batch add", method: http.MethodPost, path: "/api/stocks/batch", body: `{"symbols":[{"symbol":"ZZB"}]}`, wantSymbols: []string{"ZZA", "ZZB"}},
+		{name: "remove", method: http.MethodDelete, path: "/api/stocks/ZZA", wantSymbols: []string{"ZZB"}},
+	}
+
+	search := func() []string {
+		t.Helper()
+		w := serve(ws, http.MethodGet, "/api/search?q=zz&includeWatched=true", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("search status = %d, body %s", w.Code, w.Body)
+		}
+		var response struct {
+			Results []StockSearchResult `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		symbols := []string{}
+		for _, result := range response.Results {
+			symbols = append(symbols, result.Symbol)
+		}
+		sort.Strings(symbols)
+		return symbols
+	}
+
+	if got := search(); len(got) != 0 {
+		t.Fatalf("before any change, search = %v, want none", got)
+	}
+	for _, step := range steps {
+		if w := serve(ws, step.method, step.path, step.body); w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", step.name, w.Code, w.Body)
+		}
+		if got := search(); !reflect.DeepEqual(got, step.wantSymbols) {
+			t.Errorf("%s: search = %v, want %v", step.name, got, step.wantSymbols)
+		}
+	}
+}
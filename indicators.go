@@ -0,0 +1,273 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Technical indicator helpers. All functions return a slice aligned with the
+// input; positions without enough history are left as zero.
+
+// SMA computes the simple moving average of values over period
+func SMA(values []float64, period int) []float64 {
+	result := make([]float64, len(values))
+	if period <= 0 {
+		return result
+	}
+
+	var sum float64
+	for i, value := range values {
+		sum += value
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			result[i] = sum / float64(period)
+		}
+	}
+
+	return result
+}
+
+// TrueRange computes each bar's true range: the largest of high-low and the
+// distances from the previous close to high and low. The first bar has no
+// previous close, so its true range is just high-low.
+func TrueRange(highs, lows, closes []float64) []float64 {
+	result := make([]float64, len(closes))
+	for i := range closes {
+		result[i] = highs[i] - lows[i]
+		if i == 0 {
+			continue
+		}
+		result[i] = math.Max(result[i], math.Max(math.Abs(highs[i]-closes[i-1]), math.Abs(lows[i]-closes[i-1])))
+	}
+	return result
+}
+
+// ATR computes the Average True Range with Wilder's smoothing: the first value,
+// at period-1, is the mean true range of the first period bars, and each later
+// one is (previous ATR * (period-1) + true range) / period
+func ATR(highs, lows, closes []float64, period int) []float64 {
+	result := make([]float64, len(closes))
+	if period <= 0 || len(closes) < period {
+		return result
+	}
+
+	trueRanges := TrueRange(highs, lows, closes)
+	var sum float64
+	for _, tr := range trueRanges[:period] {
+		sum += tr
+	}
+	result[period-1] = sum / float64(period)
+
+	for i := period; i < len(closes); i++ {
+		result[i] = (result[i-1]*float64(period-1) + trueRanges[i]) / float64(period)
+	}
+	return result
+}
+
+// defaultTradingDaysPerYear annualizes daily statistics when no other count
+// is configured: US equity markets trade about 252 days a year
+const defaultTradingDaysPerYear = 252
+
+// AnnualizedVolatility computes the rolling standard deviation of daily log
+// returns over window, scaled by sqrt(tradingDays), the trading days per year
+// (252 for US equities, 365 for crypto). Result[i] covers the window returns
+// ending at closes[i], so the first window positions have no value. Returns
+// involving a non-positive close (missing or gap days) are excluded rather than
+// producing Inf; a window with fewer than two usable returns stays zero.
+func AnnualizedVolatility(closes []float64, window, tradingDays int) []float64 {
+	result := make([]float64, len(closes))
+	if window < 2 || tradingDays <= 0 {
+		return result
+	}
+
+	// returns[i] is the log return from closes[i-1] to closes[i]
+	returns := make([]float64, len(closes))
+	valid := make([]bool, len(closes))
+	for i := 1; i < len(closes); i++ {
+		if closes[i-1] > 0 && closes[i] > 0 {
+			returns[i] = math.Log(closes[i] / closes[i-1])
+			valid[i] = true
+		}
+	}
+
+	for i := window; i < len(closes); i++ {
+		var windowReturns []float64
+		for j := i - window + 1; j <= i; j++ {
+			if valid[j] {
+				windowReturns = append(windowReturns, returns[j])
+			}
+		}
+		if len(windowReturns) < 2 {
+			continue
+		}
+		result[i] = stdDev(windowReturns) * math.Sqrt(float64(tradingDays))
+	}
+
+	return result
+}
+
+// DailyReturns computes the simple return of each close over the one before
+// it. Pairs involving a non-positive close (missing or gap days) are skipped,
+// so the result may be shorter than len(closes)-1.
+func DailyReturns(closes []float64) []float64 {
+	var returns []float64
+	for i := 1; i < len(closes); i++ {
+		if closes[i-1] > 0 && closes[i] > 0 {
+			returns = append(returns, closes[i]/closes[i-1]-1)
+		}
+	}
+	return returns
+}
+
+// AnnualizedReturn compounds the return from the first to the last close up
+// to a year of tradingDays, treating each close as one trading day. It is 0
+// without two positive closes to compare.
+func AnnualizedReturn(closes []float64, tradingDays int) float64 {
+	if len(closes) < 2 || closes[0] <= 0 || closes[len(closes)-1] <= 0 {
+		return 0
+	}
+	years := float64(len(closes)-1) / float64(tradingDays)
+	if r := math.Pow(closes[len(closes)-1]/closes[0], 1/years) - 1; isFinite(r) {
+		return r
+	}
+	return 0
+}
+
+// SharpeRatio annualizes the mean and standard deviation of daily returns
+// over tradingDays and returns the excess return over the annual risk-free
+// rate rf per unit of volatility. It is 0 for fewer than two returns or
+// returns that don't vary.
+func SharpeRatio(returns []float64, rf float64, tradingDays int) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+	mean, sd := meanStdDev(returns)
+	days := float64(tradingDays)
+	return safeDiv(mean*days-rf, sd*math.Sqrt(days))
+}
+
+// Beta measures how strongly stockReturns move with indexReturns: their
+// sample covariance over the variance of indexReturns. The two must be
+// returns over the same periods, pairwise; extra values in the longer slice
+// are ignored. It is 0 for fewer than two pairs or an index that doesn't vary.
+func Beta(stockReturns, indexReturns []float64) float64 {
+	n := min(len(stockReturns), len(indexReturns))
+	if n < 2 {
+		return 0
+	}
+	stockReturns, indexReturns = stockReturns[:n], indexReturns[:n]
+
+	stockMean, indexMean := mean(stockReturns), mean(indexReturns)
+	var covariance, variance float64
+	for i := range indexReturns {
+		covariance += (stockReturns[i] - stockMean) * (indexReturns[i] - indexMean)
+		variance += (indexReturns[i] - indexMean) * (indexReturns[i] - indexMean)
+	}
+	return safeDiv(covariance, variance)
+}
+
+// MaxDrawdown returns the largest fall from a running peak in values, as a
+// positive fraction of that peak (0.25 is a 25% drawdown); 0 if values never
+// fall below an earlier positive peak
+func MaxDrawdown(values []float64) float64 {
+	var peak, maxDrawdown float64
+	for _, value := range values {
+		if value > peak {
+			peak = value
+		}
+		if peak > 0 {
+			maxDrawdown = math.Max(maxDrawdown, (peak-value)/peak)
+		}
+	}
+	return maxDrawdown
+}
+
+// RollingZScore scores each value against the window values before it:
+// (value - mean) / sample standard deviation. The first window positions, and
+// positions whose trailing window doesn't vary, stay zero.
+func RollingZScore(values []float64, window int) []float64 {
+	result := make([]float64, len(values))
+	if window < 2 {
+		return result
+	}
+
+	for i := window; i < len(values); i++ {
+		mean, sd := meanStdDev(values[i-window : i])
+		result[i] = safeDiv(values[i]-mean, sd)
+	}
+
+	return result
+}
+
+// safeDiv returns a / b, or 0 when b is zero or the quotient isn't finite, so
+// zero prices in the data can't turn analysis results into NaN or Inf
+func safeDiv(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	if q := a / b; isFinite(q) {
+		return q
+	}
+	return 0
+}
+
+// isFinite reports whether value is neither NaN nor infinite
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// mean returns the average of values, or 0 when there are none
+func mean(values []float64) float64 {
+	m, _ := meanStdDev(values)
+	return m
+}
+
+// median returns the middle value of values (the mean of the middle two for
+// an even count), or 0 when there are none. values is left unsorted.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// stdDev returns the sample standard deviation of values
+func stdDev(values []float64) float64 {
+	_, sd := meanStdDev(values)
+	return sd
+}
+
+// meanStdDev returns the mean and sample standard deviation of values; the
+// deviation is zero for fewer than two values
+func meanStdDev(values []float64) (mean, sd float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+
+	if len(values) < 2 {
+		return mean, 0
+	}
+
+	var variance float64
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	variance /= float64(len(values) - 1)
+
+	return mean, math.Sqrt(variance)
+}
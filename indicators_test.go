@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// closesFromLogReturns builds a close series starting at 100 with the given
+// daily log returns
+func closesFromLogReturns(returns ...float64) []float64 {
+	closes := []float64{100}
+	for _, r := range returns {
+		closes = append(closes, closes[len(closes)-1]*math.Exp(r))
+	}
+	return closes
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestAnnualizedVolatility(t *testing.T) {
+	sqrt252 := math.Sqrt(252)
+
+	tests := []struct {
+		name        string
+		closes      []float64
+		window      int
+		tradingDays int
+		want        []float64
+	}{
+		{
+			// Each window of two returns is {+1%, -1%}: sample sd 0.01*sqrt(2)
+			name:        "alternating returns, window 2",
+			closes:      closesFromLogReturns(0.01, -0.01, 0.01, -0.01),
+			window:      2,
+			tradingDays: 252,
+			want:        []float64{0, 0, 0.01 * math.Sqrt(2) * sqrt252, 0.01 * math.Sqrt(2) * sqrt252, 0.01 * math.Sqrt(2) * sqrt252},
+		},
+		{
+			// Four returns of ±1% around a zero mean: sample variance 4e-4/3
+			name:        "alternating returns, window 4",
+			closes:      closesFromLogReturns(0.01, -0.01, 0.01, -0.01),
+			window:      4,
+			tradingDays: 252,
+			want:        []float64{0, 0, 0, 0, math.Sqrt(4e-4/3) * sqrt252},
+		},
+		{
+			name:        "crypto annualizes over 365 days",
+			closes:      closesFromLogReturns(0.01, -0.01),
+			window:      2,
+			tradingDays: 365,
+			want:        []float64{0, 0, 0.01 * math.Sqrt(2) * math.Sqrt(365)},
+		},
+		{
+			name:        "constant growth has no volatility",
+			closes:      closesFromLogReturns(0.02, 0.02, 0.02),
+			window:      2,
+			tradingDays: 252,
+			want:        []float64{0, 0, 0, 0},
+		},
+		{
+			name:        "window below 2 is unset",
+			closes:      closesFromLogReturns(0.01, -0.01),
+			window:      1,
+			tradingDays: 252,
+			want:        []float64{0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AnnualizedVolatility(tt.closes, tt.window, tt.tradingDays)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d values, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if !approxEqual(got[i], tt.want[i]) {
+					t.Errorf("volatility[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRollingZScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		window int
+		want   []float64
+	}{
+		{
+			// Trailing window {1,2,3,4}: mean 2.5, sample sd sqrt(5/3)
+			name:   "spike after a rising window",
+			values: []float64{1, 2, 3, 4, 10},
+			window: 4,
+			want:   []float64{0, 0, 0, 0, 7.5 / math.Sqrt(5.0/3)},
+		},
+		{
+			name:   "below the trailing mean",
+			values: []float64{2, 4, 0},
+			window: 2,
+			want:   []float64{0, 0, -3 / math.Sqrt2},
+		},
+		{
+			name:   "flat window can't score a spike",
+			values: []float64{10, 10, 10, 10, 50},
+			window: 4,
+			want:   []float64{0, 0, 0, 0, 0},
+		},
+		{
+			name:   "window below 2",
+			values: []float64{1, 2, 3},
+			window: 1,
+			want:   []float64{0, 0, 0},
+		},
+		{
+			name:   "shorter than the window",
+			values: []float64{1, 2},
+			window: 5,
+			want:   []float64{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RollingZScore(tt.values, tt.window)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d values, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if !approxEqual(got[i], tt.want[i]) {
+					t.Errorf("z[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSafeDiv(t *testing.T) {
+	tests := []struct {
+		a, b float64
+		want float64
+	}{
+		{a: 1, b: 4, want: 0.25},
+		{a: -3, b: 2, want: -1.5},
+		{a: 1, b: 0, want: 0},
+		{a: 0, b: 0, want: 0},
+		{a: math.MaxFloat64, b: 1e-300, want: 0},
+		{a: math.NaN(), b: 1, want: 0},
+		{a: 1, b: math.Inf(1), want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := safeDiv(tt.a, tt.b); got != tt.want {
+			t.Errorf("safeDiv(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestZeroPrices(t *testing.T) {
+	tests := []struct {
+		name   string
+		closes []float64
+	}{
+		{name: "all zero", closes: []float64{0, 0, 0, 0, 0}},
+		{name: "starts at zero", closes: []float64{0, 100, 101, 102, 103}},
+		{name: "zero in the middle", closes: []float64{100, 101, 0, 102, 103}},
+		{name: "ends at zero", closes: []float64{100, 101, 102, 103, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bars []MinuteBar
+			for i, bar := range cliBars(len(tt.closes)) {
+				bar.Open, bar.High, bar.Low, bar.Close = tt.closes[i], tt.closes[i], tt.closes[i], tt.closes[i]
+				bars = append(bars, bar)
+			}
+			returns := DailyReturns(tt.closes)
+
+			results := map[string]any{
+				"analyzeBars":          analyzeBars(bars),
+				"AnnualizedVolatility": AnnualizedVolatility(tt.closes, 2, 252),
+				"AnnualizedReturn":     AnnualizedReturn(tt.closes, 252),
+				"SharpeRatio":          SharpeRatio(returns, 0.02, 252),
+				"Beta":                 Beta(returns, returns),
+				"MaxDrawdown":          MaxDrawdown(tt.closes),
+				"RollingZScore":        RollingZScore(tt.closes, 2),
+				"Backtest":             Backtest(dailyCloses(tt.closes...), BacktestParams{Fast: 1, Slow: 2, InitialCash: 1000}),
+			}
+			for name, result := range results {
+				// encoding/json refuses NaN and Inf, so this checks every float
+				if _, err := json.Marshal(result); err != nil {
+					t.Errorf("%s: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTrueRange(t *testing.T) {
+	tests := []struct {
+		name                string
+		highs, lows, closes []float64
+		want                []float64
+	}{
+		{
+			name:  "first bar is high-low",
+			highs: []float64{10}, lows: []float64{8}, closes: []float64{9},
+			want: []float64{2},
+		},
+		{
+			name:  "gap up uses the previous close",
+			highs: []float64{10, 20}, lows: []float64{9, 19}, closes: []float64{9.5, 19.5},
+			want: []float64{1, 10.5},
+		},
+		{
+			name:  "gap down uses the previous close",
+			highs: []float64{20, 11}, lows: []float64{19, 10}, closes: []float64{19.5, 10.5},
+			want: []float64{1, 9.5},
+		},
+		{
+			name:  "inside bar is high-low",
+			highs: []float64{12, 11}, lows: []float64{8, 9}, closes: []float64{10, 10},
+			want: []float64{4, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TrueRange(tt.highs, tt.lows, tt.closes)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d values, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if !approxEqual(got[i], tt.want[i]) {
+					t.Errorf("tr[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestATR(t *testing.T) {
+	// True ranges 2, 3, 1, 5, 2
+	highs := []float64{10, 12, 11, 15, 14}
+	lows := []float64{8, 9, 10, 11, 12}
+	closes := []float64{9, 11, 10, 14, 13}
+
+	tests := []struct {
+		name   string
+		period int
+		want   []float64
+	}{
+		{name: "period 3", period: 3, want: []float64{0, 0, 2, 3, 8.0 / 3}},
+		{name: "period 1 is the true range", period: 1, want: []float64{2, 3, 1, 5, 2}},
+		{name: "period of every bar", period: 5, want: []float64{0, 0, 0, 0, 13.0 / 5}},
+		{name: "period longer than the bars", period: 6, want: []float64{0, 0, 0, 0, 0}},
+		{name: "non-positive period", period: 0, want: []float64{0, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ATR(highs, lows, closes, tt.period)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d values, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if !approxEqual(got[i], tt.want[i]) {
+					t.Errorf("atr[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMaxDrawdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{name: "empty", values: nil, want: 0},
+		{name: "only rising", values: []float64{100, 110, 120}, want: 0},
+		{name: "deepest fall from a later peak", values: []float64{100, 120, 90, 130, 65}, want: 0.5},
+		{name: "deepest fall from an earlier peak", values: []float64{100, 50, 200, 150}, want: 0.5},
+		{name: "never recovers", values: []float64{100, 80, 60}, want: 0.4},
+		{name: "leading zeros have no peak", values: []float64{0, 0, 100, 80}, want: 0.2},
+		{name: "all zero", values: []float64{0, 0, 0}, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := MaxDrawdown(tt.values); !approxEqual(got, tt.want) {
+			t.Errorf("%s: MaxDrawdown = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSharpeRatio(t *testing.T) {
+	sqrt252 := math.Sqrt(252)
+	// Sample standard deviation of {0.02, 0.01} and of alternating ±1%
+	sdPair := 0.01 / math.Sqrt2
+	sdAlternating := math.Sqrt(4e-4 / 3)
+
+	tests := []struct {
+		name        string
+		returns     []float64
+		rf          float64
+		tradingDays int
+		want        float64
+	}{
+		{name: "positive returns", returns: []float64{0.02, 0.01}, rf: 0, tradingDays: 252, want: 0.015 * 252 / (sdPair * sqrt252)},
+		{name: "risk-free rate subtracted", returns: []float64{0.02, 0.01}, rf: 0.04, tradingDays: 252, want: (0.015*252 - 0.04) / (sdPair * sqrt252)},
+		{name: "flat mean", returns: []float64{0.01, -0.01, 0.01, -0.01}, rf: 0, tradingDays: 252, want: 0},
+		{name: "flat mean below the risk-free rate", returns: []float64{0.01, -0.01, 0.01, -0.01}, rf: 0.04, tradingDays: 252, want: -0.04 / (sdAlternating * sqrt252)},
+		{name: "crypto year", returns: []float64{0.02, 0.01}, rf: 0, tradingDays: 365, want: 0.015 * 365 / (sdPair * math.Sqrt(365))},
+		{name: "no volatility", returns: []float64{0.01, 0.01, 0.01}, rf: 0.04, tradingDays: 252, want: 0},
+		{name: "one return", returns: []float64{0.05}, rf: 0, tradingDays: 252, want: 0},
+		{name: "no returns", returns: nil, rf: 0, tradingDays: 252, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := SharpeRatio(tt.returns, tt.rf, tt.tradingDays); !approxEqual(got, tt.want) {
+			t.Errorf("%s: SharpeRatio = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
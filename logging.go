@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log file rotation defaults
+const (
+	defaultLogMaxSizeMB  = 100
+	defaultLogMaxBackups = 5
+	defaultLogMaxAgeDays = 30
+)
+
+// setupLogging sends the standard logger and gin's request log to
+// cfg.LogFile, rotated by size, when one is configured. The console keeps a
+// copy unless cfg.LogConsole is off. Writes go straight to the file, so it
+// needs no closing before exit.
+func setupLogging(cfg Config) {
+	if cfg.LogFile == "" {
+		return
+	}
+
+	file := &lumberjack.Logger{
+		Filename:   cfg.LogFile,
+		MaxSize:    cfg.LogMaxSizeMB,
+		MaxBackups: cfg.LogMaxBackups,
+		MaxAge:     cfg.LogMaxAgeDays,
+	}
+
+	var out, requestOut io.Writer = file, file
+	if cfg.LogConsole {
+		out = io.MultiWriter(os.Stderr, file)
+		requestOut = io.MultiWriter(os.Stdout, file)
+	}
+
+	log.SetOutput(out)
+	gin.DefaultWriter = requestOut
+	gin.DefaultErrorWriter = out
+}
@@ -1,88 +1,446 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/robfig/cron/v3"
 )
 
+// cliActions are the -action values runCLIMode handles
+var cliActions = []string{"collect", "collect-daily", "rebuild-summary", "analyze", "sample", "rename", "dump", "restore", "vacuum", "healthcheck"}
+
 func main() {
 	// Command line flags
 	mode := flag.String("mode", "web", "Run mode: web, cli")
 	symbol := flag.String("symbol", "TSLA", "Stock symbol (default: TSLA)")
-	days := flag.Int("days", 30, "Number of days to fetch (default: 30)")
+	daysArg := flag.String("days", "30", "Number of days to fetch, or max for the full daily history (default: 30)")
+	logFile := flag.String("log-file", "", "Also write logs to this file, rotated by size (default: console only)")
+	logConsole := flag.Bool("log-console", true, "With -log-file, keep logging to the console too (default: true)")
+	logMaxSize := flag.Int("log-max-size", defaultLogMaxSizeMB, "Rotate the log file once it reaches this many megabytes (default: 100)")
+	logMaxBackups := flag.Int("log-max-backups", defaultLogMaxBackups, "Rotated log files to keep, 0 keeps all (default: 5)")
+	logMaxAge := flag.Int("log-max-age", defaultLogMaxAgeDays, "Days to keep rotated log files, 0 keeps them regardless of age (default: 30)")
 	dbPath := flag.String("db", "stock_data.db", "Database file path (default: stock_data.db)")
-	action := flag.String("action", "collect", "Action: collect, analyze, sample")
+	action := flag.String("action", "collect", "Action: "+strings.Join(cliActions, ", "))
+	renameTo := flag.String("to", "", "With -action=rename, the symbol to move -symbol's data to")
+	dumpFile := flag.String("file", "", "With -action=dump or restore, the JSON dump file (default: stdout/stdin)")
+	dumpMinute := flag.Bool("include-minute", false, "With -action=dump, include minute bars as well as the watchlist and daily summaries")
 	port := flag.String("port", "8080", "Web server port (default: 8080)")
+	staticDir := flag.String("static-dir", "", "Serve the web UI from this directory instead of the copy embedded in the binary, e.g. ./static for development")
 	enableScheduler := flag.Bool("scheduler", true, "Enable scheduled updates at 8:00 AM China time (default: true)")
+	retentionDays := flag.Int("retention", 0, "Days of minute data to keep, pruned daily by the scheduler (default: 0, keep all)")
+	requestTimeout := flag.Duration("timeout", 60*time.Second, "Timeout for sync and data API requests (default: 60s)")
+	staleAfter := flag.Duration("stale-after", 26*time.Hour, "Flag watched stocks as stale after this long without a sync (default: 26h)")
+	enableEvents := flag.Bool("events", false, "Enable dividend/earnings calendar endpoint and weekly refresh (default: false)")
+	defaultWatchlist := flag.String("default-watchlist", "", "Comma-separated symbols to watch on first start, when the watchlist is empty, e.g. TSLA,AAPL")
+	resolveAliases := flag.Bool("resolve-aliases", false, "Resolve renamed tickers and common company names, e.g. FB or Facebook to META, when syncing and adding stocks (default: false)")
+	symbolAliases := flag.String("symbol-aliases", "", "Comma-separated ALIAS=SYMBOL pairs resolved when syncing and adding stocks, on top of -resolve-aliases, e.g. OLDCO=NEWCO")
+	searchMinLength := flag.Int("search-min-length", 1, "Shortest /api/search query matched, in characters; 2 cuts noisy one-letter results (default: 1)")
+	userAgents := flag.String("user-agent", "", "Comma-separated User-Agents to rotate for Yahoo requests (default: built-in Safari UA)")
+	proxyURL := flag.String("proxy", os.Getenv("HTTP_PROXY"), "HTTP proxy URL for Yahoo requests (default: $HTTP_PROXY)")
+	yahooTimeout := flag.Duration("yahoo-timeout", defaultYahooTimeout, "Timeout for each Yahoo request attempt (default: 15s)")
+	yahooRetries := flag.Int("yahoo-retries", defaultYahooRetries, "Retries of a Yahoo request that times out or fails to connect, before trying the next host (default: 1)")
+	yahooHosts := flag.String("yahoo-hosts", "", "Comma-separated Yahoo API hosts to fall back across (default: query1, query2)")
+	cacheBackend := flag.String("cache", CacheBackendMemory, "Response cache backend: memory, redis (default: memory)")
+	redisAddr := flag.String("redis-addr", "localhost:6379", "Redis address when -cache=redis (default: localhost:6379)")
+	cacheTTL := flag.Duration("cache-ttl", time.Minute, "TTL for cached summary, data and search responses, 0 disables (default: 1m)")
+	spotCacheTTL := flag.Duration("spot-cache-ttl", 15*time.Second, "TTL for cached live spot prices, independent of -cache-ttl, 0 disables (default: 15s)")
+	summaryCacheSize := flag.Int("summary-cache-size", 128, "Daily summary ranges kept in the in-memory LRU, 0 disables (default: 128)")
+	grpcPort := flag.String("grpc-port", "", "Serve the gRPC API on this port alongside the web server (default: disabled)")
+	gzipMinLength := flag.Int("gzip-min-length", 1024, "Gzip /api responses of at least this many bytes, 0 disables (default: 1024)")
+	extendedHours := flag.Bool("extended-hours", false, "Keep zero-volume pre/post-market bars too (default: false)")
+	summarySession := flag.String("summary-session", SummarySessionAuto, "Bars daily OHLC and volume are built from: regular, all, or auto (regular with -extended-hours, otherwise all) (default: auto)")
+	settleSchedule := flag.String("settle-schedule", "", "Cron spec (China time) for re-fetching the last closed trading day and rebuilding its summaries, e.g. \"0 7 * * 2-6\" (default: disabled)")
+	refreshStaleAfter := flag.Duration("refresh-stale-after", 0, "During US market hours, sync a stock in the background when a summary request finds its latest bar older than this, e.g. 10m (default: 0, disabled)")
+	intradayInterval := flag.Duration("intraday-interval", 0, "Also refresh watched stocks this often during US market hours, e.g. 15m (default: 0, disabled)")
+	readTimeout := flag.Duration("read-timeout", 15*time.Second, "Web server limit for reading a request, headers included, 0 disables (default: 15s)")
+	writeTimeout := flag.Duration("write-timeout", 90*time.Second, "Web server limit for writing a response, must exceed -timeout; SSE streams, streamed exports, vacuum and prune are exempt, 0 disables (default: 90s)")
+	idleTimeout := flag.Duration("idle-timeout", 120*time.Second, "Web server limit for idle keep-alive connections, 0 disables (default: 2m)")
+	batchDays := flag.Int("batch-days", defaultBatchDays, "Days of minute data per Yahoo request, 1-8 (default: 7)")
+	batchDelay := flag.Duration("batch-delay", defaultBatchDelay, "Minimum gap between the starts of Yahoo requests of a multi-batch fetch (default: 1s)")
+	batchConcurrency := flag.Int("batch-concurrency", defaultBatchConcurrency, "Yahoo requests of a multi-batch fetch run at once (default: 3)")
+	spikeFactor := flag.Float64("spike-factor", 0, "Drop minute bars whose close is more than this many times off the median of nearby bars, e.g. 3; 0 disables (default: 0)")
+	spikeWindow := flag.Int("spike-window", defaultSpikeWindow, "Nearby bars the -spike-factor median is taken over (default: 10)")
+	atomicCollect := flag.Bool("atomic-collect", false, "Store minute bars and summary updates in one transaction, rolling back both if the summaries fail (default: false)")
+	filterMode := flag.String("filter-mode", FilterModeStrict, "Minute-bar anomaly filtering: strict, lenient (high/low check only) or off (default: strict)")
+	conflictStrategy := flag.String("conflict-strategy", ConflictReplace, "Re-fetched minute bars already stored: replace overwrites them with Yahoo's latest values, ignore keeps the first fetched (default: replace)")
+	quarantineRejected := flag.Bool("quarantine-rejected", false, "Record minute bars dropped by -filter-mode and -spike-factor, with the reason, for GET /api/stocks/:symbol/rejected (default: false)")
+	since := flag.String("since", "", "Collect minute data from this date, YYYY-MM-DD New York time, instead of -days")
+	until := flag.String("until", "", "With -since, collect up to (not including) this date, YYYY-MM-DD (default: now)")
+	tradingDaysPerYear := flag.Int("trading-days-per-year", defaultTradingDaysPerYear, "Trading days per year used to annualize volatility, e.g. 365 for crypto (default: 252)")
+	format := flag.String("format", OutputFormatLog, "CLI output format for sample and analyze: log, table (default: log)")
+	initialDays := flag.Int("initial-days", defaultInitialDays, "Days of minute data to fetch on a symbol's first sync, 1-30 (default: 30)")
 	flag.Parse()
 
+	cfg := Config{
+		DBPath:             *dbPath,
+		Port:               *port,
+		StaticDir:          *staticDir,
+		EnableScheduler:    *enableScheduler,
+		RetentionDays:      *retentionDays,
+		RequestTimeout:     *requestTimeout,
+		StaleAfter:         *staleAfter,
+		EnableEvents:       *enableEvents,
+		ProxyURL:           *proxyURL,
+		CacheBackend:       *cacheBackend,
+		RedisAddr:          *redisAddr,
+		CacheTTL:           *cacheTTL,
+		SpotCacheTTL:       *spotCacheTTL,
+		SummaryCacheSize:   *summaryCacheSize,
+		GRPCPort:           *grpcPort,
+		GzipMinLength:      *gzipMinLength,
+		InitialDays:        *initialDays,
+		ExtendedHours:      *extendedHours,
+		SummarySession:     *summarySession,
+		IntradayInterval:   *intradayInterval,
+		RefreshStaleAfter:  *refreshStaleAfter,
+		SettleSchedule:     *settleSchedule,
+		BatchDays:          *batchDays,
+		BatchDelay:         *batchDelay,
+		FilterMode:         *filterMode,
+		ConflictStrategy:   *conflictStrategy,
+		SpikeFactor:        *spikeFactor,
+		SpikeWindow:        *spikeWindow,
+		LogFile:            *logFile,
+		LogConsole:         *logConsole,
+		LogMaxSizeMB:       *logMaxSize,
+		LogMaxBackups:      *logMaxBackups,
+		LogMaxAgeDays:      *logMaxAge,
+		AtomicCollect:      *atomicCollect,
+		SearchMinLength:    *searchMinLength,
+		QuarantineRejected: *quarantineRejected,
+		YahooTimeout:       *yahooTimeout,
+		YahooRetries:       *yahooRetries,
+		BatchConcurrency:   *batchConcurrency,
+		TradingDaysPerYear: *tradingDaysPerYear,
+		ReadTimeout:        *readTimeout,
+		WriteTimeout:       *writeTimeout,
+		IdleTimeout:        *idleTimeout,
+		ResolveAliases:     *resolveAliases,
+	}
+	if *userAgents != "" {
+		cfg.UserAgents = strings.Split(*userAgents, ",")
+	}
+	if *yahooHosts != "" {
+		cfg.YahooHosts = strings.Split(*yahooHosts, ",")
+	}
+	if *defaultWatchlist != "" {
+		for _, symbol := range strings.Split(*defaultWatchlist, ",") {
+			symbol = strings.ToUpper(strings.TrimSpace(symbol))
+			if !isValidSymbol(symbol) {
+				log.Fatalf("Invalid -default-watchlist symbol %q", symbol)
+			}
+			cfg.DefaultWatchlist = append(cfg.DefaultWatchlist, symbol)
+		}
+	}
+	if *symbolAliases != "" {
+		aliases, err := parseSymbolAliases(*symbolAliases)
+		if err != nil {
+			log.Fatalf("Invalid -symbol-aliases: %v", err)
+		}
+		cfg.SymbolAliases = aliases
+	}
+	if cfg.YahooTimeout <= 0 {
+		log.Fatalf("Invalid -yahoo-timeout %v: must be positive", cfg.YahooTimeout)
+	}
+	if cfg.YahooRetries < 0 {
+		log.Fatalf("Invalid -yahoo-retries %d: must not be negative", cfg.YahooRetries)
+	}
+	if cfg.SearchMinLength < 1 {
+		log.Fatalf("Invalid -search-min-length %d: must be at least 1", cfg.SearchMinLength)
+	}
+	if cfg.InitialDays < 1 || cfg.InitialDays > maxInitialDays {
+		log.Fatalf("Invalid -initial-days %d: must be between 1 and %d", cfg.InitialDays, maxInitialDays)
+	}
+
+	if !isValidOutputFormat(*format) {
+		log.Fatalf("Invalid -format %q: must be log or table", *format)
+	}
+	if cfg.BatchDays < 1 || cfg.BatchDays > maxBatchDays {
+		log.Fatalf("Invalid -batch-days %d: must be between 1 and %d", cfg.BatchDays, maxBatchDays)
+	}
+	if cfg.TradingDaysPerYear < 1 || cfg.TradingDaysPerYear > 366 {
+		log.Fatalf("Invalid -trading-days-per-year %d: must be between 1 and 366", cfg.TradingDaysPerYear)
+	}
+	if cfg.BatchConcurrency < 1 {
+		log.Fatalf("Invalid -batch-concurrency %d: must be at least 1", cfg.BatchConcurrency)
+	}
+	if cfg.SpikeFactor != 0 && cfg.SpikeFactor <= 1 {
+		log.Fatalf("Invalid -spike-factor %v: must be greater than 1, or 0 to disable", cfg.SpikeFactor)
+	}
+	if cfg.SpikeWindow < 2 {
+		log.Fatalf("Invalid -spike-window %d: must be at least 2", cfg.SpikeWindow)
+	}
+	if !isValidSummarySession(cfg.SummarySession) {
+		log.Fatalf("Invalid -summary-session %q: must be auto, regular or all", cfg.SummarySession)
+	}
+	if !isValidConflictStrategy(cfg.ConflictStrategy) {
+		log.Fatalf("Invalid -conflict-strategy %q: must be replace or ignore", cfg.ConflictStrategy)
+	}
+	if !isValidFilterMode(cfg.FilterMode) {
+		log.Fatalf("Invalid -filter-mode %q: must be strict, lenient or off", cfg.FilterMode)
+	}
+	if !isValidWriteTimeout(cfg.WriteTimeout, cfg.RequestTimeout) {
+		log.Fatalf("Invalid -write-timeout %v: must exceed -timeout %v so timed-out requests can still answer", cfg.WriteTimeout, cfg.RequestTimeout)
+	}
+	if cfg.IntradayInterval != 0 && cfg.IntradayInterval < time.Minute {
+		log.Fatalf("Invalid -intraday-interval %v: must be at least 1m", cfg.IntradayInterval)
+	}
+	if cfg.SettleSchedule != "" {
+		if _, err := cron.ParseStandard(cfg.SettleSchedule); err != nil {
+			log.Fatalf("Invalid -settle-schedule %q: %v", cfg.SettleSchedule, err)
+		}
+	}
+	if cfg.RefreshStaleAfter < 0 {
+		log.Fatalf("Invalid -refresh-stale-after %v: must not be negative", cfg.RefreshStaleAfter)
+	}
+
+	// -days=max is shorthand for collecting the full daily history
+	days := 0
+	if *daysArg == "max" {
+		if *action != "collect" && *action != "collect-daily" {
+			log.Fatalf("-days=max only applies to -action=collect")
+		}
+		*action = "collect-daily"
+	} else {
+		var err error
+		if days, err = strconv.Atoi(*daysArg); err != nil {
+			log.Fatalf("Invalid -days %q: must be a number or max", *daysArg)
+		}
+	}
+
+	start, end, err := parseCollectRange(*since, *until, time.Now())
+	if err != nil {
+		log.Fatalf("Invalid date range: %v", err)
+	}
+	if !start.IsZero() && *action != "collect" {
+		log.Fatalf("-since/-until only apply to -action=collect")
+	}
+	if *action == "rename" {
+		*renameTo = strings.ToUpper(*renameTo)
+		if !isValidSymbol(*renameTo) {
+			log.Fatalf("-action=rename needs a valid -to symbol, e.g. -symbol=FB -to=META")
+		}
+	}
+
+	if cfg.LogMaxSizeMB < 1 || cfg.LogMaxBackups < 0 || cfg.LogMaxAgeDays < 0 {
+		log.Fatalf("Invalid log rotation: -log-max-size must be at least 1, -log-max-backups and -log-max-age at least 0")
+	}
+	setupLogging(cfg)
+
 	switch *mode {
 	case "web":
-		runWebMode(*port, *dbPath, *enableScheduler)
+		runWebMode(cfg)
 	case "cli":
-		runCLIMode(*symbol, *days, *dbPath, *action)
+		runCLIMode(cfg, *symbol, days, *action, *format, start, end, *renameTo, *dumpFile, *dumpMinute)
 	default:
 		log.Fatalf("Unknown mode: %s. Available modes: web, cli", *mode)
 	}
 }
 
-func runWebMode(port, dbPath string, enableScheduler bool) {
+func runWebMode(cfg Config) {
 	log.Println("=== Stock Tracker Web Server ===")
-	log.Printf("Database: %s", dbPath)
-	log.Printf("Server will start on http://localhost:%s", port)
-	if enableScheduler {
+	log.Printf("Database: %s", cfg.DBPath)
+	log.Printf("Server will start on http://localhost:%s", cfg.Port)
+	if cfg.EnableScheduler {
 		log.Println("Scheduled updates: Enabled (8:00 AM China time daily)")
+		if cfg.IntradayInterval > 0 {
+			log.Printf("Intraday updates: every %v during US market hours", cfg.IntradayInterval)
+		}
+		if cfg.SettleSchedule != "" {
+			log.Printf("Settled data re-fetch: %q China time", cfg.SettleSchedule)
+		}
 	} else {
 		log.Println("Scheduled updates: Disabled")
 	}
+	if cfg.RetentionDays > 0 {
+		log.Printf("Minute data retention: %d days", cfg.RetentionDays)
+	}
 
 	// Initialize web server
-	server, err := NewWebServer(dbPath, enableScheduler)
+	server, err := NewWebServer(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize web server: %v", err)
 	}
 	defer server.Close()
 
+	if cfg.GRPCPort != "" {
+		go func() {
+			if err := server.RunGRPC(":" + cfg.GRPCPort); err != nil {
+				log.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+	}
+
 	// Start server
-	if err := server.Run(":" + port); err != nil {
+	if err := server.Run(":" + cfg.Port); err != nil {
 		log.Fatalf("Failed to start web server: %v", err)
 	}
 }
 
-func runCLIMode(symbol string, days int, dbPath, action string) {
+// parseCollectRange turns -since/-until dates into a fetch window: New York
+// midnight of since up to midnight of until, or now when until is empty. Both
+// are zero when neither flag is set.
+func parseCollectRange(since, until string, now time.Time) (start, end time.Time, err error) {
+	if since == "" {
+		if until != "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("-until requires -since")
+		}
+		return time.Time{}, time.Time{}, nil
+	}
+
+	start, err = time.ParseInLocation("2006-01-02", since, marketLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("-since %q: expected YYYY-MM-DD", since)
+	}
+
+	end = now
+	if until != "" {
+		end, err = time.ParseInLocation("2006-01-02", until, marketLocation)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("-until %q: expected YYYY-MM-DD", until)
+		}
+		if end.After(now) {
+			return time.Time{}, time.Time{}, fmt.Errorf("-until %s is in the future", until)
+		}
+	}
+
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("-since %s must be before -until", since)
+	}
+	// Yahoo only serves 1-minute bars for about the last 30 days
+	if start.Before(now.AddDate(0, 0, -maxInitialDays)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("-since %s is more than %d days ago; Yahoo doesn't serve minute data that old", since, maxInitialDays)
+	}
+	return start, end, nil
+}
+
+func runCLIMode(cfg Config, symbol string, days int, action, format string, start, end time.Time, renameTo, dumpFile string, dumpMinute bool) {
 	log.Println("=== Stock Data Collector CLI ===")
 	log.Printf("Symbol: %s", symbol)
-	log.Printf("Days: %d", days)
-	log.Printf("Database: %s", dbPath)
+	if start.IsZero() {
+		log.Printf("Days: %d", days)
+	} else {
+		log.Printf("Range: %s to %s", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
+	}
+	log.Printf("Database: %s", cfg.DBPath)
 	log.Printf("Action: %s", action)
 
 	// Initialize collector
-	collector, err := NewStockCollector(dbPath)
+	collector, err := NewStockCollector(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize collector: %v", err)
 	}
 	defer collector.Close()
 
+	ctx := context.Background()
+
+	// Log format keeps the output on the log's stream; tables go to stdout
+	out := log.Writer()
+	if format == OutputFormatTable {
+		out = os.Stdout
+	}
+
 	switch action {
 	case "collect":
-		// Collect historical data
-		start := time.Now()
-		if err := collector.CollectHistoricalData(symbol, days); err != nil {
+		// Collect historical data for -days, or the -since/-until range.
+		// Draw a progress bar on interactive terminals; otherwise the batch
+		// logs are the progress report
+		var progress []ProgressFunc
+		var bar *progressBar
+		logOutput := log.Writer()
+		if isTerminal(os.Stdout) {
+			bar = newProgressBar(os.Stdout, symbol)
+			log.SetOutput(bar.logWriter(logOutput))
+			progress = append(progress, bar.Update)
+		}
+
+		began := time.Now()
+		if start.IsZero() {
+			err = collector.CollectHistoricalData(ctx, symbol, days, progress...)
+		} else {
+			err = collector.CollectRange(ctx, symbol, start, end, progress...)
+		}
+		if bar != nil {
+			bar.Finish()
+			log.SetOutput(logOutput)
+		}
+		if err != nil {
 			log.Fatalf("Failed to collect data: %v", err)
 		}
-		duration := time.Since(start)
+		duration := time.Since(began)
 		log.Printf("Data collection completed in %v", duration)
 
 		// Display sample data
-		if err := collector.DisplaySampleData(symbol, 5); err != nil {
+		if err := collector.DisplaySampleData(ctx, out, format, symbol, 5); err != nil {
 			log.Printf("Warning: failed to display sample data: %v", err)
 		}
 
+	case "collect-daily":
+		// Collect the full daily history into the summaries
+		start := time.Now()
+		if err := collector.CollectDailyHistory(ctx, symbol); err != nil {
+			log.Fatalf("Failed to collect daily history: %v", err)
+		}
+		log.Printf("Daily history collection completed in %v", time.Since(start))
+
+	case "rebuild-summary":
+		// Regenerate summaries from stored minute data
+		start := time.Now()
+		if err := collector.database.RebuildDailySummaries(ctx, symbol); err != nil {
+			log.Fatalf("Failed to rebuild summaries: %v", err)
+		}
+		log.Printf("Summaries for %s rebuilt in %v", symbol, time.Since(start))
+
+	case "rename":
+		// Merge the symbol's data into its new ticker
+		if err := collector.database.RenameSymbol(ctx, symbol, renameTo); err != nil {
+			log.Fatalf("Failed to rename %s: %v", symbol, err)
+		}
+		log.Printf("Moved all data for %s to %s", symbol, renameTo)
+
+	case "dump":
+		// Write a portable JSON dump of the whole database
+		w := io.Writer(os.Stdout)
+		if dumpFile != "" {
+			file, err := os.Create(dumpFile)
+			if err != nil {
+				log.Fatalf("Failed to create dump file: %v", err)
+			}
+			defer file.Close()
+			w = file
+		}
+		if err := collector.database.ExportAll(ctx, w, dumpMinute); err != nil {
+			log.Fatalf("Failed to dump database: %v", err)
+		}
+		log.Printf("Database dumped")
+
+	case "restore":
+		// Load a dump written by -action=dump
+		r := io.Reader(os.Stdin)
+		if dumpFile != "" {
+			file, err := os.Open(dumpFile)
+			if err != nil {
+				log.Fatalf("Failed to open dump file: %v", err)
+			}
+			defer file.Close()
+			r = file
+		}
+		if err := collector.database.ImportAll(ctx, r); err != nil {
+			log.Fatalf("Failed to restore database: %v", err)
+		}
+		log.Printf("Database restored")
+
 	case "analyze":
 		// Analyze existing data
-		bars, err := collector.GetDataForAnalysis(symbol, days)
+		bars, err := collector.GetDataForAnalysis(ctx, symbol, days)
 		if err != nil {
 			log.Fatalf("Failed to get data for analysis: %v", err)
 		}
@@ -93,89 +451,54 @@ func runCLIMode(symbol string, days int, dbPath, action string) {
 		}
 
 		// Basic analysis
-		analyzeBars(bars)
+		if err := writeAnalysis(out, format, analyzeBars(bars)); err != nil {
+			log.Fatalf("Failed to write analysis: %v", err)
+		}
 
 	case "sample":
 		// Show sample data
-		if err := collector.DisplaySampleData(symbol, 10); err != nil {
+		if err := collector.DisplaySampleData(ctx, out, format, symbol, 10); err != nil {
 			log.Fatalf("Failed to display sample data: %v", err)
 		}
 
-	default:
-		log.Printf("Unknown action: %s", action)
-		log.Printf("Available actions: collect, analyze, sample")
-		os.Exit(1)
-	}
-}
-
-func analyzeBars(bars []MinuteBar) {
-	if len(bars) == 0 {
-		return
-	}
-
-	log.Printf("\n=== Basic Analysis ===")
-
-	// Price range
-	var minPrice, maxPrice float64 = bars[0].Close, bars[0].Close
-	var totalVolume int64 = 0
-
-	for _, bar := range bars {
-		if bar.Close < minPrice {
-			minPrice = bar.Close
-		}
-		if bar.Close > maxPrice {
-			maxPrice = bar.Close
+	case "vacuum":
+		// Compact the database file
+		sizeBefore, err := collector.database.FileSize()
+		if err != nil {
+			log.Fatalf("Failed to get database size: %v", err)
 		}
-		totalVolume += bar.Volume
-	}
-
-	latestPrice := bars[len(bars)-1].Close
-	firstPrice := bars[0].Close
-	priceChange := latestPrice - firstPrice
-	priceChangePercent := (priceChange / firstPrice) * 100
-
-	log.Printf("Data Points: %d", len(bars))
-	log.Printf("Date Range: %s to %s",
-		bars[0].Timestamp.Format("2006-01-02 15:04:05"),
-		bars[len(bars)-1].Timestamp.Format("2006-01-02 15:04:05"))
-	log.Printf("Price Range: $%.2f - $%.2f", minPrice, maxPrice)
-	log.Printf("Current Price: $%.2f", latestPrice)
-	log.Printf("Price Change: $%.2f (%.2f%%)", priceChange, priceChangePercent)
-	log.Printf("Total Volume: %d", totalVolume)
-
-	// Find highest and lowest trading days
-	findHighLowDays(bars)
 
-	// Average hourly volume
-	avgVolume := float64(totalVolume) / float64(len(bars))
-	log.Printf("Average Volume per Minute: %.0f", avgVolume)
-}
-
-func findHighLowDays(bars []MinuteBar) {
-	if len(bars) < 2 {
-		return
-	}
+		start := time.Now()
+		if err := collector.database.Vacuum(ctx); err != nil {
+			log.Fatalf("Failed to vacuum database: %v", err)
+		}
 
-	var maxVolumeBar, minPriceBar MinuteBar
-	maxVolume := int64(0)
-	minPrice := bars[0].Close
+		sizeAfter, err := collector.database.FileSize()
+		if err != nil {
+			log.Fatalf("Failed to get database size: %v", err)
+		}
+		log.Printf("Vacuum completed in %v", time.Since(start))
+		log.Printf("Database size: %d bytes -> %d bytes", sizeBefore, sizeAfter)
 
-	for _, bar := range bars {
-		if bar.Volume > maxVolume {
-			maxVolume = bar.Volume
-			maxVolumeBar = bar
+	case "healthcheck":
+		// Check the database and Yahoo connectivity
+		if err := collector.database.Ping(ctx); err != nil {
+			log.Fatalf("Database: FAILED (%v)", err)
 		}
-		if bar.Close < minPrice {
-			minPrice = bar.Close
-			minPriceBar = bar
+		log.Println("Database: OK")
+
+		ping, err := collector.yahooClient.Ping(ctx)
+		log.Printf("Yahoo host: %s", ping.Host)
+		log.Printf("Yahoo latency: %v", ping.Latency.Round(time.Millisecond))
+		log.Printf("Yahoo crumb valid: %v", ping.CrumbValid)
+		if err != nil {
+			log.Fatalf("Yahoo Finance: FAILED (%v)", err)
 		}
-	}
+		log.Println("Yahoo Finance: OK")
 
-	log.Printf("\n=== Notable Points ===")
-	log.Printf("Highest Volume Day: %s (Volume: %d, Price: $%.2f)",
-		maxVolumeBar.Timestamp.Format("2006-01-02 15:04:05"),
-		maxVolumeBar.Volume, maxVolumeBar.Close)
-	log.Printf("Lowest Price Point: %s (Price: $%.2f)",
-		minPriceBar.Timestamp.Format("2006-01-02 15:04:05"),
-		minPriceBar.Close)
-}
\ No newline at end of file
+	default:
+		log.Printf("Unknown action: %s", action)
+		log.Printf("Available actions: %s", strings.Join(cliActions, ", "))
+		os.Exit(1)
+	}
+}
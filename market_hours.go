@@ -0,0 +1,291 @@
+package main
+
+import (
+	"time"
+
+	// Embedded zone data so America/New_York resolves in minimal containers
+	_ "time/tzdata"
+)
+
+// Trading sessions a minute bar can fall in, by its time in New York
+const (
+	SessionRegular = "regular"
+	SessionPre     = "pre"
+	SessionPost    = "post"
+)
+
+// marketLocation is the exchange timezone sessions are defined in
+var marketLocation, _ = time.LoadLocation("America/New_York")
+
+// Regular session boundaries, New York time. On early-close days the session
+// ends at earlyCloseHour instead of regularCloseHour.
+const (
+	regularOpenHour   = 9
+	regularOpenMinute = 30
+	regularCloseHour  = 16
+	earlyCloseHour    = 13
+)
+
+// MarketSession returns the regular session window for the New York calendar
+// date of date, with the 13:00 close on early-close days. isTradingDay is false,
+// and open and close are zero, on weekends and NYSE holidays.
+func MarketSession(date time.Time) (open, close time.Time, isTradingDay bool) {
+	local := date.In(marketLocation)
+	if !isTradingDate(local) {
+		return time.Time{}, time.Time{}, false
+	}
+	open, close = sessionBounds(local)
+	return open, close, true
+}
+
+// sessionBounds returns the regular session window on the New York date of t,
+// whether or not that date is a trading day
+func sessionBounds(t time.Time) (open, close time.Time) {
+	year, month, day := t.In(marketLocation).Date()
+	open = time.Date(year, month, day, regularOpenHour, regularOpenMinute, 0, 0, marketLocation)
+
+	closeHour := regularCloseHour
+	if isEarlyClose(year, month, day) {
+		closeHour = earlyCloseHour
+	}
+	close = time.Date(year, month, day, closeHour, 0, 0, 0, marketLocation)
+	return open, close
+}
+
+// classifySession returns the session a bar starting at t belongs to:
+// pre-market before the 9:30 open, regular until the close (16:00, or 13:00
+// on early-close days), post-market after.
+func classifySession(t time.Time) string {
+	open, close := sessionBounds(t)
+
+	switch {
+	case t.Before(open):
+		return SessionPre
+	case t.Before(close):
+		return SessionRegular
+	default:
+		return SessionPost
+	}
+}
+
+// IsMarketOpen reports whether t falls in the regular session of a US
+// trading day, including early closes
+func IsMarketOpen(t time.Time) bool {
+	open, close, ok := MarketSession(t)
+	return ok && !t.Before(open) && t.Before(close)
+}
+
+// expectedBars returns how many 1-minute bars the regular session on the New
+// York calendar date of date spans: 390, 210 on early-close days, and 0 when
+// the market is closed
+func expectedBars(date time.Time) int {
+	// Summary dates are midnight UTC labels for the New York date
+	year, month, day := date.Date()
+	open, close, ok := MarketSession(time.Date(year, month, day, 12, 0, 0, 0, marketLocation))
+	if !ok {
+		return 0
+	}
+	return int(close.Sub(open) / time.Minute)
+}
+
+// Units a days query parameter can be counted in
+const (
+	DayUnitCalendar = "calendar"
+	DayUnitTrading  = "trading"
+)
+
+// lastClosedTradingDay returns New York midnight of the latest trading day
+// whose regular session had closed by now
+func lastClosedTradingDay(now time.Time) time.Time {
+	year, month, day := now.In(marketLocation).Date()
+	date := time.Date(year, month, day, 0, 0, 0, 0, marketLocation)
+	for {
+		if _, close, ok := MarketSession(date); ok && !close.After(now) {
+			return date
+		}
+		date = date.AddDate(0, 0, -1)
+	}
+}
+
+// tradingDaysStart returns New York midnight of the earliest of the last days
+// trading days up to and including now's date, so a range starting there
+// covers that many sessions regardless of weekends and holidays
+func tradingDaysStart(now time.Time, days int) time.Time {
+	year, month, day := now.In(marketLocation).Date()
+	date := time.Date(year, month, day, 0, 0, 0, 0, marketLocation)
+
+	for counted := 0; ; date = date.AddDate(0, 0, -1) {
+		if isTradingDate(date) {
+			counted++
+		}
+		if counted >= days {
+			return date
+		}
+	}
+}
+
+// tradingToCalendarDays converts a count of trading days to the calendar days
+// back from now's New York date that reach the earliest of them
+func tradingToCalendarDays(now time.Time, days int) int {
+	start := tradingDaysStart(now, days)
+	year, month, day := now.In(marketLocation).Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	return int(today.Sub(first).Hours() / 24)
+}
+
+// isTradingDate reports whether the New York calendar date of t is a weekday
+// that isn't an NYSE holiday
+func isTradingDate(t time.Time) bool {
+	local := t.In(marketLocation)
+	if weekday := local.Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+		return false
+	}
+	return !isMarketHoliday(local.Year(), local.Month(), local.Day())
+}
+
+// isMarketHoliday reports whether the date is a full-day NYSE closure. Fixed
+// holidays falling on a weekend are observed on the nearest weekday, except
+// New Year's Day on a Saturday, which is not observed.
+func isMarketHoliday(year int, month time.Month, day int) bool {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	holidays := []time.Time{
+		observed(time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		nthWeekday(year, time.January, time.Monday, 3),  // Martin Luther King Jr. Day
+		nthWeekday(year, time.February, time.Monday, 3), // Washington's Birthday
+		easter(year).AddDate(0, 0, -2),                  // Good Friday
+		lastWeekday(year, time.May, time.Monday),        // Memorial Day
+		observed(time.Date(year, time.July, 4, 0, 0, 0, 0, time.UTC)),
+		nthWeekday(year, time.September, time.Monday, 1),  // Labor Day
+		nthWeekday(year, time.November, time.Thursday, 4), // Thanksgiving
+		observed(time.Date(year, time.December, 25, 0, 0, 0, 0, time.UTC)),
+	}
+	if year >= 2022 {
+		holidays = append(holidays, observed(time.Date(year, time.June, 19, 0, 0, 0, 0, time.UTC)))
+	}
+
+	for _, holiday := range holidays {
+		if holiday.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEarlyClose reports whether NYSE closes at 13:00 on the date: the day
+// after Thanksgiving, and July 3 and December 24 when they are trading days
+func isEarlyClose(year int, month time.Month, day int) bool {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if weekday := date.Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+		return false
+	}
+	if isMarketHoliday(year, month, day) {
+		return false
+	}
+
+	switch {
+	case date.Equal(nthWeekday(year, time.November, time.Thursday, 4).AddDate(0, 0, 1)):
+		return true
+	case month == time.July && day == 3:
+		return true
+	case month == time.December && day == 24:
+		return true
+	}
+	return false
+}
+
+// observed moves a Sunday holiday to Monday and a Saturday one to Friday,
+// except New Year's Day, which NYSE doesn't observe on the prior Friday
+func observed(date time.Time) time.Time {
+	switch date.Weekday() {
+	case time.Sunday:
+		return date.AddDate(0, 0, 1)
+	case time.Saturday:
+		if date.Month() == time.January && date.Day() == 1 {
+			return time.Time{}
+		}
+		return date.AddDate(0, 0, -1)
+	}
+	return date
+}
+
+// nthWeekday returns the nth (1-based) given weekday of the month
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+7*(n-1))
+}
+
+// lastWeekday returns the last given weekday of the month
+func lastWeekday(year int, month time.Month, weekday time.Weekday) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+	return last.AddDate(0, 0, -offset)
+}
+
+// easter returns Western Easter Sunday (anonymous Gregorian algorithm)
+func easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// sessionOrRegular treats bars without a session as regular
+func sessionOrRegular(session string) string {
+	if session == "" {
+		return SessionRegular
+	}
+	return session
+}
+
+// Which bars daily summaries are built from. SummarySessionRegular uses the
+// regular session only, SummarySessionAll every stored bar, and
+// SummarySessionAuto the regular session when extended hours are collected,
+// otherwise all.
+const (
+	SummarySessionAuto    = "auto"
+	SummarySessionRegular = "regular"
+	SummarySessionAll     = "all"
+)
+
+// isValidSummarySession reports whether mode is a supported summary session mode
+func isValidSummarySession(mode string) bool {
+	return mode == SummarySessionAuto || mode == SummarySessionRegular || mode == SummarySessionAll
+}
+
+// regularSessionSummaries reports whether daily summaries should be built
+// from regular-session bars only under summary session mode
+func regularSessionSummaries(mode string, extendedHours bool) bool {
+	switch mode {
+	case SummarySessionRegular:
+		return true
+	case SummarySessionAll:
+		return false
+	default:
+		return extendedHours
+	}
+}
+
+// regularSessionOnly returns the bars in the regular session
+func regularSessionOnly(bars []MinuteBar) []MinuteBar {
+	regular := make([]MinuteBar, 0, len(bars))
+	for _, bar := range bars {
+		if sessionOrRegular(bar.Session) == SessionRegular {
+			regular = append(regular, bar)
+		}
+	}
+	return regular
+}
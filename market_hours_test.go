@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newYork returns the instant of a New York wall-clock time
+func newYork(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, marketLocation)
+}
+
+func TestClassifySession(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{name: "pre-market opens", t: newYork(2024, 3, 5, 4, 0), want: SessionPre},
+		{name: "minute before the open", t: newYork(2024, 3, 5, 9, 29), want: SessionPre},
+		{name: "open", t: newYork(2024, 3, 5, 9, 30), want: SessionRegular},
+		{name: "last regular minute", t: newYork(2024, 3, 5, 15, 59), want: SessionRegular},
+		{name: "close", t: newYork(2024, 3, 5, 16, 0), want: SessionPost},
+		{name: "late post-market", t: newYork(2024, 3, 5, 19, 59), want: SessionPost},
+		{name: "open during daylight saving, as UTC", t: time.Date(2024, 7, 9, 13, 30, 0, 0, time.UTC), want: SessionRegular},
+		{name: "pre-market in winter, as UTC", t: time.Date(2024, 1, 9, 14, 29, 0, 0, time.UTC), want: SessionPre},
+		{name: "open in winter, as UTC", t: time.Date(2024, 1, 9, 14, 30, 0, 0, time.UTC), want: SessionRegular},
+		{name: "before an early close", t: newYork(2024, 11, 29, 12, 59), want: SessionRegular},
+		{name: "early close", t: newYork(2024, 11, 29, 13, 0), want: SessionPost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifySession(tt.t); got != tt.want {
+				t.Errorf("classifySession(%s) = %q, want %q", tt.t.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegularSessionSummaries(t *testing.T) {
+	tests := []struct {
+		mode          string
+		extendedHours bool
+		want          bool
+	}{
+		{mode: SummarySessionRegular, extendedHours: false, want: true},
+		{mode: SummarySessionRegular, extendedHours: true, want: true},
+		{mode: SummarySessionAll, extendedHours: false, want: false},
+		{mode: SummarySessionAll, extendedHours: true, want: false},
+		{mode: SummarySessionAuto, extendedHours: false, want: false},
+		{mode: SummarySessionAuto, extendedHours: true, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := regularSessionSummaries(tt.mode, tt.extendedHours); got != tt.want {
+			t.Errorf("regularSessionSummaries(%q, %v) = %v, want %v", tt.mode, tt.extendedHours, got, tt.want)
+		}
+	}
+}
+
+func TestRegularSessionOnly(t *testing.T) {
+	at := newYork(2024, 3, 5, 10, 0)
+	bars := []MinuteBar{
+		{Timestamp: at.Add(-2 * time.Hour), Session: SessionPre},
+		{Timestamp: at, Session: SessionRegular},
+		{Timestamp: at.Add(time.Minute), Session: ""},
+		{Timestamp: at.Add(7 * time.Hour), Session: SessionPost},
+	}
+
+	got := regularSessionOnly(bars)
+	if len(got) != 2 || !got[0].Timestamp.Equal(at) || !got[1].Timestamp.Equal(at.Add(time.Minute)) {
+		t.Errorf("regularSessionOnly kept %+v, want the regular and unlabelled bars", got)
+	}
+}
+
+func TestMarketSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		date        time.Time
+		wantTrading bool
+		wantClose   int // New York hour
+		wantBars    int
+		openAt14    bool
+	}{
+		{name: "normal day", date: newYork(2024, 3, 5, 12, 0), wantTrading: true, wantClose: 16, wantBars: 390, openAt14: true},
+		{name: "day after Thanksgiving", date: newYork(2024, 11, 29, 12, 0), wantTrading: true, wantClose: 13, wantBars: 210},
+		{name: "July 3", date: newYork(2024, 7, 3, 12, 0), wantTrading: true, wantClose: 13, wantBars: 210},
+		{name: "Christmas Eve", date: newYork(2024, 12, 24, 12, 0), wantTrading: true, wantClose: 13, wantBars: 210},
+		{name: "Christmas", date: newYork(2024, 12, 25, 12, 0), wantTrading: false},
+		{name: "Good Friday", date: newYork(2024, 3, 29, 12, 0), wantTrading: false},
+		{name: "Juneteenth observed on a Monday", date: newYork(2022, 6, 20, 12, 0), wantTrading: false},
+		{name: "weekend", date: newYork(2024, 3, 9, 12, 0), wantTrading: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open, close, ok := MarketSession(tt.date)
+			if ok != tt.wantTrading {
+				t.Fatalf("isTradingDay = %v, want %v", ok, tt.wantTrading)
+			}
+			if got := expectedBars(time.Date(tt.date.Year(), tt.date.Month(), tt.date.Day(), 0, 0, 0, 0, time.UTC)); got != tt.wantBars {
+				t.Errorf("expectedBars = %d, want %d", got, tt.wantBars)
+			}
+			if got := IsMarketOpen(newYork(tt.date.Year(), tt.date.Month(), tt.date.Day(), 14, 0)); got != tt.openAt14 {
+				t.Errorf("IsMarketOpen at 14:00 = %v, want %v", got, tt.openAt14)
+			}
+			if !ok {
+				if !open.IsZero() || !close.IsZero() {
+					t.Errorf("closed day has session %s-%s", open, close)
+				}
+				return
+			}
+
+			if open.Hour() != 9 || open.Minute() != 30 {
+				t.Errorf("open = %s, want 09:30", open.Format("15:04"))
+			}
+			if close.Hour() != tt.wantClose || close.Minute() != 0 {
+				t.Errorf("close = %s, want %02d:00", close.Format("15:04"), tt.wantClose)
+			}
+		})
+	}
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutMiddleware bounds each request with a deadline. Handlers pass
+// c.Request.Context() down to the collector and database, so when the deadline
+// expires the in-flight fetch is cancelled and the client gets a 504.
+//
+// Only handlers that build their whole response before writing it may be
+// wrapped. Once a streaming handler has sent its status the 504 can no longer
+// be delivered, and the deadline just truncates a 200 body; such routes (SSE
+// sync, streamed exports) must stay unwrapped and manage their own deadlines.
+// A wrapped handler caught mid-body is logged so the mistake shows up.
+func timeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if ctx.Err() != context.DeadlineExceeded {
+			return
+		}
+		switch {
+		case !c.Writer.Written():
+			// Fallback for handlers that returned without writing a response
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+		case c.Writer.Status() != http.StatusGatewayTimeout:
+			log.Printf("Warning: %s %s hit its deadline after responding %d; the body may be truncated",
+				c.Request.Method, c.Request.URL.Path, c.Writer.Status())
+		}
+	}
+}
+
+// gzipMiddleware compresses responses for clients that accept gzip once the
+// body reaches minLength bytes; smaller bodies are sent as-is. Server-sent
+// event streams are skipped so each event still flushes immediately.
+func gzipMiddleware(minLength int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if minLength <= 0 ||
+			!strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") ||
+			c.Request.Method == http.MethodHead ||
+			c.GetHeader("Range") != "" ||
+			strings.HasSuffix(c.Request.URL.Path, "/stream") {
+			c.Next()
+			return
+		}
+
+		c.Header("Vary", "Accept-Encoding")
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer, minLength: minLength}
+		c.Writer = writer
+		defer writer.finish()
+
+		c.Next()
+	}
+}
+
+// gzipResponseWriter buffers the body until it knows whether the response is
+// large enough to compress, then switches to writing through gzip or raw
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	minLength int
+	buf       []byte
+	decided   bool
+	gz        *gzip.Writer
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		return w.writeThrough(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minLength {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap exposes the underlying writer, so http.ResponseController can reach
+// the connection to clear its write deadline
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Written reports buffered output too, so the timeout fallback doesn't
+// overwrite a response that hasn't reached the client yet
+func (w *gzipResponseWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush commits to the current compression decision and pushes data out
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.decide(len(w.buf) >= w.minLength)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide writes the buffered body, compressed if compress is true and the
+// handler hasn't already set its own Content-Encoding
+func (w *gzipResponseWriter) decide(compress bool) error {
+	w.decided = true
+
+	header := w.Header()
+	if compress && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.writeThrough(buf)
+	return err
+}
+
+func (w *gzipResponseWriter) writeThrough(data []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// finish sends a body that never reached minLength uncompressed and closes
+// the gzip stream otherwise
+func (w *gzipResponseWriter) finish() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
+
+// idempotencyTTL is how long a completed response is replayed for its key
+const idempotencyTTL = 10 * time.Minute
+
+// maxIdempotencyKeyLength bounds the keys kept in memory
+const maxIdempotencyKeyLength = 255
+
+// idempotentResponse is a response recorded for an Idempotency-Key. done is
+// closed once the first request with the key has finished.
+type idempotentResponse struct {
+	done        chan struct{}
+	completed   bool
+	status      int
+	contentType string
+	body        []byte
+	expires     time.Time
+}
+
+// idempotencyStore remembers recent responses by Idempotency-Key, in memory
+type idempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]*idempotentResponse
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{entries: make(map[string]*idempotentResponse)}
+}
+
+// begin returns the entry for key and whether the caller is the first request
+// with it, which must run the handler and then call finish
+func (s *idempotencyStore) begin(key string) (*idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range s.entries {
+		if entry.completed && now.After(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+
+	if entry, ok := s.entries[key]; ok {
+		return entry, false
+	}
+	entry := &idempotentResponse{done: make(chan struct{})}
+	s.entries[key] = entry
+	return entry, true
+}
+
+// finish records the response for key and releases requests waiting on it.
+// Server errors and handlers that wrote nothing aren't recorded, so a retry
+// with the same key runs again.
+func (s *idempotencyStore) finish(key string, entry *idempotentResponse, status int, contentType string, body []byte) {
+	s.mu.Lock()
+	if status == 0 || status >= http.StatusInternalServerError {
+		delete(s.entries, key)
+	} else {
+		entry.completed = true
+		entry.status = status
+		entry.contentType = contentType
+		entry.body = body
+		entry.expires = time.Now().Add(idempotencyTTL)
+	}
+	s.mu.Unlock()
+
+	close(entry.done)
+}
+
+// idempotencyMiddleware makes POST routes safe to repeat: a request carrying
+// an Idempotency-Key already seen for the same route within idempotencyTTL gets
+// the first request's response replayed instead of running again. A repeat
+// that arrives while the first is still running waits for it. Requests without
+// the header run normally.
+func idempotencyMiddleware(store *idempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		scopedKey := TenantFromContext(c.Request.Context()) + " " + c.Request.Method + " " + c.Request.URL.Path + " " + key
+		entry, first := store.begin(scopedKey)
+		if !first {
+			select {
+			case <-entry.done:
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+			// The first request failed without recording; run this one
+			if entry.status == 0 {
+				c.Next()
+				return
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(entry.status, entry.contentType, entry.body)
+			c.Abort()
+			return
+		}
+
+		writer := &recordingResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			status := 0
+			if writer.Written() {
+				status = writer.Status()
+			}
+			store.finish(scopedKey, entry, status, writer.Header().Get("Content-Type"), writer.body)
+		}()
+
+		c.Next()
+	}
+}
+
+// recordingResponseWriter keeps a copy of the body written through it
+type recordingResponseWriter struct {
+	gin.ResponseWriter
+	body []byte
+}
+
+func (w *recordingResponseWriter) Write(data []byte) (int, error) {
+	w.body = append(w.body, data...)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (w *recordingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// requestIDKey is the gin context key holding the request's id
+const requestIDKey = "requestID"
+
+// maxRequestIDLength bounds client-supplied X-Request-ID values
+const maxRequestIDLength = 128
+
+// requestIDMiddleware tags each request with the client's X-Request-ID, or a
+// generated one when it's missing or unusable, and echoes it in the response
+// so clients can correlate their requests with the server logs
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header("X-Request-ID", id)
+		c.Next()
+	}
+}
+
+// tenantMiddleware scopes the request to the watchlist named by its
+// X-Tenant-ID header, or the default tenant's without one
+func tenantMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant := c.GetHeader("X-Tenant-ID")
+		if tenant == "" {
+			c.Next()
+			return
+		}
+		if !isValidTenantID(tenant) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid X-Tenant-ID: use up to 64 letters, digits, '-' or '_'"})
+			return
+		}
+
+		c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), tenant))
+		c.Next()
+	}
+}
+
+// isValidRequestID accepts non-empty printable ASCII ids without spaces
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, char := range id {
+		if char <= ' ' || char > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns 16 random bytes as hex
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// requestLogger is gin's access log with the request id appended
+func requestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		requestID, _ := param.Keys[requestIDKey].(string)
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v | id=%s\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency,
+			param.ClientIP,
+			param.Method,
+			param.Path,
+			requestID,
+			param.ErrorMessage,
+		)
+	})
+}
+
+// envelopeMiddleware wraps JSON responses as {data, error, requestId}: data
+// holds the handler's body on success, error the handler's error message on
+// 4xx/5xx. Non-JSON responses such as CSV exports and event streams, and
+// bodiless responses like 304s, pass through unchanged.
+func envelopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writer := &envelopeResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer writer.finish(c.GetString(requestIDKey))
+
+		c.Next()
+	}
+}
+
+// envelopeResponseWriter buffers a JSON body so it can be wrapped once the
+// handler is done; anything else is written straight through
+type envelopeResponseWriter struct {
+	gin.ResponseWriter
+	decided bool
+	buffer  bool
+	buf     []byte
+}
+
+func (w *envelopeResponseWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.buffer = strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+	}
+	if !w.buffer {
+		return w.ResponseWriter.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	return len(data), nil
+}
+
+func (w *envelopeResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap lets v2 streaming handlers reach the connection through the envelope
+func (w *envelopeResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Written reports buffered output too, so the timeout fallback doesn't append
+// a second body
+func (w *envelopeResponseWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// finish writes the buffered body inside the envelope
+func (w *envelopeResponseWriter) finish(requestID string) {
+	if !w.buffer {
+		return
+	}
+
+	envelope := APIEnvelope{RequestID: requestID}
+	if status := w.Status(); status >= http.StatusBadRequest {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(w.buf, &failure); err != nil || failure.Error == "" {
+			failure.Error = http.StatusText(status)
+		}
+		envelope.Error = &failure.Error
+	} else if json.Valid(w.buf) {
+		envelope.Data = json.RawMessage(w.buf)
+	}
+
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		body = w.buf
+	}
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	// blockingProvider stands in for a Yahoo fetch that never answers: it
+	// returns only once the request context is done, without responding
+	blockingProvider := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(5 * time.Second):
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		}
+	}
+	fastProvider := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		handler gin.HandlerFunc
+		want    int
+		maxTime time.Duration
+	}{
+		{name: "blocking provider times out", timeout: 50 * time.Millisecond, handler: blockingProvider, want: http.StatusGatewayTimeout, maxTime: 2 * time.Second},
+		{name: "fast provider answers", timeout: time.Second, handler: fastProvider, want: http.StatusOK, maxTime: time.Second},
+		{name: "zero timeout disables the deadline", timeout: 0, handler: fastProvider, want: http.StatusOK, maxTime: time.Second},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", timeoutMiddleware(tt.timeout), tt.handler)
+
+			start := time.Now()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > tt.maxTime {
+				t.Errorf("took %v, want under %v", elapsed, tt.maxTime)
+			}
+		})
+	}
+}
+
+func TestClearWriteDeadline(t *testing.T) {
+	// The handler clears the server's write deadline the way the streaming
+	// handlers do, then answers after the deadline would have passed
+	const writeTimeout = 100 * time.Millisecond
+	body := strings.Repeat("x", 2048)
+	handler := func(c *gin.Context) {
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		time.Sleep(3 * writeTimeout)
+		c.String(http.StatusOK, body)
+	}
+
+	tests := []struct {
+		name       string
+		middleware []gin.HandlerFunc
+		headers    map[string]string
+	}{
+		{name: "plain", middleware: nil},
+		{name: "gzip", middleware: []gin.HandlerFunc{gzipMiddleware(1024)}},
+		{name: "v2 envelope", middleware: []gin.HandlerFunc{gzipMiddleware(1024), envelopeMiddleware()}},
+		{
+			name:       "idempotency recording",
+			middleware: []gin.HandlerFunc{idempotencyMiddleware(newIdempotencyStore())},
+			headers:    map[string]string{"Idempotency-Key": "deadline"},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", append(tt.middleware, handler)...)
+
+			server := httptest.NewUnstartedServer(router)
+			server.Config.WriteTimeout = writeTimeout
+			server.Start()
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			// The default transport asks for gzip and decompresses the body
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("read body: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, http.StatusOK, got)
+			}
+			if string(got) != body {
+				t.Errorf("got %d bytes, want %d", len(got), len(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Numbered schema migrations. Each migration runs once per database file and
+// is recorded in the migrations table, so new columns and data transforms can
+// be applied repeatably to existing stock_data.db files. Append new migrations
+// to the end of the list; never reorder or edit ones that have shipped.
+var migrations = []*gormigrate.Migration{
+	{
+		ID: "001_initial_schema",
+		Migrate: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(initialModels...); err != nil {
+				return fmt.Errorf("failed to auto migrate: %v", err)
+			}
+			return createAdditionalIndexes(tx)
+		},
+	},
+	{
+		ID: "002_weekly_monthly_summary",
+		Migrate: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&StockWeeklySummary{}, &StockMonthlySummary{}); err != nil {
+				return fmt.Errorf("failed to create period summary tables: %v", err)
+			}
+			if err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_weekly_summary_symbol_date_unique ON stock_weekly_summary(symbol, date)").Error; err != nil {
+				return fmt.Errorf("failed to create weekly summary unique index: %v", err)
+			}
+			if err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_monthly_summary_symbol_date_unique ON stock_monthly_summary(symbol, date)").Error; err != nil {
+				return fmt.Errorf("failed to create monthly summary unique index: %v", err)
+			}
+			return backfillPeriodSummaries(tx)
+		},
+	},
+	{
+		ID: "003_watched_stock_exchange_currency",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &WatchedStock{}, "Exchange", "Currency")
+		},
+	},
+	{
+		ID: "004_minute_data_currency",
+		Migrate: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &StockMinuteData{}, "Currency"); err != nil {
+				return err
+			}
+			// Everything collected before currencies were tracked was priced in USD
+			if err := tx.Exec("UPDATE stock_minute_data SET currency = 'USD' WHERE currency IS NULL OR currency = ''").Error; err != nil {
+				return fmt.Errorf("failed to backfill minute data currency: %v", err)
+			}
+			if err := tx.Exec("UPDATE watched_stocks SET currency = 'USD' WHERE currency IS NULL OR currency = ''").Error; err != nil {
+				return fmt.Errorf("failed to backfill watched stock currency: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		ID: "005_watched_stock_sort_order",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &WatchedStock{}, "SortOrder")
+		},
+	},
+	{
+		ID: "006_corporate_events",
+		Migrate: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&CorporateEvent{}); err != nil {
+				return fmt.Errorf("failed to create corporate events table: %v", err)
+			}
+			if err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_corporate_events_unique ON corporate_events(symbol, type, date)").Error; err != nil {
+				return fmt.Errorf("failed to create corporate events unique index: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		ID: "007_fixed_point_prices",
+		Migrate: func(tx *gorm.DB) error {
+			// Prices become integer ten-thousandths (see Price). Weekly and monthly
+			// rows are rebuilt from the converted daily rows instead of scaled, since
+			// a backfill by an earlier migration in this same run already read the
+			// daily prices as Price.
+			for _, table := range []string{"stock_minute_data", "stock_daily_summary"} {
+				err := tx.Exec(fmt.Sprintf(
+					"UPDATE %s SET open = CAST(ROUND(open * %d) AS INTEGER), high = CAST(ROUND(high * %d) AS INTEGER), "+
+						"low = CAST(ROUND(low * %d) AS INTEGER), close = CAST(ROUND(close * %d) AS INTEGER)",
+					table, priceScale, priceScale, priceScale, priceScale)).Error
+				if err != nil {
+					return fmt.Errorf("failed to convert %s prices: %v", table, err)
+				}
+			}
+			return backfillPeriodSummaries(tx)
+		},
+	},
+	{
+		ID: "008_watched_stock_precision",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &WatchedStock{}, "Precision")
+		},
+	},
+	{
+		ID: "009_minute_data_session",
+		Migrate: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &StockMinuteData{}, "Session"); err != nil {
+				return err
+			}
+			return backfillSessions(tx)
+		},
+	},
+	{
+		ID: "010_daily_summary_bar_count",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &StockDailySummary{}, "BarCount")
+		},
+	},
+	{
+		ID: "011_daily_summary_vwap",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &StockDailySummary{}, "VWAP")
+		},
+	},
+	{
+		// Minute bars used to be stored in the server's local zone; rewrite
+		// them as UTC so string comparisons match UTC query bounds. A bar
+		// stored twice under different offsets collapses into one.
+		ID: "012_minute_timestamps_utc",
+		Migrate: func(tx *gorm.DB) error {
+			err := tx.Exec(`UPDATE OR REPLACE stock_minute_data
+				SET timestamp = strftime('%Y-%m-%d %H:%M:%S', timestamp) || '+00:00'
+				WHERE timestamp NOT LIKE '%+00:00'`).Error
+			if err != nil {
+				return fmt.Errorf("failed to convert minute timestamps to UTC: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		// Watchlists become per tenant: existing entries go to the default
+		// tenant, and a symbol only has to be unique within its tenant
+		ID: "013_watched_stock_tenant",
+		Migrate: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &WatchedStock{}, "Tenant"); err != nil {
+				return err
+			}
+			if err := tx.Exec("DROP INDEX IF EXISTS idx_watched_stocks_symbol").Error; err != nil {
+				return fmt.Errorf("failed to drop watched stock symbol index: %v", err)
+			}
+			if err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_watched_stocks_tenant_symbol ON watched_stocks(tenant, symbol)").Error; err != nil {
+				return fmt.Errorf("failed to create watched stock tenant index: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		ID: "014_rejected_bars",
+		Migrate: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&RejectedBar{}); err != nil {
+				return fmt.Errorf("failed to create rejected bars table: %v", err)
+			}
+			if err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_rejected_bars_unique ON rejected_bars(symbol, timestamp)").Error; err != nil {
+				return fmt.Errorf("failed to create rejected bars unique index: %v", err)
+			}
+			return nil
+		},
+	},
+}
+
+// The tables as migration 001 shipped them. They are frozen here, rather than
+// taken from the live models, so 001 creates the same schema on every release
+// and the later migrations always start from what they expect; columns and
+// tables added since belong to the migration that introduced them.
+
+type initialMinuteData struct {
+	ID        uint      `gorm:"primaryKey"`
+	Symbol    string    `gorm:"index:idx_symbol;not null"`
+	Timestamp time.Time `gorm:"index:idx_timestamp;not null"`
+	Open      float64   `gorm:"not null"`
+	High      float64   `gorm:"not null"`
+	Low       float64   `gorm:"not null"`
+	Close     float64   `gorm:"not null"`
+	Volume    int64     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (initialMinuteData) TableName() string { return "stock_minute_data" }
+
+type initialWatchedStock struct {
+	ID        uint       `gorm:"primaryKey"`
+	Symbol    string     `gorm:"uniqueIndex;index:idx_watched_stocks_symbol;not null"`
+	Name      string     `gorm:""`
+	AddedAt   time.Time  `gorm:"autoCreateTime"`
+	LastSync  *time.Time `gorm:""`
+	IsActive  bool       `gorm:"default:true;not null"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
+}
+
+func (initialWatchedStock) TableName() string { return "watched_stocks" }
+
+type initialDailySummary struct {
+	ID        uint      `gorm:"primaryKey"`
+	Symbol    string    `gorm:"index:idx_daily_summary_symbol_date;not null"`
+	Date      time.Time `gorm:"index:idx_daily_summary_symbol_date;not null"`
+	Open      float64   `gorm:"not null"`
+	High      float64   `gorm:"not null"`
+	Low       float64   `gorm:"not null"`
+	Close     float64   `gorm:"not null"`
+	Volume    int64     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+func (initialDailySummary) TableName() string { return "stock_daily_summary" }
+
+var initialModels = []interface{}{
+	&initialMinuteData{},
+	&initialWatchedStock{},
+	&initialDailySummary{},
+}
+
+// runMigrations applies all pending migrations in order
+func runMigrations(db *gorm.DB) error {
+	m := gormigrate.New(db, gormigrate.DefaultOptions, migrations)
+	if err := m.Migrate(); err != nil {
+		return fmt.Errorf("failed to run migrations: %v", err)
+	}
+	return nil
+}
+
+// backfillPeriodSummaries builds weekly and monthly summaries from all existing daily summaries
+func backfillPeriodSummaries(tx *gorm.DB) error {
+	var days []StockDailySummary
+	if err := tx.Select("symbol", "date").Find(&days).Error; err != nil {
+		return fmt.Errorf("failed to load daily summaries: %v", err)
+	}
+
+	datesBySymbol := make(map[string][]time.Time)
+	for _, day := range days {
+		datesBySymbol[day.Symbol] = append(datesBySymbol[day.Symbol], day.Date)
+	}
+
+	for symbol, dates := range datesBySymbol {
+		if err := updatePeriodSummaries(tx, weeklyPeriod, symbol, dates); err != nil {
+			return err
+		}
+		if err := updatePeriodSummaries(tx, monthlyPeriod, symbol, dates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillSessions tags existing minute bars outside 9:30-16:00 New York time
+// as pre or post; the column default already marks the rest regular
+func backfillSessions(tx *gorm.DB) error {
+	idsBySession := make(map[string][]uint)
+	var rows []StockMinuteData
+	result := tx.Select("id", "timestamp").FindInBatches(&rows, 5000, func(_ *gorm.DB, _ int) error {
+		for _, row := range rows {
+			if session := classifySession(row.Timestamp); session != SessionRegular {
+				idsBySession[session] = append(idsBySession[session], row.ID)
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to load minute data timestamps: %v", result.Error)
+	}
+
+	for session, ids := range idsBySession {
+		for start := 0; start < len(ids); start += 500 {
+			end := start + 500
+			if end > len(ids) {
+				end = len(ids)
+			}
+			err := tx.Model(&StockMinuteData{}).Where("id IN ?", ids[start:end]).Update("session", session).Error
+			if err != nil {
+				return fmt.Errorf("failed to backfill %s session: %v", session, err)
+			}
+		}
+	}
+	return nil
+}
+
+// addColumns adds the given model fields as columns if they don't already exist
+func addColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	migrator := tx.Migrator()
+	for _, field := range fields {
+		if migrator.HasColumn(model, field) {
+			continue
+		}
+		if err := migrator.AddColumn(model, field); err != nil {
+			return fmt.Errorf("failed to add column %s: %v", field, err)
+		}
+	}
+	return nil
+}
+
+// createAdditionalIndexes creates indexes that are not easily covered by GORM tags
+func createAdditionalIndexes(tx *gorm.DB) error {
+	// Create composite unique index for (symbol, timestamp) in stock_minute_data
+	if err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_symbol_timestamp_unique ON stock_minute_data(symbol, timestamp)").Error; err != nil {
+		return fmt.Errorf("failed to create unique index: %v", err)
+	}
+
+	// Remove duplicate daily summaries left by the old FirstOrCreate/Update logic
+	// before enforcing uniqueness on (symbol, date)
+	if err := tx.Exec("DELETE FROM stock_daily_summary WHERE id NOT IN (SELECT MAX(id) FROM stock_daily_summary GROUP BY symbol, date)").Error; err != nil {
+		return fmt.Errorf("failed to remove duplicate daily summaries: %v", err)
+	}
+
+	// Create composite unique index for (symbol, date) in stock_daily_summary
+	if err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_summary_symbol_date_unique ON stock_daily_summary(symbol, date)").Error; err != nil {
+		return fmt.Errorf("failed to create daily summary unique index: %v", err)
+	}
+
+	return nil
+}
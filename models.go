@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -11,9 +12,18 @@ type WatchedStockAPI struct {
 	ID        int       `json:"id"`
 	Symbol    string    `json:"symbol"`
 	Name      string    `json:"name"`
+	Exchange  string    `json:"exchange"`
+	Currency  string    `json:"currency"`
 	AddedAt   time.Time `json:"addedAt"`
 	LastSync  *time.Time `json:"lastSync"`
 	IsActive  bool      `json:"isActive"`
+	SortOrder int       `json:"sortOrder"`
+	Precision int       `json:"precision"`
+
+	// Sync health, computed per request
+	IsStale             bool       `json:"isStale"`
+	RecordCount         int64      `json:"recordCount"`
+	LatestDataTimestamp *time.Time `json:"latestDataTimestamp"`
 }
 
 // DailySummaryAPI is the API-compatible version of StockDailySummary
@@ -26,23 +36,98 @@ type DailySummaryAPI struct {
 	Low      float64   `json:"low"`
 	Close    float64   `json:"close"`
 	Volume   int64     `json:"volume"`
-	CreateAt time.Time `json:"createdAt"`
+	BarCount int       `json:"barCount"`
+	VWAP     float64   `json:"vwap,omitempty"`
+	// Completeness is BarCount over the minute bars expected in the day's
+	// regular session (390, or 210 on early closes); omitted when unknown
+	Completeness *float64  `json:"completeness,omitempty"`
+	CreateAt     time.Time `json:"createdAt"`
 }
 
 type StockSummary struct {
 	Symbol       string            `json:"symbol"`
 	Name         string            `json:"name"`
+	Currency     string            `json:"currency"`
 	CurrentPrice float64           `json:"currentPrice"`
 	Change       float64           `json:"change"`
 	ChangePercent float64          `json:"changePercent"`
 	LastUpdate   time.Time         `json:"lastUpdate"`
 	DailyData    []DailySummaryAPI `json:"dailyData"`
 	IsActive     bool              `json:"isActive"`
+	// Timezone is the zone the REST API expressed the times in (?tz=)
+	Timezone string `json:"timezone,omitempty"`
+	// Stale is set when the latest bar was older than -refresh-stale-after
+	// during market hours; a background sync has been started
+	Stale bool `json:"stale,omitempty"`
+}
+
+// LatestPrice is a symbol's most recent price and its change against the
+// previous daily close
+type LatestPrice struct {
+	Symbol        string    `json:"symbol"`
+	Price         float64   `json:"price"`
+	Timestamp     time.Time `json:"timestamp"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"changePercent"`
+}
+
+// APIEnvelope is the /api/v2 response shape. Data is null on errors and Error
+// is null on success.
+type APIEnvelope struct {
+	Data      json.RawMessage `json:"data"`
+	Error     *string         `json:"error"`
+	RequestID string          `json:"requestId"`
+}
+
+// SpotPrice is a live price read from Yahoo, not from stored minute data
+type SpotPrice struct {
+	Symbol        string    `json:"symbol"`
+	Price         float64   `json:"price"`
+	Currency      string    `json:"currency"`
+	Timestamp     time.Time `json:"timestamp"`
+	PreviousClose float64   `json:"previousClose"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"changePercent"`
+}
+
+// MoversResponse lists the watched stocks that moved most over a period
+type MoversResponse struct {
+	Days    int           `json:"days"`
+	Gainers []LatestPrice `json:"gainers"`
+	Losers  []LatestPrice `json:"losers"`
 }
 
 type AddStockRequest struct {
 	Symbol string `json:"symbol" binding:"required"`
 	Name   string `json:"name,omitempty"`
+
+	// Precision is the decimal places stored prices keep, 0-4; defaults to 2
+	Precision *int `json:"precision,omitempty"`
+}
+
+type BatchAddStocksRequest struct {
+	Symbols []AddStockRequest `json:"symbols" binding:"required"`
+}
+
+// Per-symbol outcomes of a batch add
+const (
+	BatchStatusAdded   = "added"
+	BatchStatusSkipped = "skipped"
+	BatchStatusInvalid = "invalid"
+)
+
+type BatchAddResult struct {
+	Symbol string `json:"symbol"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type UpdateStockRequest struct {
+	IsActive *bool `json:"isActive" binding:"required"`
+}
+
+type ReorderStocksRequest struct {
+	Symbols []string `json:"symbols" binding:"required"`
 }
 
 type SyncResponse struct {
@@ -50,6 +135,11 @@ type SyncResponse struct {
 	Message     string `json:"message"`
 	RecordsAdded int   `json:"recordsAdded"`
 	LatestDate  string `json:"latestDate"`
+	Added       bool   `json:"added,omitempty"`
+	// Symbol is the ticker synced when the request named an alias of it,
+	// given in ResolvedFrom
+	Symbol       string `json:"symbol,omitempty"`
+	ResolvedFrom string `json:"resolvedFrom,omitempty"`
 }
 
 type StockSearchResult struct {
@@ -57,4 +147,25 @@ type StockSearchResult struct {
 	Name      string `json:"name"`
 	ChineseName string `json:"chineseName"`
 	FullName  string `json:"fullName"`
-}
\ No newline at end of file
+}
+
+type BacktestRequest struct {
+	Symbol      string  `json:"symbol" binding:"required"`
+	Fast        int     `json:"fast"`
+	Slow        int     `json:"slow"`
+	Days        int     `json:"days"`
+	InitialCash float64 `json:"initialCash"`
+}
+
+// OHLCSeries is bar data as parallel column arrays for charting libraries.
+// T is Unix seconds, oldest first; index i of every array is the same bar.
+type OHLCSeries struct {
+	Symbol      string    `json:"symbol"`
+	Granularity string    `json:"granularity"`
+	T           []int64   `json:"t"`
+	O           []float64 `json:"o"`
+	H           []float64 `json:"h"`
+	L           []float64 `json:"l"`
+	C           []float64 `json:"c"`
+	V           []int64   `json:"v"`
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+)
+
+// priceScale is the number of Price units per currency unit, giving
+// priceDecimals places of precision
+const (
+	priceScale    = 10000
+	priceDecimals = 4
+)
+
+// Price is a fixed-point price stored as an integer count of ten-thousandths
+// (12.3456 is stored as 123456), so sums and aggregates over stored prices
+// don't pick up float rounding artifacts. Prices convert to float64 only at
+// the API boundary.
+type Price int64
+
+// defaultPricePrecision is the decimal places prices are rounded to for
+// symbols without their own precision
+const defaultPricePrecision = 2
+
+// NewPrice converts a float price, rounding to the nearest ten-thousandth
+func NewPrice(value float64) Price {
+	return Price(math.Round(value * priceScale))
+}
+
+// roundPrice rounds value to places decimals, at most priceDecimals, as a Price
+func roundPrice(value float64, places int) Price {
+	if places > priceDecimals {
+		places = priceDecimals
+	}
+	return Price(math.Round(value*math.Pow10(places)) * math.Pow10(priceDecimals-places))
+}
+
+// Float64 returns the price as a float for JSON and calculations
+func (p Price) Float64() float64 {
+	return float64(p) / priceScale
+}
+
+// Value stores the price as an integer
+func (p Price) Value() (driver.Value, error) {
+	return int64(p), nil
+}
+
+// Scan reads an integer price. Columns created before prices were fixed-point
+// keep REAL affinity in SQLite and come back as float64 holding a whole number
+// of units, which is rounded rather than truncated.
+func (p *Price) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case int64:
+		*p = Price(v)
+	case float64:
+		*p = Price(math.Round(v))
+	case nil:
+		*p = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Price", value)
+	}
+	return nil
+}
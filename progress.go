@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+const progressBarWidth = 30
+
+// progressBar renders a single-line terminal progress bar for a multi-batch
+// Yahoo fetch. Its Update method is a ProgressFunc.
+type progressBar struct {
+	mu    sync.Mutex
+	out   io.Writer
+	label string
+
+	batch, totalBatches, bars int
+	drawn                     bool
+}
+
+// newProgressBar creates a progress bar drawn on out
+func newProgressBar(out io.Writer, label string) *progressBar {
+	return &progressBar{out: out, label: label}
+}
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Update records a completed batch and redraws the bar
+func (p *progressBar) Update(batch, totalBatches, barsSoFar int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.batch, p.totalBatches, p.bars = batch, totalBatches, barsSoFar
+	p.draw()
+}
+
+// Finish ends the bar's line so later output starts on a fresh one
+func (p *progressBar) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.drawn {
+		fmt.Fprintln(p.out)
+		p.drawn = false
+	}
+}
+
+// draw rewrites the current line; callers hold mu
+func (p *progressBar) draw() {
+	filled := 0
+	if p.totalBatches > 0 {
+		filled = progressBarWidth * p.batch / p.totalBatches
+		if filled > progressBarWidth {
+			filled = progressBarWidth
+		}
+	}
+
+	fmt.Fprintf(p.out, "\r\033[K%s [%s%s] batch %d/%d, %d bars",
+		p.label, strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+		p.batch, p.totalBatches, p.bars)
+	p.drawn = true
+}
+
+// logWriter wraps w so each write first clears the bar, then redraws it
+// after, keeping log lines from being mangled by the bar on a shared terminal
+func (p *progressBar) logWriter(w io.Writer) io.Writer {
+	return progressLogWriter{bar: p, w: w}
+}
+
+type progressLogWriter struct {
+	bar *progressBar
+	w   io.Writer
+}
+
+func (lw progressLogWriter) Write(b []byte) (int, error) {
+	lw.bar.mu.Lock()
+	defer lw.bar.mu.Unlock()
+
+	if !lw.bar.drawn {
+		return lw.w.Write(b)
+	}
+
+	fmt.Fprint(lw.bar.out, "\r\033[K")
+	n, err := lw.w.Write(b)
+	lw.bar.draw()
+	return n, err
+}
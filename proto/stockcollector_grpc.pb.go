@@ -0,0 +1,228 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: stockcollector.proto
+
+package stockpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	StockCollector_Collect_FullMethodName    = "/stockcollector.StockCollector/Collect"
+	StockCollector_GetSummary_FullMethodName = "/stockcollector.StockCollector/GetSummary"
+	StockCollector_GetData_FullMethodName    = "/stockcollector.StockCollector/GetData"
+)
+
+// StockCollectorClient is the client API for StockCollector service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// StockCollector exposes collection and queries for service-to-service use,
+// mirroring the sync, summary and data REST endpoints.
+type StockCollectorClient interface {
+	// Collect fetches minute data from Yahoo Finance for the last N days,
+	// incrementally when data already exists.
+	Collect(ctx context.Context, in *CollectRequest, opts ...grpc.CallOption) (*CollectResponse, error)
+	// GetSummary returns the latest price and daily, weekly or monthly bars.
+	GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*GetSummaryResponse, error)
+	// GetData streams stored minute bars between start and end, oldest first.
+	GetData(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (StockCollector_GetDataClient, error)
+}
+
+type stockCollectorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStockCollectorClient(cc grpc.ClientConnInterface) StockCollectorClient {
+	return &stockCollectorClient{cc}
+}
+
+func (c *stockCollectorClient) Collect(ctx context.Context, in *CollectRequest, opts ...grpc.CallOption) (*CollectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CollectResponse)
+	err := c.cc.Invoke(ctx, StockCollector_Collect_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stockCollectorClient) GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*GetSummaryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSummaryResponse)
+	err := c.cc.Invoke(ctx, StockCollector_GetSummary_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stockCollectorClient) GetData(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (StockCollector_GetDataClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StockCollector_ServiceDesc.Streams[0], StockCollector_GetData_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &stockCollectorGetDataClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type StockCollector_GetDataClient interface {
+	Recv() (*MinuteBar, error)
+	grpc.ClientStream
+}
+
+type stockCollectorGetDataClient struct {
+	grpc.ClientStream
+}
+
+func (x *stockCollectorGetDataClient) Recv() (*MinuteBar, error) {
+	m := new(MinuteBar)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// StockCollectorServer is the server API for StockCollector service.
+// All implementations must embed UnimplementedStockCollectorServer
+// for forward compatibility
+//
+// StockCollector exposes collection and queries for service-to-service use,
+// mirroring the sync, summary and data REST endpoints.
+type StockCollectorServer interface {
+	// Collect fetches minute data from Yahoo Finance for the last N days,
+	// incrementally when data already exists.
+	Collect(context.Context, *CollectRequest) (*CollectResponse, error)
+	// GetSummary returns the latest price and daily, weekly or monthly bars.
+	GetSummary(context.Context, *GetSummaryRequest) (*GetSummaryResponse, error)
+	// GetData streams stored minute bars between start and end, oldest first.
+	GetData(*GetDataRequest, StockCollector_GetDataServer) error
+	mustEmbedUnimplementedStockCollectorServer()
+}
+
+// UnimplementedStockCollectorServer must be embedded to have forward compatible implementations.
+type UnimplementedStockCollectorServer struct {
+}
+
+func (UnimplementedStockCollectorServer) Collect(context.Context, *CollectRequest) (*CollectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Collect not implemented")
+}
+func (UnimplementedStockCollectorServer) GetSummary(context.Context, *GetSummaryRequest) (*GetSummaryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSummary not implemented")
+}
+func (UnimplementedStockCollectorServer) GetData(*GetDataRequest, StockCollector_GetDataServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetData not implemented")
+}
+func (UnimplementedStockCollectorServer) mustEmbedUnimplementedStockCollectorServer() {}
+
+// UnsafeStockCollectorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StockCollectorServer will
+// result in compilation errors.
+type UnsafeStockCollectorServer interface {
+	mustEmbedUnimplementedStockCollectorServer()
+}
+
+func RegisterStockCollectorServer(s grpc.ServiceRegistrar, srv StockCollectorServer) {
+	s.RegisterService(&StockCollector_ServiceDesc, srv)
+}
+
+func _StockCollector_Collect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CollectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StockCollectorServer).Collect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StockCollector_Collect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StockCollectorServer).Collect(ctx, req.(*CollectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StockCollector_GetSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StockCollectorServer).GetSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StockCollector_GetSummary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StockCollectorServer).GetSummary(ctx, req.(*GetSummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StockCollector_GetData_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetDataRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StockCollectorServer).GetData(m, &stockCollectorGetDataServer{ServerStream: stream})
+}
+
+type StockCollector_GetDataServer interface {
+	Send(*MinuteBar) error
+	grpc.ServerStream
+}
+
+type stockCollectorGetDataServer struct {
+	grpc.ServerStream
+}
+
+func (x *stockCollectorGetDataServer) Send(m *MinuteBar) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// StockCollector_ServiceDesc is the grpc.ServiceDesc for StockCollector service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StockCollector_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "stockcollector.StockCollector",
+	HandlerType: (*StockCollectorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Collect",
+			Handler:    _StockCollector_Collect_Handler,
+		},
+		{
+			MethodName: "GetSummary",
+			Handler:    _StockCollector_GetSummary_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetData",
+			Handler:       _StockCollector_GetData_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "stockcollector.proto",
+}
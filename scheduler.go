@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"time"
 
@@ -12,10 +13,16 @@ type Scheduler struct {
 	collector *StockCollector
 	database  *Database
 	cron      *cron.Cron
+	config    Config
+
+	// ctx is cancelled on Stop so in-flight updates abort on shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-// NewScheduler creates a new scheduler instance with China timezone
-func NewScheduler(collector *StockCollector, database *Database) (*Scheduler, error) {
+// NewScheduler creates a new scheduler instance with China timezone.
+// Optional jobs (minute data pruning, event refresh) are enabled from config.
+func NewScheduler(collector *StockCollector, database *Database, config Config) (*Scheduler, error) {
 	// Load China timezone (UTC+8)
 	chinaTZ, err := time.LoadLocation("Asia/Shanghai")
 	if err != nil {
@@ -25,10 +32,15 @@ func NewScheduler(collector *StockCollector, database *Database) (*Scheduler, er
 	// Create cron with China timezone
 	c := cron.New(cron.WithLocation(chinaTZ))
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Scheduler{
 		collector: collector,
 		database:  database,
 		cron:      c,
+		config:    config,
+		ctx:       ctx,
+		cancel:    cancel,
 	}, nil
 }
 
@@ -47,13 +59,140 @@ func (s *Scheduler) Start() {
 		return
 	}
 
+	// Prune old minute data after the morning update, if retention is configured
+	if s.config.RetentionDays > 0 {
+		_, err := s.cron.AddFunc("30 8 * * *", func() {
+			log.Printf("[Scheduler] Pruning minute data older than %d days...", s.config.RetentionDays)
+			s.pruneMinuteData()
+		})
+		if err != nil {
+			log.Printf("[Scheduler] Failed to schedule prune task: %v", err)
+		}
+	}
+
+	// Refresh every IntradayInterval while the market is open. Runs that would
+	// overlap a still-running update are skipped.
+	if s.config.IntradayInterval > 0 {
+		job := cron.NewChain(cron.SkipIfStillRunning(cron.DefaultLogger)).Then(cron.FuncJob(func() {
+			if !IsMarketOpen(time.Now()) {
+				return
+			}
+			log.Println("[Scheduler] Starting intraday data update...")
+			s.updateAllWatchedStocks()
+		}))
+		if _, err := s.cron.AddJob("@every "+s.config.IntradayInterval.String(), job); err != nil {
+			log.Printf("[Scheduler] Failed to schedule intraday task: %v", err)
+		}
+	}
+
+	// Re-fetch the last closed trading day on its own schedule so late Yahoo
+	// corrections reach the summaries. Runs that would overlap are skipped.
+	if s.config.SettleSchedule != "" {
+		job := cron.NewChain(cron.SkipIfStillRunning(cron.DefaultLogger)).Then(cron.FuncJob(func() {
+			log.Println("[Scheduler] Re-fetching last trading day for settled data...")
+			s.settleLastTradingDay()
+		}))
+		if _, err := s.cron.AddJob(s.config.SettleSchedule, job); err != nil {
+			log.Printf("[Scheduler] Failed to schedule settle task: %v", err)
+		}
+	}
+
+	// Refresh dividends and earnings dates weekly (Sunday 9:00 AM China time)
+	if s.config.EnableEvents {
+		_, err := s.cron.AddFunc("0 9 * * 0", func() {
+			log.Println("[Scheduler] Refreshing corporate events...")
+			s.refreshCorporateEvents()
+		})
+		if err != nil {
+			log.Printf("[Scheduler] Failed to schedule events task: %v", err)
+		}
+	}
+
 	s.cron.Start()
 	log.Println("[Scheduler] Scheduler started - will update all watched stocks daily at 8:00 AM China time")
 }
 
-// updateAllWatchedStocks fetches latest data for all watched stocks
+// pruneMinuteData deletes minute bars older than the retention window for all symbols
+func (s *Scheduler) pruneMinuteData() {
+	cutoff := time.Now().AddDate(0, 0, -s.config.RetentionDays)
+	deleted, err := s.database.PruneMinuteData(s.ctx, "", cutoff)
+	if err != nil {
+		log.Printf("[Scheduler] Failed to prune minute data: %v", err)
+		return
+	}
+	log.Printf("[Scheduler] Pruned %d minute bars older than %s", deleted, cutoff.Format("2006-01-02"))
+}
+
+// refreshCorporateEvents fetches dividends and earnings dates for all active watched stocks
+func (s *Scheduler) refreshCorporateEvents() {
+	stocks, err := s.database.GetCollectedStocks(s.ctx)
+	if err != nil {
+		log.Printf("[Scheduler] Error getting watched stocks: %v", err)
+		return
+	}
+
+	for _, stock := range stocks {
+		events, err := s.collector.yahooClient.GetEvents(s.ctx, stock.Symbol)
+		if err != nil {
+			log.Printf("[Scheduler] Failed to fetch events for %s: %v", stock.Symbol, err)
+		} else if err := s.database.SaveCorporateEvents(s.ctx, events); err != nil {
+			log.Printf("[Scheduler] Failed to save events for %s: %v", stock.Symbol, err)
+		}
+
+		select {
+		case <-time.After(2 * time.Second):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+
+	log.Printf("[Scheduler] Corporate events refreshed for %d stocks", len(stocks))
+}
+
+// settleLastTradingDay re-fetches the whole New York day of the last closed
+// trading session for every active watched stock. The bars replace the stored
+// ones and that day's summaries are rebuilt from them, as with any collection.
+func (s *Scheduler) settleLastTradingDay() {
+	stocks, err := s.database.GetCollectedStocks(s.ctx)
+	if err != nil {
+		log.Printf("[Scheduler] Error getting watched stocks: %v", err)
+		return
+	}
+
+	day := lastClosedTradingDay(time.Now())
+	log.Printf("[Scheduler] Settling %s for %d watched stocks...", day.Format("2006-01-02"), len(stocks))
+
+	successCount := 0
+	failCount := 0
+
+	for _, stock := range stocks {
+		err := s.collector.CollectRange(s.ctx, stock.Symbol, day, day.AddDate(0, 0, 1))
+		if err != nil {
+			if s.ctx.Err() != nil {
+				log.Printf("[Scheduler] Settle cancelled: %v", s.ctx.Err())
+				return
+			}
+			log.Printf("[Scheduler] Failed to settle %s: %v", stock.Symbol, err)
+			failCount++
+			continue
+		}
+		successCount++
+
+		select {
+		case <-time.After(2 * time.Second):
+		case <-s.ctx.Done():
+			log.Printf("[Scheduler] Settle cancelled: %v", s.ctx.Err())
+			return
+		}
+	}
+
+	log.Printf("[Scheduler] Settle completed: %d succeeded, %d failed", successCount, failCount)
+}
+
+// updateAllWatchedStocks fetches latest data for all active watched stocks of
+// every tenant. Paused (inactive) stocks are excluded by GetCollectedStocks.
 func (s *Scheduler) updateAllWatchedStocks() {
-	stocks, err := s.database.GetWatchedStocks()
+	stocks, err := s.database.GetCollectedStocks(s.ctx)
 	if err != nil {
 		log.Printf("[Scheduler] Error getting watched stocks: %v", err)
 		return
@@ -73,22 +212,31 @@ func (s *Scheduler) updateAllWatchedStocks() {
 		log.Printf("[Scheduler] Updating %s (%s)...", stock.Symbol, stock.Name)
 
 		// Use intelligent incremental update (default 1 day, will adjust based on existing data)
-		err := s.collector.CollectHistoricalData(stock.Symbol, 1)
+		err := s.collector.CollectHistoricalData(s.ctx, stock.Symbol, 1)
 		if err != nil {
+			if s.ctx.Err() != nil {
+				log.Printf("[Scheduler] Update cancelled: %v", s.ctx.Err())
+				return
+			}
 			log.Printf("[Scheduler] Failed to update %s: %v", stock.Symbol, err)
 			failCount++
 			continue
 		}
 
 		// Update last sync time
-		if err := s.database.UpdateLastSync(stock.Symbol); err != nil {
+		if err := s.database.UpdateLastSync(s.ctx, stock.Symbol); err != nil {
 			log.Printf("[Scheduler] Warning: failed to update last sync time for %s: %v", stock.Symbol, err)
 		}
 
 		successCount++
 
 		// Small delay between requests to avoid rate limiting
-		time.Sleep(2 * time.Second)
+		select {
+		case <-time.After(2 * time.Second):
+		case <-s.ctx.Done():
+			log.Printf("[Scheduler] Update cancelled: %v", s.ctx.Err())
+			return
+		}
 	}
 
 	log.Printf("[Scheduler] Update completed: %d succeeded, %d failed", successCount, failCount)
@@ -97,6 +245,7 @@ func (s *Scheduler) updateAllWatchedStocks() {
 // Stop gracefully stops the scheduler
 func (s *Scheduler) Stop() {
 	log.Println("[Scheduler] Stopping scheduler...")
-	s.cron.Stop()
+	s.cancel()
+	<-s.cron.Stop().Done()
 	log.Println("[Scheduler] Scheduler stopped")
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// SeasonalReturn is the average daily return over the days in one calendar
+// bucket, such as Mondays or Januaries
+type SeasonalReturn struct {
+	Period        string  `json:"period"`
+	AverageReturn float64 `json:"averageReturn"`
+	Days          int     `json:"days"`
+}
+
+// ReturnsByWeekday averages daily close-to-close returns by the weekday of
+// the day they end on. bars may be in either order; a day is left out when
+// the previous trading day isn't in bars or its close isn't positive.
+func ReturnsByWeekday(bars []DailySummaryAPI) map[time.Weekday]float64 {
+	averages := make(map[time.Weekday]float64)
+	for key, returns := range groupDailyReturns(bars, func(date time.Time) int { return int(date.Weekday()) }) {
+		averages[time.Weekday(key)] = mean(returns)
+	}
+	return averages
+}
+
+// ReturnsByMonth averages daily close-to-close returns by calendar month, with
+// the same exclusions as ReturnsByWeekday
+func ReturnsByMonth(bars []DailySummaryAPI) map[time.Month]float64 {
+	averages := make(map[time.Month]float64)
+	for key, returns := range groupDailyReturns(bars, func(date time.Time) int { return int(date.Month()) }) {
+		averages[time.Month(key)] = mean(returns)
+	}
+	return averages
+}
+
+// groupDailyReturns computes each day's return over the previous day in bars
+// and groups the returns by bucket(date)
+func groupDailyReturns(bars []DailySummaryAPI, bucket func(time.Time) int) map[int][]float64 {
+	sorted := append([]DailySummaryAPI(nil), bars...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	groups := make(map[int][]float64)
+	for i := 1; i < len(sorted); i++ {
+		prev := sorted[i-1].Close
+		if prev <= 0 || sorted[i].Close <= 0 {
+			continue
+		}
+		key := bucket(sorted[i].Date)
+		groups[key] = append(groups[key], sorted[i].Close/prev-1)
+	}
+	return groups
+}
+
+// weekdaySeasonality lists the average return and day count of each weekday
+// with data, Monday first
+func weekdaySeasonality(bars []DailySummaryAPI) []SeasonalReturn {
+	groups := groupDailyReturns(bars, func(date time.Time) int { return int(date.Weekday()) })
+
+	result := []SeasonalReturn{}
+	for i := 1; i <= 7; i++ {
+		weekday := time.Weekday(i % 7)
+		if returns, ok := groups[int(weekday)]; ok {
+			result = append(result, SeasonalReturn{
+				Period:        weekday.String(),
+				AverageReturn: roundToDecimal(mean(returns), 6),
+				Days:          len(returns),
+			})
+		}
+	}
+	return result
+}
+
+// monthSeasonality lists the average return and day count of each month with
+// data, January first
+func monthSeasonality(bars []DailySummaryAPI) []SeasonalReturn {
+	groups := groupDailyReturns(bars, func(date time.Time) int { return int(date.Month()) })
+
+	result := []SeasonalReturn{}
+	for month := time.January; month <= time.December; month++ {
+		if returns, ok := groups[int(month)]; ok {
+			result = append(result, SeasonalReturn{
+				Period:        month.String(),
+				AverageReturn: roundToDecimal(mean(returns), 6),
+				Days:          len(returns),
+			})
+		}
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// closesOn returns daily bars closing at closes on the dates, given as
+// YYYY-MM-DD
+func closesOn(t *testing.T, dates []string, closes []float64) []DailySummaryAPI {
+	t.Helper()
+	bars := make([]DailySummaryAPI, len(dates))
+	for i, d := range dates {
+		date, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			t.Fatalf("parse %s: %v", d, err)
+		}
+		bars[i] = DailySummaryAPI{Symbol: "AAPL", Date: date, Open: closes[i], High: closes[i], Low: closes[i], Close: closes[i]}
+	}
+	return bars
+}
+
+func TestReturnsByWeekday(t *testing.T) {
+	// 2024-01-08 is a Monday
+	week := []string{"2024-01-08", "2024-01-09", "2024-01-10", "2024-01-11", "2024-01-12"}
+
+	tests := []struct {
+		name   string
+		dates  []string
+		closes []float64
+		want   map[time.Weekday]float64
+	}{
+		{
+			name:   "first day has no prior close",
+			dates:  week,
+			closes: []float64{100, 110, 99, 99, 198},
+			want:   map[time.Weekday]float64{time.Tuesday: 0.1, time.Wednesday: -0.1, time.Thursday: 0, time.Friday: 1},
+		},
+		{
+			name:   "monday over the weekend, averaged",
+			dates:  []string{"2024-01-05", "2024-01-08", "2024-01-12", "2024-01-15"},
+			closes: []float64{100, 110, 100, 80},
+			want:   map[time.Weekday]float64{time.Monday: (0.1 - 0.2) / 2, time.Friday: 100.0/110 - 1},
+		},
+		{
+			name:   "zero close skipped on both sides",
+			dates:  week,
+			closes: []float64{100, 0, 100, 110, 121},
+			want:   map[time.Weekday]float64{time.Thursday: 0.1, time.Friday: 0.1},
+		},
+		{name: "one day", dates: week[:1], closes: []float64{100}, want: map[time.Weekday]float64{}},
+		{name: "no days", want: map[time.Weekday]float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bars := closesOn(t, tt.dates, tt.closes)
+			for order, input := range map[string][]DailySummaryAPI{"oldest first": bars, "newest first": reversed(bars)} {
+				got := ReturnsByWeekday(input)
+				if len(got) != len(tt.want) {
+					t.Fatalf("%s: got %v, want %v", order, got, tt.want)
+				}
+				for weekday, want := range tt.want {
+					if r, ok := got[weekday]; !ok || !approxEqual(r, want) {
+						t.Errorf("%s: %s = %v, want %v", order, weekday, r, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReturnsByMonth(t *testing.T) {
+	bars := closesOn(t,
+		[]string{"2024-01-30", "2024-01-31", "2024-02-01", "2024-02-02", "2024-03-01"},
+		[]float64{100, 110, 121, 121, 60.5})
+
+	want := map[time.Month]float64{time.January: 0.1, time.February: 0.05, time.March: -0.5}
+	got := ReturnsByMonth(bars)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for month, w := range want {
+		if r, ok := got[month]; !ok || !approxEqual(r, w) {
+			t.Errorf("%s = %v, want %v", month, r, w)
+		}
+	}
+}
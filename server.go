@@ -1,35 +1,101 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"google.golang.org/grpc"
 )
 
 type WebServer struct {
-	collector *StockCollector
-	scheduler *Scheduler
-	router    *gin.Engine
+	config        Config
+	collector     *StockCollector
+	scheduler     *Scheduler
+	router        *gin.Engine
+	graphqlSchema graphql.Schema
+
+	// grpcServer is nil unless a gRPC port is configured
+	grpcServer *grpc.Server
+
+	// idempotency replays responses to POSTs repeated with an Idempotency-Key
+	idempotency *idempotencyStore
+
+	// staticFiles holds the web UI, embedded or from -static-dir
+	staticFiles http.FileSystem
+
+	// search is loaded from stocks.csv once; nil if that failed
+	search *StockSearchService
+
+	// staleRefreshes holds when refreshIfStale last started a background
+	// sync of each symbol, as a time.Time
+	staleRefreshes sync.Map
+
+	// aliases resolves symbol aliases; nil when alias resolution is off
+	aliases *SymbolAliases
+
+	// now is the clock refreshIfStale judges market hours and staleness by
+	now func() time.Time
 }
 
-func NewWebServer(dbPath string, enableScheduler bool) (*WebServer, error) {
-	collector, err := NewStockCollector(dbPath)
+func NewWebServer(cfg Config) (*WebServer, error) {
+	staticFiles, err := staticFileSystem(cfg.StaticDir)
+	if err != nil {
+		return nil, err
+	}
+
+	collector, err := NewStockCollector(cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery())
+	router.Use(requestIDMiddleware(), requestLogger(), gin.Recovery(), tenantMiddleware())
+
+	search, err := NewStockSearchService()
+	if err != nil {
+		log.Printf("Warning: stock search unavailable: %v", err)
+	}
+
+	if len(cfg.DefaultWatchlist) > 0 {
+		seedWatchlist(collector.database, search, cfg.DefaultWatchlist)
+	}
 
 	server := &WebServer{
-		collector: collector,
-		router:    router,
+		config:      cfg,
+		collector:   collector,
+		router:      router,
+		idempotency: newIdempotencyStore(),
+		staticFiles: staticFiles,
+		search:      search,
+		now:         time.Now,
+	}
+	if cfg.ResolveAliases || len(cfg.SymbolAliases) > 0 {
+		server.aliases = NewSymbolAliases(cfg.ResolveAliases, cfg.SymbolAliases)
+	}
+
+	schema, err := server.newGraphQLSchema()
+	if err != nil {
+		collector.Close()
+		return nil, fmt.Errorf("failed to build GraphQL schema: %v", err)
+	}
+	server.graphqlSchema = schema
+
+	if cfg.GRPCPort != "" {
+		server.grpcServer = newGRPCServer(server)
 	}
 
 	// Initialize scheduler if enabled
-	if enableScheduler {
-		scheduler, err := NewScheduler(collector, collector.database)
+	if cfg.EnableScheduler {
+		scheduler, err := NewScheduler(collector, collector.database, cfg)
 		if err != nil {
 			log.Printf("Warning: Failed to initialize scheduler: %v", err)
 		} else {
@@ -42,36 +108,177 @@ func NewWebServer(dbPath string, enableScheduler bool) (*WebServer, error) {
 	return server, nil
 }
 
+// seedWatchlist adds symbols to an empty watchlist, named from stocks.csv
+// where possible. Failures are logged rather than stopping startup.
+func seedWatchlist(database *Database, search *StockSearchService, symbols []string) {
+	stocks := make([]WatchedStock, 0, len(symbols))
+	for _, symbol := range symbols {
+		stock := WatchedStock{Symbol: symbol}
+		if search != nil {
+			if info, ok := search.Lookup(symbol); ok {
+				stock.Name = info.Name
+			}
+		}
+		stocks = append(stocks, stock)
+	}
+
+	seeded, err := database.SeedWatchlist(context.Background(), stocks)
+	if err != nil {
+		log.Printf("Warning: failed to seed default watchlist: %v", err)
+		return
+	}
+	if seeded {
+		log.Printf("Seeded empty watchlist with %s", strings.Join(symbols, ", "))
+	}
+}
+
 func (ws *WebServer) setupRoutes() {
-	// Serve static files
-	ws.router.Static("/static", "./static")
-	ws.router.StaticFile("/", "./static/index.html")
-	ws.router.StaticFile("/index.html", "./static/index.html")
-
-	// API routes
-	api := ws.router.Group("/api")
-	{
-		// Stock search
-		api.GET("/search", ws.searchStocks)
-
-		// Stock management
-		api.GET("/stocks", ws.getWatchedStocks)
-		api.POST("/stocks", ws.addWatchedStock)
-		api.DELETE("/stocks/:symbol", ws.removeWatchedStock)
-
-		// Stock data
-		api.GET("/stocks/:symbol/summary", ws.getStockSummary)
-		api.GET("/stocks/:symbol/data", ws.getStockData)
-		api.POST("/stocks/:symbol/sync", ws.syncStockData)
+	// Serve static files, embedded unless -static-dir is set
+	index := func(c *gin.Context) { serveIndex(c, ws.staticFiles) }
+	ws.router.StaticFS("/static", ws.staticFiles)
+	ws.router.GET("/", index)
+	ws.router.HEAD("/", index)
+	ws.router.GET("/index.html", index)
+	ws.router.HEAD("/index.html", index)
+
+	// Client-side routes fall back to index.html so deep links survive a
+	// refresh; unknown API paths and missing assets get a JSON 404
+	ws.router.NoRoute(func(c *gin.Context) {
+		if isSPARoute(c.Request) {
+			serveIndex(c, ws.staticFiles)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	})
+
+	// Readiness probe: database and Yahoo connectivity
+	ws.router.GET("/readyz", ws.readiness)
+
+	// GraphQL alongside the REST API
+	ws.router.GET("/graphql", ws.graphqlQuery)
+	ws.router.POST("/graphql", ws.graphqlQuery)
+
+	// Versioned API routes. v1 has the original response shapes and stays
+	// frozen; incompatible changes land in v2, which wraps JSON responses in
+	// the {data, error, requestId} envelope. Unversioned /api is an alias of
+	// v1 kept for existing clients.
+	ws.registerV1Routes(ws.router.Group("/api/v1"))
+	ws.registerV1Routes(ws.router.Group("/api"))
+	ws.registerV2Routes(ws.router.Group("/api/v2"))
+}
+
+// isSPARoute reports whether an unmatched request is a page the web UI routes
+// itself: a GET or HEAD outside /api and /static for a path without a file
+// extension
+func isSPARoute(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
 	}
+	p := r.URL.Path
+	if p == "/api" || strings.HasPrefix(p, "/api/") || strings.HasPrefix(p, "/static/") {
+		return false
+	}
+	return path.Ext(p) == ""
+}
+
+// registerV1Routes mounts the v1 API on group
+func (ws *WebServer) registerV1Routes(group *gin.RouterGroup) {
+	group.Use(gzipMiddleware(ws.config.GzipMinLength))
+	ws.registerAPIRoutes(group)
 }
 
+// registerV2Routes mounts the v2 API on group: the v1 handlers with their
+// JSON responses enveloped
+func (ws *WebServer) registerV2Routes(group *gin.RouterGroup) {
+	group.Use(gzipMiddleware(ws.config.GzipMinLength), envelopeMiddleware())
+	ws.registerAPIRoutes(group)
+}
+
+// registerAPIRoutes adds the REST API endpoints shared by every version to api
+func (ws *WebServer) registerAPIRoutes(api *gin.RouterGroup) {
+	// Long-running endpoints that fetch from Yahoo or scan minute data
+	timeout := timeoutMiddleware(ws.config.RequestTimeout)
+
+	// Repeated POSTs with the same Idempotency-Key replay the first response
+	idempotent := idempotencyMiddleware(ws.idempotency)
+
+	// Build information of the running binary
+	api.GET("/version", ws.getVersion)
+
+	// Stock search
+	api.GET("/search", ws.searchStocks)
+
+	// Stock management
+	api.GET("/stocks", ws.getWatchedStocks)
+	api.GET("/stocks/latest", ws.getLatestPrices)
+	api.POST("/stocks", idempotent, ws.addWatchedStock)
+	api.POST("/stocks/batch", idempotent, ws.addWatchedStocksBatch)
+	api.PUT("/stocks/order", ws.reorderWatchedStocks)
+	api.PATCH("/stocks/:symbol", ws.updateWatchedStock)
+	api.DELETE("/stocks/:symbol", ws.removeWatchedStock)
+
+	// Stock data
+	api.GET("/stocks/:symbol/summary", ws.getStockSummary)
+	api.GET("/summaries", ws.getSummaries)
+	api.GET("/stocks/:symbol/price", timeout, ws.getSpotPrice)
+	api.GET("/stocks/:symbol/data", timeout, ws.getStockData)
+	api.GET("/stocks/:symbol/range", timeout, ws.getStockRange)
+	api.GET("/stocks/:symbol/latest", ws.getLatestBar)
+	// Not wrapped in timeout: csv, tsv and jsonl are streamed, see exportStockData
+	api.GET("/stocks/:symbol/export", ws.exportStockData)
+	api.GET("/stocks/:symbol/ohlc", timeout, ws.getOHLC)
+	api.POST("/stocks/:symbol/sync", idempotent, timeout, ws.syncStockData)
+	api.GET("/stocks/:symbol/sync/stream", ws.streamSyncStockData)
+	api.POST("/stocks/:symbol/rebuild-summary", idempotent, timeout, ws.rebuildSummary)
+
+	// Analytics
+	api.POST("/backtest", ws.runBacktest)
+	api.GET("/stocks/:symbol/volatility", ws.getVolatility)
+	api.GET("/stocks/:symbol/risk", ws.getRiskMetrics)
+	api.GET("/stocks/:symbol/seasonality", ws.getSeasonality)
+	api.GET("/stocks/:symbol/beta", ws.getBeta)
+	api.GET("/stocks/:symbol/indicators", ws.getIndicator)
+	api.GET("/stocks/:symbol/rejected", ws.getRejectedBars)
+	api.GET("/stocks/:symbol/volume-anomalies", ws.getVolumeAnomalies)
+	api.GET("/compare", ws.compareStocks)
+	api.GET("/movers", ws.getMovers)
+
+	// Corporate events (dividends, earnings)
+	if ws.config.EnableEvents {
+		api.GET("/stocks/:symbol/events", ws.getCorporateEvents)
+	}
+
+	// Maintenance
+	api.POST("/maintenance/vacuum", ws.vacuumDatabase)
+	api.POST("/maintenance/prune", ws.pruneMinuteData)
+}
+
+// isValidWriteTimeout reports whether the server write timeout leaves a timed
+// out request room to answer: it must exceed the request timeout, unless either
+// is 0 and disabled
+func isValidWriteTimeout(writeTimeout, requestTimeout time.Duration) bool {
+	return writeTimeout <= 0 || requestTimeout <= 0 || writeTimeout > requestTimeout
+}
+
+// Run serves the router on addr with the configured connection timeouts
 func (ws *WebServer) Run(addr string) error {
 	log.Printf("Web server starting on %s", addr)
-	return ws.router.Run(addr)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           ws.router,
+		ReadTimeout:       ws.config.ReadTimeout,
+		ReadHeaderTimeout: ws.config.ReadTimeout,
+		WriteTimeout:      ws.config.WriteTimeout,
+		IdleTimeout:       ws.config.IdleTimeout,
+	}
+	return server.ListenAndServe()
 }
 
 func (ws *WebServer) Close() {
+	if ws.grpcServer != nil {
+		ws.grpcServer.GracefulStop()
+	}
 	if ws.scheduler != nil {
 		ws.scheduler.Stop()
 	}
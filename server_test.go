@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsValidWriteTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		writeTimeout   time.Duration
+		requestTimeout time.Duration
+		want           bool
+	}{
+		{name: "defaults", writeTimeout: 90 * time.Second, requestTimeout: 60 * time.Second, want: true},
+		{name: "request timeout disabled", writeTimeout: 90 * time.Second, requestTimeout: 0, want: true},
+		{name: "write timeout disabled", writeTimeout: 0, requestTimeout: 60 * time.Second, want: true},
+		{name: "both disabled", writeTimeout: 0, requestTimeout: 0, want: true},
+		{name: "equal", writeTimeout: 60 * time.Second, requestTimeout: 60 * time.Second, want: false},
+		{name: "shorter than the request timeout", writeTimeout: 30 * time.Second, requestTimeout: 60 * time.Second, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidWriteTimeout(tt.writeTimeout, tt.requestTimeout); got != tt.want {
+			t.Errorf("%s: isValidWriteTimeout(%v, %v) = %v, want %v", tt.name, tt.writeTimeout, tt.requestTimeout, got, tt.want)
+		}
+	}
+}
@@ -302,7 +302,7 @@ class StockTracker {
         const changeElement = card.querySelector('.price-change');
         const lastUpdateElement = card.querySelector('.last-update');
 
-        priceElement.textContent = this.formatPrice(data.currentPrice);
+        priceElement.textContent = this.formatPrice(data.currentPrice, data.currency);
 
         if (data.change !== undefined) {
             const isPositive = data.change >= 0;
@@ -332,7 +332,7 @@ class StockTracker {
                     </div>
                 </div>
                 <div class="stock-price-info">
-                    <div class="current-price">${this.formatPrice(data.currentPrice)}</div>
+                    <div class="current-price">${this.formatPrice(data.currentPrice, data.currency)}</div>
                     <div class="price-change ${changeClass}">${changeHTML}</div>
                     ${data.lastUpdate ? `<div class="last-update text-xs text-gray-500 mt-1">Last updated: ${this.formatDateTime(data.lastUpdate)}</div>` : ''}
                 </div>
@@ -492,10 +492,10 @@ class StockTracker {
     }
 
     // Utility functions
-    formatPrice(price) {
+    formatPrice(price, currency = 'USD') {
         return new Intl.NumberFormat('en-US', {
             style: 'currency',
-            currency: 'USD',
+            currency: currency || 'USD',
             minimumFractionDigits: 2,
             maximumFractionDigits: 2,
         }).format(price);
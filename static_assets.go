@@ -0,0 +1,56 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// embeddedStatic is the web UI bundled into the binary at build time, so a
+// single binary can be deployed without the static/ folder
+//
+//go:embed static
+var embeddedStatic embed.FS
+
+// staticFileSystem returns the web UI assets: the on-disk dir when one is
+// given, which picks up edits without rebuilding, or the embedded copy
+func staticFileSystem(dir string) (http.FileSystem, error) {
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("static directory %s: %v", dir, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("static directory %s is not a directory", dir)
+		}
+		return http.Dir(dir), nil
+	}
+
+	assets, err := fs.Sub(embeddedStatic, "static")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded static files: %v", err)
+	}
+	return http.FS(assets), nil
+}
+
+// serveIndex writes index.html from files. http.ServeContent is used directly
+// because http.FileServer redirects requests for index.html to the directory.
+func serveIndex(c *gin.Context, files http.FileSystem) {
+	file, err := files.Open("/index.html")
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	http.ServeContent(c.Writer, c.Request, "index.html", info.ModTime(), file)
+}
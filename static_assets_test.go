@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeIndex(t *testing.T) {
+	embeddedIndex, err := embeddedStatic.ReadFile("static/index.html")
+	if err != nil {
+		t.Fatalf("read embedded index: %v", err)
+	}
+
+	diskDir := t.TempDir()
+	diskIndex := "<html>from disk</html>"
+	if err := os.WriteFile(filepath.Join(diskDir, "index.html"), []byte(diskIndex), 0o644); err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+
+	// Run from an empty directory so no static/ folder is on hand
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	tests := []struct {
+		name      string
+		staticDir string
+		path      string
+		want      string
+	}{
+		{name: "embedded root", path: "/", want: string(embeddedIndex)},
+		{name: "embedded index.html", path: "/index.html", want: string(embeddedIndex)},
+		{name: "embedded client route", path: "/stocks/AAPL", want: string(embeddedIndex)},
+		{name: "disk dir", staticDir: diskDir, path: "/", want: diskIndex},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newTestWebServer(t, Config{StaticDir: tt.staticDir})
+			w := serve(ws, http.MethodGet, tt.path, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			if w.Body.String() != tt.want {
+				t.Errorf("body = %.80q, want %.80q", w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticFileSystemMissingDir(t *testing.T) {
+	if _, err := staticFileSystem(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing static dir: want an error")
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"sync"
 	"time"
 )
 
@@ -14,89 +18,263 @@ type MinuteBar struct {
 	Low       float64   `json:"low"`
 	Close     float64   `json:"close"`
 	Volume    int64     `json:"volume"`
+	Currency  string    `json:"currency"`
+	Session   string    `json:"session,omitempty"` // regular, pre or post
 }
 
+const (
+	// defaultInitialDays is the first-collection window when none is configured
+	defaultInitialDays = 30
+
+	// maxInitialDays is how far back Yahoo serves 1-minute bars
+	maxInitialDays = 30
+)
+
+// ErrSyncInProgress is returned by TryCollectHistoricalData when another
+// collection of the same symbol holds its lock
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+// symbolLockPoll is how often TryCollectHistoricalData retries a held lock
+const symbolLockPoll = 50 * time.Millisecond
+
 type StockCollector struct {
 	yahooClient *YahooFinanceClient
 	database    *Database
+	cache       Cache
+	initialDays int
+
+	// atomicCollect stores bars and summaries in one transaction
+	atomicCollect bool
+
+	// symbolLocks holds a *sync.Mutex per symbol, so collections of one
+	// symbol run one at a time while different symbols proceed in parallel
+	symbolLocks sync.Map
 }
 
-func NewStockCollector(dbPath string) (*StockCollector, error) {
+func NewStockCollector(cfg Config) (*StockCollector, error) {
 	yahooClient := NewYahooFinanceClient()
-	database, err := NewDatabase(dbPath)
+	yahooClient.SetUserAgents(cfg.UserAgents)
+	yahooClient.SetHosts(cfg.YahooHosts)
+	yahooClient.SetTimeout(cfg.YahooTimeout, cfg.YahooRetries)
+	yahooClient.SetExtendedHours(cfg.ExtendedHours)
+	yahooClient.SetBatching(cfg.BatchDays, cfg.BatchDelay)
+	yahooClient.SetBatchConcurrency(cfg.BatchConcurrency)
+	yahooClient.SetFilterMode(cfg.FilterMode)
+	yahooClient.SetSpikeFilter(cfg.SpikeFactor, cfg.SpikeWindow)
+	if err := yahooClient.SetProxy(cfg.ProxyURL); err != nil {
+		return nil, err
+	}
+
+	database, err := NewDatabase(cfg.DBPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %v", err)
 	}
+	database.SetSummaryCacheSize(cfg.SummaryCacheSize)
+	database.SetRegularSessionSummaries(regularSessionSummaries(cfg.SummarySession, cfg.ExtendedHours))
+	database.SetConflictStrategy(cfg.ConflictStrategy)
 
-	return &StockCollector{
-		yahooClient: yahooClient,
-		database:    database,
-	}, nil
+	cache, err := newCache(cfg)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to initialize cache: %v", err)
+	}
+
+	initialDays := cfg.InitialDays
+	if initialDays <= 0 {
+		initialDays = defaultInitialDays
+	}
+
+	sc := &StockCollector{
+		yahooClient:   yahooClient,
+		database:      database,
+		cache:         cache,
+		initialDays:   initialDays,
+		atomicCollect: cfg.AtomicCollect,
+	}
+
+	if cfg.QuarantineRejected {
+		yahooClient.SetRejectHandler(func(bars []RejectedBar) {
+			if err := database.SaveRejectedBars(context.Background(), bars); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		})
+	}
+
+	// Drop cached API responses built from the old data whenever new data lands
+	sc.OnDataChanged(func(symbol string) {
+		if err := invalidateSymbol(context.Background(), cache, symbol); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	})
+	return sc, nil
 }
 
-func (sc *StockCollector) CollectHistoricalData(symbol string, days int) error {
-	log.Printf("Starting data collection for %s (last %d days)...", symbol, days)
+// OnDataChanged registers fn to be called with a symbol whenever its stored
+// minute data or summaries change, whichever path wrote them
+func (sc *StockCollector) OnDataChanged(fn DataChangedFunc) {
+	sc.database.OnDataChanged(fn)
+}
+
+// initialWindow returns days, or the configured first-collection window when
+// days is 0 or less
+func (sc *StockCollector) initialWindow(days int) int {
+	if days <= 0 {
+		return sc.initialDays
+	}
+	return days
+}
+
+// symbolLock returns the mutex serializing collections of symbol
+func (sc *StockCollector) symbolLock(symbol string) *sync.Mutex {
+	lock, _ := sc.symbolLocks.LoadOrStore(symbol, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// tryLockSymbol waits up to wait for symbol's lock, returning false if it is
+// still held then or ctx ends first
+func (sc *StockCollector) tryLockSymbol(ctx context.Context, symbol string, wait time.Duration) bool {
+	lock := sc.symbolLock(symbol)
+	deadline := time.Now().Add(wait)
+	for {
+		if lock.TryLock() {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(symbolLockPoll):
+		}
+	}
+}
+
+// CollectDailyHistory fetches the full daily history Yahoo has for symbol and
+// stores it as daily, weekly and monthly summaries. Minute data is untouched,
+// so this extends charts back years beyond the ~30 days of minute bars.
+func (sc *StockCollector) CollectDailyHistory(ctx context.Context, symbol string) error {
+	log.Printf("Starting full daily history collection for %s...", symbol)
 
-	// Check if we already have data for this symbol
-	latestTimestamp, err := sc.database.GetLatestTimestamp(symbol)
+	bars, err := sc.yahooClient.GetDailyHistory(ctx, symbol)
 	if err != nil {
-		return fmt.Errorf("failed to check existing data: %v", err)
+		return fmt.Errorf("failed to fetch daily history from Yahoo Finance: %w", err)
 	}
 
-	if !latestTimestamp.IsZero() {
-		log.Printf("Found existing data for %s, latest timestamp: %s", symbol, latestTimestamp.Format("2006-01-02 15:04:05"))
-
-		// Calculate how many days we need to fetch
-		// Add 1 to ensure we re-fetch the last day completely (in case it was incomplete)
-		daysSinceLatest := int(time.Since(latestTimestamp).Hours()/24) + 1
-
-		// If the last data is very recent (less than 1 day old), check if it's a trading day
-		now := time.Now()
-		if daysSinceLatest == 1 {
-			// Check if we're on the same calendar day (in any timezone)
-			if latestTimestamp.Year() == now.Year() &&
-			   latestTimestamp.YearDay() == now.YearDay() {
-				// Same day - always re-fetch to ensure completeness
-				log.Printf("Latest data is from today, re-fetching to ensure completeness")
-				days = 1
-			} else {
-				// Different day - fetch since the day of latest data
-				days = daysSinceLatest
-			}
-		} else if daysSinceLatest <= 0 {
-			// This shouldn't happen with the +1 above, but keep as safety check
-			log.Printf("Re-fetching last day to ensure data completeness")
-			days = 1
-		} else {
-			days = daysSinceLatest
+	if len(bars) == 0 {
+		log.Printf("No daily history returned for %s", symbol)
+		return nil
+	}
+
+	if err := sc.database.StoreDailyBars(ctx, symbol, bars); err != nil {
+		return fmt.Errorf("failed to store daily history: %v", err)
+	}
+
+	log.Printf("Stored %d daily bars for %s (%s to %s)", len(bars), symbol,
+		bars[0].Timestamp.Format("2006-01-02"), bars[len(bars)-1].Timestamp.Format("2006-01-02"))
+	return nil
+}
+
+// CollectHistoricalData fetches and stores minute data for symbol, incrementally
+// when data already exists. days is the window for a first collection; 0 uses
+// the configured initial window. Optional progress callbacks observe each Yahoo batch.
+// A collection of the same symbol already running is waited for.
+func (sc *StockCollector) CollectHistoricalData(ctx context.Context, symbol string, days int, onProgress ...ProgressFunc) error {
+	lock := sc.symbolLock(symbol)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return sc.collectHistoricalData(ctx, symbol, days, onProgress...)
+}
+
+// TryCollectHistoricalData is CollectHistoricalData for manual syncs: rather
+// than queueing behind a running collection of symbol for longer than wait, it
+// returns ErrSyncInProgress
+func (sc *StockCollector) TryCollectHistoricalData(ctx context.Context, symbol string, days int, wait time.Duration, onProgress ...ProgressFunc) error {
+	if !sc.tryLockSymbol(ctx, symbol, wait) {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		return fmt.Errorf("%w for %s", ErrSyncInProgress, symbol)
+	}
+	defer sc.symbolLock(symbol).Unlock()
+
+	return sc.collectHistoricalData(ctx, symbol, days, onProgress...)
+}
 
-		log.Printf("Fetching %d days of data for %s (includes re-fetching last day)", days, symbol)
+func (sc *StockCollector) collectHistoricalData(ctx context.Context, symbol string, days int, onProgress ...ProgressFunc) error {
+	days = sc.initialWindow(days)
+	log.Printf("Starting data collection for %s (last %d days)...", symbol, days)
+
+	// Switch to an incremental fetch if we already have data for this symbol
+	fetchDays, err := sc.database.DaysToFetch(ctx, symbol, days, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to check existing data: %v", err)
+	}
+	if fetchDays != days {
+		days = fetchDays
+		log.Printf("Found existing data for %s, fetching %d days (includes re-fetching last day)", symbol, days)
 	}
 
 	// Fetch data from Yahoo Finance
-	bars, err := sc.yahooClient.GetMinuteData(symbol, days)
+	bars, err := sc.yahooClient.GetMinuteData(ctx, symbol, days, onProgress...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch data from Yahoo Finance: %w", err)
+	}
+
+	return sc.storeCollected(ctx, symbol, bars)
+}
+
+// CollectRange fetches and stores minute data for symbol between start and
+// end, regardless of what is already stored
+func (sc *StockCollector) CollectRange(ctx context.Context, symbol string, start, end time.Time, onProgress ...ProgressFunc) error {
+	lock := sc.symbolLock(symbol)
+	lock.Lock()
+	defer lock.Unlock()
+
+	log.Printf("Starting data collection for %s (%s to %s)...", symbol,
+		start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
+
+	bars, err := sc.yahooClient.GetDataRange(ctx, symbol, start, end, "1m", onProgress...)
 	if err != nil {
-		return fmt.Errorf("failed to fetch data from Yahoo Finance: %v", err)
+		return fmt.Errorf("failed to fetch data from Yahoo Finance: %w", err)
 	}
 
+	return sc.storeCollected(ctx, symbol, bars)
+}
+
+// storeCollected inserts freshly fetched bars and updates the summaries; the
+// database's change notification drops cached responses for symbol. With
+// atomicCollect a summary failure fails the whole store; otherwise the bars
+// are kept and the failure only logged.
+func (sc *StockCollector) storeCollected(ctx context.Context, symbol string, bars []MinuteBar) error {
 	if len(bars) == 0 {
 		log.Printf("No data returned for %s", symbol)
 		return nil
 	}
 
-	// Insert data into database
-	if err := sc.database.InsertMinuteData(bars); err != nil {
-		return fmt.Errorf("failed to insert data into database: %v", err)
-	}
+	if sc.atomicCollect {
+		if err := sc.database.InsertWithSummary(ctx, symbol, bars); err != nil {
+			return fmt.Errorf("failed to store data, rolled back: %v", err)
+		}
+	} else {
+		// Insert data into database
+		if err := sc.database.InsertMinuteData(ctx, bars); err != nil {
+			return fmt.Errorf("failed to insert data into database: %v", err)
+		}
 
-	// Update daily summary
-	if err := sc.database.UpdateDailySummary(symbol, bars); err != nil {
-		log.Printf("Warning: failed to update daily summary for %s: %v", symbol, err)
+		// Update daily summary
+		summaryBars, err := sc.database.summaryBars(ctx, symbol, bars)
+		if err == nil {
+			err = sc.database.UpdateDailySummary(ctx, symbol, summaryBars)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to update daily summary for %s: %v", symbol, err)
+		}
 	}
 
 	// Log statistics
-	count, earliest, latest, err := sc.database.GetDataStats(symbol)
+	count, earliest, latest, err := sc.database.GetDataStats(ctx, symbol)
 	if err != nil {
 		log.Printf("Warning: failed to get data stats: %v", err)
 	} else {
@@ -110,11 +288,13 @@ func (sc *StockCollector) CollectHistoricalData(symbol string, days int) error {
 	return nil
 }
 
-func (sc *StockCollector) GetDataForAnalysis(symbol string, days int) ([]MinuteBar, error) {
-	endTime := time.Now()
-	startTime := endTime.AddDate(0, 0, -days)
+func (sc *StockCollector) GetDataForAnalysis(ctx context.Context, symbol string, days int) ([]MinuteBar, error) {
+	return sc.GetDataSince(ctx, symbol, time.Now().AddDate(0, 0, -days))
+}
 
-	bars, err := sc.database.GetMinuteData(symbol, startTime, endTime)
+// GetDataSince returns stored minute bars from startTime until now
+func (sc *StockCollector) GetDataSince(ctx context.Context, symbol string, startTime time.Time) ([]MinuteBar, error) {
+	bars, err := sc.database.GetMinuteData(ctx, symbol, startTime, time.Now())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get data for analysis: %v", err)
 	}
@@ -123,8 +303,10 @@ func (sc *StockCollector) GetDataForAnalysis(symbol string, days int) ([]MinuteB
 	return bars, nil
 }
 
-func (sc *StockCollector) DisplaySampleData(symbol string, limit int) error {
-	bars, err := sc.GetDataForAnalysis(symbol, 1) // Get last day's data
+// DisplaySampleData writes the first limit of the last day's bars for symbol,
+// and the last few when there are more, to w in the given output format
+func (sc *StockCollector) DisplaySampleData(ctx context.Context, w io.Writer, format, symbol string, limit int) error {
+	bars, err := sc.GetDataForAnalysis(ctx, symbol, 1) // Get last day's data
 	if err != nil {
 		return fmt.Errorf("failed to get sample data: %v", err)
 	}
@@ -134,36 +316,13 @@ func (sc *StockCollector) DisplaySampleData(symbol string, limit int) error {
 		return nil
 	}
 
-	log.Printf("\n=== Sample Data for %s ===", symbol)
-	log.Printf("Total records: %d", len(bars))
-	log.Printf("\nFirst 5 records:")
-	count := limit
-	if count > len(bars) {
-		count = len(bars)
-	}
-
-	for i := 0; i < count; i++ {
-		bar := bars[i]
-		log.Printf("%s | O:%.2f H:%.2f L:%.2f C:%.2f V:%d",
-			bar.Timestamp.Format("2006-01-02 15:04:05"),
-			bar.Open, bar.High, bar.Low, bar.Close, bar.Volume)
-	}
-
-	if len(bars) > limit {
-		log.Printf("...")
-		log.Printf("Last 5 records:")
-		for i := len(bars) - 5; i < len(bars); i++ {
-			bar := bars[i]
-			log.Printf("%s | O:%.2f H:%.2f L:%.2f C:%.2f V:%d",
-				bar.Timestamp.Format("2006-01-02 15:04:05"),
-				bar.Open, bar.High, bar.Low, bar.Close, bar.Volume)
-		}
-	}
-
-	return nil
+	return writeSampleData(w, format, symbol, bars, limit)
 }
 
 func (sc *StockCollector) Close() {
+	if sc.cache != nil {
+		sc.cache.Close()
+	}
 	if sc.database != nil {
 		sc.database.Close()
 	}
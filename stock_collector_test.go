@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestCollector builds a collector on a temporary database with Yahoo
+// served by a stub server passing chart requests to handler
+func newTestCollector(t *testing.T, cfg Config, handler http.HandlerFunc) *StockCollector {
+	t.Helper()
+	stub := newStubYahooServer(t, handler)
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	cfg.YahooHosts = []string{stub.URL}
+	sc, err := NewStockCollector(cfg)
+	if err != nil {
+		t.Fatalf("NewStockCollector: %v", err)
+	}
+	sc.yahooClient.cookieURL = stub.URL + "/cookie"
+	t.Cleanup(sc.Close)
+	return sc
+}
+
+func TestInitialWindow(t *testing.T) {
+	tests := []struct {
+		name        string
+		initialDays int
+		days        int
+		want        int
+	}{
+		{name: "configured window when unset", initialDays: 10, days: 0, want: 10},
+		{name: "configured window for negative days", initialDays: 10, days: -1, want: 10},
+		{name: "explicit days win", initialDays: 10, days: 5, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := &StockCollector{initialDays: tt.initialDays}
+			if got := sc.initialWindow(tt.days); got != tt.want {
+				t.Errorf("initialWindow(%d) = %d, want %d", tt.days, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSymbolLock(t *testing.T) {
+	// AAPL chart requests block until released; others answer at once
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	var inFlight, maxInFlight atomic.Int32
+	sc := newTestCollector(t, Config{InitialDays: 1}, func(w http.ResponseWriter, r *http.Request) {
+		symbol := "MSFT"
+		if strings.Contains(r.URL.Path, "AAPL") {
+			symbol = "AAPL"
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				peak := maxInFlight.Load()
+				if n <= peak || maxInFlight.CompareAndSwap(peak, n) {
+					break
+				}
+			}
+			started <- struct{}{}
+			<-release
+		}
+		w.Write(chartJSON(t, symbol, stubBar{at: time.Now().Add(-time.Hour).Truncate(time.Minute), price: 100, volume: 10}))
+	})
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	t.Cleanup(unblock)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	collectErrs := make(chan error, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			collectErrs <- sc.CollectHistoricalData(ctx, "AAPL", 0)
+		}()
+	}
+	<-started
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		symbol  string
+		wait    time.Duration
+		wantErr error
+	}{
+		{name: "same symbol busy", ctx: ctx, symbol: "AAPL", wait: 0, wantErr: ErrSyncInProgress},
+		{name: "same symbol busy past the wait", ctx: ctx, symbol: "AAPL", wait: 2 * symbolLockPoll, wantErr: ErrSyncInProgress},
+		{name: "cancelled while waiting", ctx: cancelled, symbol: "AAPL", wait: time.Second, wantErr: context.Canceled},
+		{name: "other symbol runs in parallel", ctx: ctx, symbol: "MSFT", wait: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sc.TryCollectHistoricalData(tt.ctx, tt.symbol, 0, tt.wait)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("TryCollectHistoricalData = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A manual sync willing to wait gets the lock once the others finish
+	waited := make(chan error, 1)
+	go func() { waited <- sc.TryCollectHistoricalData(ctx, "AAPL", 0, 10*time.Second) }()
+	unblock()
+	wg.Wait()
+	close(collectErrs)
+	for err := range collectErrs {
+		if err != nil {
+			t.Errorf("CollectHistoricalData: %v", err)
+		}
+	}
+	if err := <-waited; err != nil {
+		t.Errorf("waiting TryCollectHistoricalData: %v", err)
+	}
+	if got := maxInFlight.Load(); got != 1 {
+		t.Errorf("%d AAPL collections fetched at once, want 1", got)
+	}
+}
+
+func TestStoreCollectedSummaryFailure(t *testing.T) {
+	// 2024-03-05 10:00-10:02 New York
+	start := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	var bars []MinuteBar
+	for i := range 3 {
+		bars = append(bars, testBar("AAPL", start.Add(time.Duration(i)*time.Minute), 100, 10))
+	}
+
+	tests := []struct {
+		name     string
+		atomic   bool
+		wantErr  bool
+		wantBars int64
+	}{
+		{name: "atomic rolls the bars back", atomic: true, wantErr: true, wantBars: 0},
+		{name: "lenient keeps the bars", atomic: false, wantErr: false, wantBars: 3},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDatabase(t)
+			// Make every daily summary write fail
+			if err := database.db.Exec(`CREATE TRIGGER fail_summary BEFORE INSERT ON stock_daily_summary
+				BEGIN SELECT RAISE(ABORT, 'forced summary failure'); END`).Error; err != nil {
+				t.Fatalf("create trigger: %v", err)
+			}
+			sc := &StockCollector{database: database, atomicCollect: tt.atomic}
+
+			err := sc.storeCollected(ctx, "AAPL", bars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("storeCollected error = %v, want error %v", err, tt.wantErr)
+			}
+
+			var stored int64
+			if err := database.db.Model(&StockMinuteData{}).Where("symbol = ?", "AAPL").Count(&stored).Error; err != nil {
+				t.Fatalf("count bars: %v", err)
+			}
+			if stored != tt.wantBars {
+				t.Errorf("stored %d bars, want %d", stored, tt.wantBars)
+			}
+		})
+	}
+}
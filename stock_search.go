@@ -1,16 +1,39 @@
 package main
 
 import (
+	"container/list"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"unicode"
 )
 
+// searchCacheSize is how many queries' match lists Search remembers
+const searchCacheSize = 64
+
+// searchCheckEvery is how many stocks Search scans between checks for a
+// cancelled request
+const searchCheckEvery = 32
+
 type StockSearchService struct {
 	stocks []StockInfo
+
+	// matchCache maps recent queries to the indexes of every stock they
+	// match, least recently used evicted first. A query extending a cached
+	// one only needs to scan that one's matches.
+	mu         sync.Mutex
+	matchOrder *list.List // front is most recently used
+	matchCache map[string]*list.Element
+}
+
+type searchCacheEntry struct {
+	query   string
+	matches []int
 }
 
 type StockInfo struct {
@@ -21,7 +44,10 @@ type StockInfo struct {
 }
 
 func NewStockSearchService() (*StockSearchService, error) {
-	service := &StockSearchService{}
+	service := &StockSearchService{
+		matchOrder: list.New(),
+		matchCache: make(map[string]*list.Element),
+	}
 	err := service.loadStockData()
 	if err != nil {
 		return nil, err
@@ -119,31 +145,222 @@ func (s *StockSearchService) generatePinyinSearchText(chineseName, name, symbol
 	return strings.Join(pinyin, " ")
 }
 
-func (s *StockSearchService) Search(query string, limit int) []StockSearchResult {
+// Search returns up to limit stocks matching query. The scan stops with
+// ctx's error once ctx is done, so a request superseded by the next keystroke
+// doesn't keep working. Matches are cached per query, and since every match
+// of a query also matches its prefixes, a longer query only rescans the
+// matches of the longest cached prefix.
+func (s *StockSearchService) Search(ctx context.Context, query string, limit int) ([]StockSearchResult, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
 	if query == "" {
-		return []StockSearchResult{}
+		return []StockSearchResult{}, nil
 	}
 
-	query = strings.ToLower(strings.TrimSpace(query))
-	var results []StockSearchResult
+	matches, cached := s.cachedMatches(query)
+	if !cached {
+		candidates := s.candidates(query)
+		matches = make([]int, 0, len(candidates))
+		for n, i := range candidates {
+			if n%searchCheckEvery == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+			}
+			if s.matchesQuery(s.stocks[i], query) {
+				matches = append(matches, i)
+			}
+		}
+		s.cacheMatches(query, matches)
+	}
+
+	results := []StockSearchResult{}
+	for _, i := range matches {
+		if len(results) >= limit {
+			break
+		}
+		stock := s.stocks[i]
+		results = append(results, StockSearchResult{
+			Symbol:      stock.Symbol,
+			Name:        stock.Name,
+			ChineseName: stock.ChineseName,
+			FullName:    fmt.Sprintf("%s (%s)", stock.Name, stock.ChineseName),
+		})
+	}
+
+	return results, nil
+}
+
+// cachedMatches returns the cached match indexes for query
+func (s *StockSearchService) cachedMatches(query string) ([]int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.matchCache[query]
+	if !ok {
+		return nil, false
+	}
+	s.matchOrder.MoveToFront(elem)
+	return elem.Value.(*searchCacheEntry).matches, true
+}
+
+// candidates returns the indexes of the stocks that can match query: the
+// matches of its longest cached prefix, or every stock
+func (s *StockSearchService) candidates(query string) []int {
+	s.mu.Lock()
+	for n := len(query) - 1; n > 0; n-- {
+		if elem, ok := s.matchCache[query[:n]]; ok {
+			s.mu.Unlock()
+			return elem.Value.(*searchCacheEntry).matches
+		}
+	}
+	s.mu.Unlock()
+
+	all := make([]int, len(s.stocks))
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+// cacheMatches remembers query's match indexes, evicting the least recently
+// used query once searchCacheSize is reached
+func (s *StockSearchService) cacheMatches(query string, matches []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.matchCache[query]; ok {
+		s.matchOrder.MoveToFront(elem)
+		return
+	}
+
+	s.matchCache[query] = s.matchOrder.PushFront(&searchCacheEntry{query: query, matches: matches})
+	if s.matchOrder.Len() > searchCacheSize {
+		oldest := s.matchOrder.Back()
+		s.matchOrder.Remove(oldest)
+		delete(s.matchCache, oldest.Value.(*searchCacheEntry).query)
+	}
+}
+
+// maxSuggestDistance is the largest edit distance Suggest accepts between a
+// symbol and a bundled ticker
+const maxSuggestDistance = 2
+
+// Suggest returns up to limit bundled tickers close to symbol, for when it
+// turns out not to exist: those within a couple of edits of it, nearest first,
+// and ties broken alphabetically. Symbols too short to misspell get none.
+func (s *StockSearchService) Suggest(symbol string, limit int) []StockSearchResult {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if len(symbol) < 2 || limit <= 0 {
+		return []StockSearchResult{}
+	}
 
+	type candidate struct {
+		stock    StockInfo
+		distance int
+	}
+	var candidates []candidate
 	for _, stock := range s.stocks {
-		if s.matchesQuery(stock, query) {
-			result := StockSearchResult{
-				Symbol:      stock.Symbol,
-				Name:        stock.Name,
-				ChineseName: stock.ChineseName,
-				FullName:    fmt.Sprintf("%s (%s)", stock.Name, stock.ChineseName),
+		other := strings.ToUpper(stock.Symbol)
+		if other == symbol {
+			continue
+		}
+		distance := editDistance(symbol, other)
+		if distance <= maxSuggestDistance && distance < len(symbol) {
+			candidates = append(candidates, candidate{stock, distance})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].stock.Symbol < candidates[j].stock.Symbol
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	results := make([]StockSearchResult, 0, len(candidates))
+	for _, c := range candidates {
+		results = append(results, StockSearchResult{
+			Symbol:      c.stock.Symbol,
+			Name:        c.stock.Name,
+			ChineseName: c.stock.ChineseName,
+			FullName:    fmt.Sprintf("%s (%s)", c.stock.Name, c.stock.ChineseName),
+		})
+	}
+	return results
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
 			}
-			results = append(results, result)
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// Lookup returns the bundled entry for symbol, if there is one
+func (s *StockSearchService) Lookup(symbol string) (StockInfo, bool) {
+	for _, stock := range s.stocks {
+		if strings.EqualFold(stock.Symbol, symbol) {
+			return stock, true
+		}
+	}
+	return StockInfo{}, false
+}
 
-			if len(results) >= limit {
-				break
+// mergeWatchedResults puts watchlist matches ahead of the CSV results, using
+// the watchlist's fetched name and the CSV's Chinese name when it has one, and
+// drops CSV results for symbols already listed
+func (s *StockSearchService) mergeWatchedResults(watched []WatchedStock, results []StockSearchResult, limit int) []StockSearchResult {
+	merged := make([]StockSearchResult, 0, limit)
+	seen := make(map[string]bool)
+
+	for _, stock := range watched {
+		result := StockSearchResult{
+			Symbol:   stock.Symbol,
+			Name:     stock.Name,
+			FullName: stock.Name,
+		}
+		if info, ok := s.Lookup(stock.Symbol); ok {
+			if result.Name == "" {
+				result.Name = info.Name
 			}
+			result.ChineseName = info.ChineseName
+			result.FullName = fmt.Sprintf("%s (%s)", result.Name, info.ChineseName)
 		}
+		merged = append(merged, result)
+		seen[strings.ToUpper(stock.Symbol)] = true
 	}
 
-	return results
+	for _, result := range results {
+		if len(merged) >= limit {
+			break
+		}
+		if !seen[strings.ToUpper(result.Symbol)] {
+			merged = append(merged, result)
+		}
+	}
+
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
 }
 
 func (s *StockSearchService) matchesQuery(stock StockInfo, query string) bool {
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSearchService returns a search service over stocks instead of
+// stocks.csv
+func newTestSearchService(stocks ...StockInfo) *StockSearchService {
+	return &StockSearchService{
+		stocks:     stocks,
+		matchOrder: list.New(),
+		matchCache: make(map[string]*list.Element),
+	}
+}
+
+// numberedStocks returns n stocks, T0000 "Test Corp 0000" onwards, every one
+// matching the query "test"
+func numberedStocks(n int) []StockInfo {
+	stocks := make([]StockInfo, n)
+	for i := range stocks {
+		symbol := fmt.Sprintf("T%04d", i)
+		name := fmt.Sprintf("Test Corp %04d", i)
+		stocks[i] = StockInfo{Symbol: symbol, Name: name, SearchText: strings.ToLower(symbol + " " + name)}
+	}
+	return stocks
+}
+
+// cancelAfterContext reports itself cancelled from its n-th Err call on,
+// counting the calls, so a test can tell how far a scan got
+type cancelAfterContext struct {
+	context.Context
+	n     int
+	calls int
+}
+
+func (c *cancelAfterContext) Err() error {
+	c.calls++
+	if c.calls >= c.n {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestSearchCancellation(t *testing.T) {
+	stocks := numberedStocks(100 * searchCheckEvery)
+	fullScanChecks := len(stocks) / searchCheckEvery
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantErr   error
+		maxChecks int // Err calls allowed before the scan stops
+	}{
+		{name: "cancelled before the scan", ctx: &cancelAfterContext{Context: context.Background(), n: 1}, wantErr: context.Canceled, maxChecks: 1},
+		{name: "cancelled mid-scan", ctx: &cancelAfterContext{Context: context.Background(), n: 3}, wantErr: context.Canceled, maxChecks: 3},
+		{name: "deadline passed", ctx: expired, wantErr: context.DeadlineExceeded},
+		{name: "live", ctx: &cancelAfterContext{Context: context.Background(), n: fullScanChecks + 1}, maxChecks: fullScanChecks},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSearchService(stocks...)
+
+			results, err := s.Search(tt.ctx, "test", 10)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Search error = %v, want %v", err, tt.wantErr)
+			}
+			if counted, ok := tt.ctx.(*cancelAfterContext); ok && counted.calls > tt.maxChecks {
+				t.Errorf("scan checked the context %d times, want at most %d", counted.calls, tt.maxChecks)
+			}
+			if tt.wantErr == nil {
+				if len(results) != 10 {
+					t.Errorf("got %d results, want 10", len(results))
+				}
+				return
+			}
+
+			// An aborted scan caches nothing, so the next search is complete
+			if _, cached := s.cachedMatches("test"); cached {
+				t.Error("aborted search was cached")
+			}
+			results, err = s.Search(context.Background(), "test", len(stocks))
+			if err != nil || len(results) != len(stocks) {
+				t.Errorf("search after abort = %d results, %v; want %d", len(results), err, len(stocks))
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// summaryCacheTTL bounds how stale a cached daily summary can be, covering
+// writes that bypass Database (e.g. another process on the same file)
+const summaryCacheTTL = 30 * time.Second
+
+type summaryCacheKey struct {
+	symbol string
+	days   int
+}
+
+type summaryCacheEntry struct {
+	key       summaryCacheKey
+	summaries []DailySummaryAPI
+	expires   time.Time
+}
+
+// summaryLRU caches GetDailySummary results by (symbol, days), evicting the
+// least recently used entry once size is reached. A nil *summaryLRU is a
+// disabled cache.
+type summaryLRU struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recently used
+	entries map[summaryCacheKey]*list.Element
+}
+
+// newSummaryLRU returns nil (caching disabled) when size is not positive
+func newSummaryLRU(size int) *summaryLRU {
+	if size <= 0 {
+		return nil
+	}
+	return &summaryLRU{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[summaryCacheKey]*list.Element),
+	}
+}
+
+func (l *summaryLRU) get(symbol string, days int) ([]DailySummaryAPI, bool) {
+	if l == nil {
+		return nil, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.entries[summaryCacheKey{symbol, days}]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*summaryCacheEntry)
+	if time.Now().After(entry.expires) {
+		l.order.Remove(elem)
+		delete(l.entries, entry.key)
+		return nil, false
+	}
+
+	l.order.MoveToFront(elem)
+	return append([]DailySummaryAPI(nil), entry.summaries...), true
+}
+
+func (l *summaryLRU) put(symbol string, days int, summaries []DailySummaryAPI) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := summaryCacheKey{symbol, days}
+	entry := &summaryCacheEntry{
+		key:       key,
+		summaries: append([]DailySummaryAPI(nil), summaries...),
+		expires:   time.Now().Add(summaryCacheTTL),
+	}
+
+	if elem, ok := l.entries[key]; ok {
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(entry)
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*summaryCacheEntry).key)
+	}
+}
+
+// invalidate drops every cached range for symbol
+func (l *summaryLRU) invalidate(symbol string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, elem := range l.entries {
+		if key.symbol == symbol {
+			l.order.Remove(elem)
+			delete(l.entries, key)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Watchlists are kept per tenant, so several users can share one process and
+// database. Market data (minute bars, summaries, events) is the same for
+// everyone watching a symbol and stays shared; only watchlist entries carry a
+// tenant.
+
+// DefaultTenant owns requests without an X-Tenant-ID header and every
+// watchlist entry made before tenants existed
+const DefaultTenant = "default"
+
+// maxTenantIDLength bounds X-Tenant-ID values
+const maxTenantIDLength = 64
+
+type tenantContextKey struct{}
+
+// WithTenant returns ctx scoped to tenant's watchlist
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant ctx is scoped to, or DefaultTenant
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantContextKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// isValidTenantID accepts letters, digits, '-' and '_'
+func isValidTenantID(id string) bool {
+	if id == "" || len(id) > maxTenantIDLength {
+		return false
+	}
+	for _, char := range id {
+		if !((char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') ||
+			(char >= '0' && char <= '9') || char == '-' || char == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// tenantScope limits a watched_stocks query to ctx's tenant
+func tenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	tenant := TenantFromContext(ctx)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("watched_stocks.tenant = ?", tenant)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import "runtime"
+
+// Build information, set at build time with
+//
+//	go build -ldflags "-X main.Version=v1.2.0 -X main.GitCommit=$(git rev-parse --short HEAD) -X main.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Builds without the flags report "dev".
+var (
+	Version   = "dev"
+	GitCommit = "dev"
+	BuildTime = "dev"
+)
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// buildInfo returns the build-time version details and the Go runtime version
+func buildInfo() BuildInfo {
+	return BuildInfo{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -17,13 +21,14 @@ type YahooChart struct {
 
 type ChartData struct {
 	Result []ChartResult `json:"result"`
-	Error  interface{}   `json:"error"`
+	Error  *YahooError   `json:"error"`
 }
 
 type ChartResult struct {
 	Meta    ChartMeta    `json:"meta"`
 	Timestamp []int64    `json:"timestamp"`
 	Indicators Indicators `json:"indicators"`
+	Events     *ChartEvents `json:"events"`
 }
 
 type ChartMeta struct {
@@ -31,6 +36,28 @@ type ChartMeta struct {
 	InstrumentType  string  `json:"instrumentType"`
 	RegularMarketPrice float64 `json:"regularMarketPrice"`
 	ChartPreviousClose float64 `json:"chartPreviousClose"`
+	RegularMarketTime  int64   `json:"regularMarketTime"`
+	Currency         string `json:"currency"`
+	ExchangeName     string `json:"exchangeName"`
+	FullExchangeName string `json:"fullExchangeName"`
+	LongName         string `json:"longName"`
+	ShortName        string `json:"shortName"`
+}
+
+// QuoteMeta is descriptive metadata for a symbol
+type QuoteMeta struct {
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Exchange string `json:"exchange"`
+	Currency string `json:"currency"`
+}
+
+// quoteMetaTTL is how long fetched metadata is reused before asking Yahoo again
+const quoteMetaTTL = 24 * time.Hour
+
+type cachedQuoteMeta struct {
+	meta      QuoteMeta
+	fetchedAt time.Time
 }
 
 type Indicators struct {
@@ -45,34 +72,595 @@ type Quote struct {
 	Low   []float64 `json:"low"`
 }
 
+// defaultUserAgent is sent when no User-Agent has been configured
+const defaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36"
+
 type YahooFinanceClient struct {
 	client *resty.Client
+
+	uaMu       sync.Mutex
+	userAgents []string
+	uaNext     int
+
+	// hosts are tried in order starting from preferredHost, the last one that answered
+	hostsMu       sync.Mutex
+	hosts         []string
+	preferredHost int
+
+	// sessionMu guards the crumb, which is tied to the cookie in the client's jar
+	// that cookieURL sets
+	sessionMu       sync.Mutex
+	crumb           string
+	sessionFailedAt time.Time
+	cookieURL       string
+
+	metaMu    sync.Mutex
+	metaCache map[string]cachedQuoteMeta
+
+	// extendedHours keeps zero-volume pre/post-market minute bars
+	extendedHours bool
+
+	// batchDays is how many days GetMinuteData requests at once, and
+	// batchDelay the minimum gap between the starts of those requests, of
+	// which up to batchConcurrency run at a time
+	batchDays        int
+	batchDelay       time.Duration
+	batchConcurrency int
+
+	// filterMode controls which implausible minute bars are dropped
+	filterMode string
+
+	// spikeFactor, when above 1, drops a bar whose close is more than that
+	// many times off the median close of the spikeWindow bars around it
+	spikeFactor float64
+	spikeWindow int
+
+	// onReject, when set, receives the bars each chart response's filters
+	// dropped as anomalous
+	onReject func([]RejectedBar)
 }
 
+// defaultSpikeWindow is how many neighbouring bars the spike filter compares
+// against when none is configured
+const defaultSpikeWindow = 10
+
+// Minute-bar filter modes. FilterModeStrict drops bars outside $1-$10000,
+// bars whose high/low don't bound open/close, and one-minute moves over 20%;
+// FilterModeLenient keeps only the high/low check; FilterModeOff keeps every
+// bar with real prices. Null and zero-volume bars are skipped in all modes.
+const (
+	FilterModeStrict  = "strict"
+	FilterModeLenient = "lenient"
+	FilterModeOff     = "off"
+)
+
+// isValidFilterMode reports whether mode is a supported minute-bar filter mode
+func isValidFilterMode(mode string) bool {
+	return mode == FilterModeStrict || mode == FilterModeLenient || mode == FilterModeOff
+}
+
+// Multi-batch minute fetch defaults. Yahoo serves at most 8 days of 1-minute
+// bars per request; 7 leaves a margin.
+const (
+	defaultBatchDays        = 7
+	maxBatchDays            = 8
+	defaultBatchDelay       = time.Second
+	defaultBatchConcurrency = 3
+)
+
+// Yahoo request defaults. Each attempt is bounded by defaultYahooTimeout; one
+// that times out or fails to connect is retried defaultYahooRetries times,
+// yahooRetryWait apart, before the next host is tried. A short timeout with a
+// retry recovers from a stalled connection faster than one long timeout.
+const (
+	defaultYahooTimeout = 15 * time.Second
+	defaultYahooRetries = 1
+	yahooRetryWait      = 500 * time.Millisecond
+)
+
 func NewYahooFinanceClient() *YahooFinanceClient {
 	client := resty.New()
-	client.SetTimeout(30 * time.Second)
-	client.SetHeader("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
+	client.SetTimeout(defaultYahooTimeout).
+		SetRetryCount(defaultYahooRetries).
+		SetRetryWaitTime(yahooRetryWait).
+		AddRetryHook(func(resp *resty.Response, err error) {
+			if err != nil {
+				log.Printf("Warning: Yahoo request failed, retrying: %v", err)
+			}
+		})
+
+	y := &YahooFinanceClient{
+		client:     client,
+		userAgents: []string{defaultUserAgent},
+		hosts:      defaultYahooHosts,
+		cookieURL:  yahooCookieURL,
+		metaCache:  make(map[string]cachedQuoteMeta),
+		batchDays:  defaultBatchDays,
+		batchDelay: defaultBatchDelay,
+		filterMode: FilterModeStrict,
+
+		batchConcurrency: defaultBatchConcurrency,
+	}
+	client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		req.SetHeader("User-Agent", y.nextUserAgent())
+		return nil
+	})
+
+	return y
+}
+
+// SetTimeout bounds each Yahoo request attempt by timeout and retries an
+// attempt that fails (timing out or failing to connect) up to retries times.
+// A non-positive timeout and a negative retries keep the current values.
+func (y *YahooFinanceClient) SetTimeout(timeout time.Duration, retries int) {
+	if timeout > 0 {
+		y.client.SetTimeout(timeout)
+	}
+	if retries >= 0 {
+		y.client.SetRetryCount(retries)
+	}
+}
+
+// SetExtendedHours keeps pre/post-market minute bars even when they carry no
+// volume, which Yahoo reports for most quiet extended-hours minutes
+func (y *YahooFinanceClient) SetExtendedHours(enabled bool) {
+	y.extendedHours = enabled
+}
+
+// SetBatching sets how many days each minute-data request covers (capped at
+// maxBatchDays) and how long to wait between requests. Non-positive days and
+// negative delays keep the current values.
+func (y *YahooFinanceClient) SetBatching(days int, delay time.Duration) {
+	if days > 0 {
+		if days > maxBatchDays {
+			days = maxBatchDays
+		}
+		y.batchDays = days
+	}
+	if delay >= 0 {
+		y.batchDelay = delay
+	}
+}
+
+// SetBatchConcurrency sets how many batches of a multi-batch fetch are
+// requested at once; non-positive values keep the current setting
+func (y *YahooFinanceClient) SetBatchConcurrency(n int) {
+	if n > 0 {
+		y.batchConcurrency = n
+	}
+}
+
+// SetFilterMode sets how aggressively implausible minute bars are dropped;
+// an empty mode keeps the current one
+func (y *YahooFinanceClient) SetFilterMode(mode string) {
+	if mode != "" {
+		y.filterMode = mode
+	}
+}
+
+// SetSpikeFilter enables the cross-bar spike filter: a bar whose close is
+// more than factor times above or below the median close of the window bars
+// around it is dropped. A factor of 1 or less disables it; a non-positive
+// window uses defaultSpikeWindow.
+func (y *YahooFinanceClient) SetSpikeFilter(factor float64, window int) {
+	if window <= 0 {
+		window = defaultSpikeWindow
+	}
+	y.spikeFactor = factor
+	y.spikeWindow = window
+}
+
+// SetRejectHandler has fn called with the bars the filter mode and spike
+// filter drop from each chart response; nil stops reporting them. Null,
+// non-finite and zero-volume bars are routine gaps and aren't reported.
+func (y *YahooFinanceClient) SetRejectHandler(fn func([]RejectedBar)) {
+	y.onReject = fn
+}
+
+// SetUserAgent sends ua on every request
+func (y *YahooFinanceClient) SetUserAgent(ua string) {
+	y.SetUserAgents([]string{ua})
+}
+
+// SetUserAgents rotates through uas, one per request. Blank entries are ignored;
+// an empty list restores the default User-Agent.
+func (y *YahooFinanceClient) SetUserAgents(uas []string) {
+	var agents []string
+	for _, ua := range uas {
+		if ua = strings.TrimSpace(ua); ua != "" {
+			agents = append(agents, ua)
+		}
+	}
+	if len(agents) == 0 {
+		agents = []string{defaultUserAgent}
+	}
+
+	y.uaMu.Lock()
+	y.userAgents = agents
+	y.uaNext = 0
+	y.uaMu.Unlock()
+}
+
+func (y *YahooFinanceClient) nextUserAgent() string {
+	y.uaMu.Lock()
+	defer y.uaMu.Unlock()
+
+	ua := y.userAgents[y.uaNext%len(y.userAgents)]
+	y.uaNext++
+	return ua
+}
+
+// SetProxy routes all Yahoo requests through proxyURL. An empty URL removes any
+// configured proxy.
+func (y *YahooFinanceClient) SetProxy(proxyURL string) error {
+	if proxyURL == "" {
+		y.client.RemoveProxy()
+		return nil
+	}
+
+	parsed, err := url.Parse(proxyURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q", proxyURL)
+	}
+
+	y.client.SetProxy(proxyURL)
+	return nil
+}
+
+// defaultYahooHosts serve the same API; one is often rate-limited while the other isn't
+var defaultYahooHosts = []string{
+	"https://query1.finance.yahoo.com",
+	"https://query2.finance.yahoo.com",
+}
+
+// SetHosts replaces the API hosts tried for each request. Blank entries and
+// trailing slashes are dropped; an empty list restores the defaults.
+func (y *YahooFinanceClient) SetHosts(hosts []string) {
+	var cleaned []string
+	for _, host := range hosts {
+		if host = strings.TrimRight(strings.TrimSpace(host), "/"); host != "" {
+			cleaned = append(cleaned, host)
+		}
+	}
+	if len(cleaned) == 0 {
+		cleaned = defaultYahooHosts
+	}
+
+	y.hostsMu.Lock()
+	y.hosts = cleaned
+	y.preferredHost = 0
+	y.hostsMu.Unlock()
+}
+
+// hostOrder returns the hosts rotated so the last host that answered comes first
+func (y *YahooFinanceClient) hostOrder() []string {
+	y.hostsMu.Lock()
+	defer y.hostsMu.Unlock()
+
+	order := make([]string, 0, len(y.hosts))
+	for i := range y.hosts {
+		order = append(order, y.hosts[(y.preferredHost+i)%len(y.hosts)])
+	}
+	return order
+}
+
+func (y *YahooFinanceClient) markHostHealthy(host string) {
+	y.hostsMu.Lock()
+	defer y.hostsMu.Unlock()
+
+	for i, h := range y.hosts {
+		if h == host {
+			y.preferredHost = i
+			return
+		}
+	}
+}
+
+const (
+	// yahooCookieURL sets the session cookie; it answers 404 but still sets it
+	yahooCookieURL = "https://fc.yahoo.com"
+	yahooCrumbPath = "/v1/test/getcrumb"
+
+	// sessionRetryAfter stops every request from re-attempting a failing crumb fetch
+	sessionRetryAfter = 5 * time.Minute
+)
+
+// ensureSession returns the cached crumb, fetching a session cookie and a new
+// crumb first if there isn't one yet
+func (y *YahooFinanceClient) ensureSession(ctx context.Context) (string, error) {
+	y.sessionMu.Lock()
+	defer y.sessionMu.Unlock()
+
+	if y.crumb != "" {
+		return y.crumb, nil
+	}
+	if time.Since(y.sessionFailedAt) < sessionRetryAfter {
+		return "", fmt.Errorf("session fetch failed %s ago", time.Since(y.sessionFailedAt).Round(time.Second))
+	}
+
+	crumb, err := y.fetchCrumb(ctx)
+	if err != nil {
+		log.Printf("Warning: Yahoo session unavailable, continuing without crumb: %v", err)
+		y.sessionFailedAt = time.Now()
+		return "", err
+	}
+
+	y.crumb = crumb
+	return crumb, nil
+}
+
+func (y *YahooFinanceClient) fetchCrumb(ctx context.Context) (string, error) {
+	if _, err := y.client.R().SetContext(ctx).Get(y.cookieURL); err != nil {
+		return "", fmt.Errorf("failed to fetch session cookie: %v", err)
+	}
+
+	resp, err := y.client.R().SetContext(ctx).Get(y.hostOrder()[0] + yahooCrumbPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch crumb: %v", err)
+	}
+
+	crumb := strings.TrimSpace(resp.String())
+	if resp.StatusCode() != 200 || crumb == "" || strings.Contains(crumb, "<") {
+		return "", fmt.Errorf("unexpected crumb response: status %d", resp.StatusCode())
+	}
+
+	return crumb, nil
+}
+
+// hasSession reports whether a crumb is currently cached
+func (y *YahooFinanceClient) hasSession() bool {
+	y.sessionMu.Lock()
+	defer y.sessionMu.Unlock()
+	return y.crumb != ""
+}
+
+// invalidateSession drops the cached crumb so the next request fetches a fresh one
+func (y *YahooFinanceClient) invalidateSession() {
+	y.sessionMu.Lock()
+	y.crumb = ""
+	y.sessionFailedAt = time.Time{}
+	y.sessionMu.Unlock()
+}
+
+// get requests path from each configured host in turn, moving on when a host
+// fails to answer, rate-limits (429) or errors (5xx). The last response or
+// error is returned if every host fails.
+func (y *YahooFinanceClient) get(ctx context.Context, path string) (*resty.Response, error) {
+	var lastResp *resty.Response
+	var lastErr error
+
+	for _, host := range y.hostOrder() {
+		resp, err := y.getWithSession(ctx, host+path)
+		if err == nil && resp.StatusCode() != 429 && resp.StatusCode() < 500 {
+			y.markHostHealthy(host)
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if err != nil {
+			log.Printf("Warning: Yahoo host %s failed: %v", host, err)
+		} else {
+			log.Printf("Warning: Yahoo host %s returned status %d", host, resp.StatusCode())
+		}
+		lastResp, lastErr = resp, err
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return lastResp, nil
+}
+
+// getWithSession issues a GET with the session crumb attached. Without a crumb
+// the request is still attempted, since the chart endpoint often works
+// anonymously. A 401 refreshes the session and retries once.
+func (y *YahooFinanceClient) getWithSession(ctx context.Context, url string) (*resty.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req := y.client.R().SetContext(ctx)
+
+		if crumb, err := y.ensureSession(ctx); err == nil {
+			req.SetQueryParam("crumb", crumb)
+		}
+
+		resp, err := req.Get(url)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode() != 401 || attempt > 0 {
+			return resp, nil
+		}
+
+		log.Printf("Yahoo returned 401, refreshing session")
+		y.invalidateSession()
+	}
+}
+
+// pingSymbol is a known-good symbol used to check connectivity
+const pingSymbol = "AAPL"
+
+// PingResult reports whether Yahoo is reachable and the session is usable
+type PingResult struct {
+	Host       string        `json:"host"`
+	Latency    time.Duration `json:"-"`
+	LatencyMs  int64         `json:"latencyMs"`
+	CrumbValid bool          `json:"crumbValid"`
+}
+
+// Ping fetches the chart meta for pingSymbol, bypassing the meta cache, to
+// verify connectivity and auth. The result is filled in even on failure so
+// callers can report latency and crumb state alongside the error.
+func (y *YahooFinanceClient) Ping(ctx context.Context) (PingResult, error) {
+	path := fmt.Sprintf("/v8/finance/chart/%s?range=1d&interval=1d", pingSymbol)
+
+	start := time.Now()
+	resp, err := y.get(ctx, path)
+	result := PingResult{
+		Host:       y.hostOrder()[0],
+		Latency:    time.Since(start),
+		CrumbValid: y.hasSession(),
+	}
+	result.LatencyMs = result.Latency.Milliseconds()
+
+	if err != nil {
+		return result, fmt.Errorf("failed to reach Yahoo Finance: %v", err)
+	}
+	if resp.StatusCode() != 200 {
+		return result, classifyYahooError(pingSymbol, resp.StatusCode(), parseYahooError(resp.Body()))
+	}
+	return result, nil
+}
+
+// GetQuoteMeta returns the long name, exchange and currency for a symbol from
+// the chart endpoint's meta block. Results are cached for quoteMetaTTL.
+func (y *YahooFinanceClient) GetQuoteMeta(ctx context.Context, symbol string) (QuoteMeta, error) {
+	symbol = strings.ToUpper(symbol)
+
+	y.metaMu.Lock()
+	cached, ok := y.metaCache[symbol]
+	y.metaMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < quoteMetaTTL {
+		return cached.meta, nil
+	}
+
+	chartMeta, err := y.fetchChartMeta(ctx, symbol)
+	if err != nil {
+		return QuoteMeta{}, err
+	}
+
+	meta := QuoteMeta{
+		Symbol:   symbol,
+		Name:     chartMeta.LongName,
+		Exchange: chartMeta.FullExchangeName,
+		Currency: chartMeta.Currency,
+	}
+	if meta.Name == "" {
+		meta.Name = chartMeta.ShortName
+	}
+	if meta.Exchange == "" {
+		meta.Exchange = chartMeta.ExchangeName
+	}
+
+	y.metaMu.Lock()
+	y.metaCache[symbol] = cachedQuoteMeta{meta: meta, fetchedAt: time.Now()}
+	y.metaMu.Unlock()
+
+	return meta, nil
+}
+
+// GetSpotPrice returns symbol's current regular-market price from the chart
+// meta, a single small request that doesn't touch minute data
+func (y *YahooFinanceClient) GetSpotPrice(ctx context.Context, symbol string) (SpotPrice, error) {
+	symbol = strings.ToUpper(symbol)
+
+	chartMeta, err := y.fetchChartMeta(ctx, symbol)
+	if err != nil {
+		return SpotPrice{}, err
+	}
+	if chartMeta.RegularMarketPrice <= 0 {
+		return SpotPrice{}, fmt.Errorf("no price returned for symbol %s", symbol)
+	}
+
+	spot := SpotPrice{
+		Symbol:        symbol,
+		Price:         chartMeta.RegularMarketPrice,
+		Currency:      chartMeta.Currency,
+		PreviousClose: chartMeta.ChartPreviousClose,
+		Timestamp:     time.Unix(chartMeta.RegularMarketTime, 0).UTC(),
+	}
+	if spot.PreviousClose > 0 {
+		spot.Change = spot.Price - spot.PreviousClose
+		spot.ChangePercent = (spot.Change / spot.PreviousClose) * 100
+	}
+	return spot, nil
+}
+
+// fetchChartMeta fetches the meta block of a one-day daily chart for symbol
+func (y *YahooFinanceClient) fetchChartMeta(ctx context.Context, symbol string) (ChartMeta, error) {
+	path := fmt.Sprintf("/v8/finance/chart/%s?range=1d&interval=1d", symbol)
+
+	resp, err := y.get(ctx, path)
+	if err != nil {
+		return ChartMeta{}, fmt.Errorf("failed to fetch quote meta: %v", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return ChartMeta{}, classifyYahooError(symbol, resp.StatusCode(), parseYahooError(resp.Body()))
+	}
+
+	var chart YahooChart
+	if err := json.Unmarshal(resp.Body(), &chart); err != nil {
+		return ChartMeta{}, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if chart.Chart.Error != nil {
+		return ChartMeta{}, classifyYahooError(symbol, resp.StatusCode(), chart.Chart.Error)
+	}
+
+	if len(chart.Chart.Result) == 0 {
+		return ChartMeta{}, fmt.Errorf("no data returned for symbol %s", symbol)
+	}
 
-	return &YahooFinanceClient{client: client}
+	return chart.Chart.Result[0].Meta, nil
 }
 
-func (y *YahooFinanceClient) GetHistoricalData(symbol string, period string, interval string) ([]MinuteBar, error) {
+func (y *YahooFinanceClient) GetHistoricalData(ctx context.Context, symbol string, period string, interval string) ([]MinuteBar, error) {
 	// Yahoo Finance query format
-	url := fmt.Sprintf("https://query1.finance.yahoo.com/v8/finance/chart/%s?period1=%s&period2=%s&interval=%s&includePrePost=true",
+	path := fmt.Sprintf("/v8/finance/chart/%s?period1=%s&period2=%s&interval=%s&includePrePost=true",
 		symbol,
 		strconv.FormatInt(time.Now().AddDate(0, 0, -30).Unix(), 10), // 30 days ago
 		strconv.FormatInt(time.Now().Unix(), 10),                    // now
 		interval,
 	)
 
-	resp, err := y.client.R().Get(url)
+	resp, err := y.get(ctx, path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch data: %v", err)
 	}
 
 	if resp.StatusCode() != 200 {
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode(), resp.String())
+		return nil, classifyYahooError(symbol, resp.StatusCode(), parseYahooError(resp.Body()))
+	}
+
+	var chart YahooChart
+	if err := json.Unmarshal(resp.Body(), &chart); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if chart.Chart.Error != nil {
+		return nil, classifyYahooError(symbol, resp.StatusCode(), chart.Chart.Error)
+	}
+
+	if len(chart.Chart.Result) == 0 {
+		return nil, fmt.Errorf("no data returned for symbol %s", symbol)
+	}
+
+	result := chart.Chart.Result[0]
+
+	if len(result.Indicators.Quote) == 0 {
+		return nil, fmt.Errorf("no quote data available")
+	}
+
+	return y.minuteBarsFromResult(symbol, result), nil
+}
+
+// GetDailyHistory fetches every daily bar Yahoo has for symbol (range=max),
+// which for long-listed stocks goes back decades. Each bar's Timestamp is the
+// start of its trading session. Unlike minute data, no price band or
+// percent-move filters apply: split-adjusted early prices can be well under $1
+// and daily moves over 20% are real.
+func (y *YahooFinanceClient) GetDailyHistory(ctx context.Context, symbol string) ([]MinuteBar, error) {
+	path := fmt.Sprintf("/v8/finance/chart/%s?range=max&interval=1d", symbol)
+
+	resp, err := y.get(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch daily history: %v", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return nil, classifyYahooError(symbol, resp.StatusCode(), parseYahooError(resp.Body()))
 	}
 
 	var chart YahooChart
@@ -81,7 +669,7 @@ func (y *YahooFinanceClient) GetHistoricalData(symbol string, period string, int
 	}
 
 	if chart.Chart.Error != nil {
-		return nil, fmt.Errorf("Yahoo Finance API error: %v", chart.Chart.Error)
+		return nil, classifyYahooError(symbol, resp.StatusCode(), chart.Chart.Error)
 	}
 
 	if len(chart.Chart.Result) == 0 {
@@ -102,184 +690,403 @@ func (y *YahooFinanceClient) GetHistoricalData(symbol string, period string, int
 			continue
 		}
 
-		// Skip null/zero values
+		// Skip days Yahoo returns as nulls (holidays, halts)
 		if quote.Close[i] == 0 || quote.Open[i] == 0 || quote.High[i] == 0 || quote.Low[i] == 0 {
 			continue
 		}
 
-		// Filter out anomalous data
-		open := quote.Open[i]
-		high := quote.High[i]
-		low := quote.Low[i]
-		close := quote.Close[i]
-		volume := quote.Volume[i]
+		bars = append(bars, MinuteBar{
+			Symbol:    strings.ToUpper(symbol),
+			Timestamp: time.Unix(timestamp, 0).UTC(),
+			Open:      quote.Open[i],
+			High:      quote.High[i],
+			Low:       quote.Low[i],
+			Close:     quote.Close[i],
+			Volume:    quote.Volume[i],
+			Currency:  result.Meta.Currency,
+		})
+	}
 
-		// Skip data with zero volume (likely pre/post market data)
-		if volume == 0 {
-			continue
-		}
+	return bars, nil
+}
 
-		// Basic price validation: prices should be reasonable
-		// For most stocks, price should be between $1 and $10000
-		if open < 1 || open > 10000 || high < 1 || high > 10000 || low < 1 || low > 10000 || close < 1 || close > 10000 {
-			continue
+// ProgressFunc observes a multi-batch fetch. It is called after each batch with
+// the 1-based batch number, the total batch count computed up front from the
+// requested range, and the number of bars collected so far.
+type ProgressFunc func(batch, totalBatches, barsSoFar int)
+
+// GetMinuteData fetches minute bars for the last N days, in batches via
+// GetDataRange. Optional progress callbacks are invoked after each batch, in
+// addition to the logging.
+func (y *YahooFinanceClient) GetMinuteData(ctx context.Context, symbol string, days int, onProgress ...ProgressFunc) ([]MinuteBar, error) {
+	log.Printf("Fetching %d days of minute data for %s...", days, symbol)
+
+	end := time.Now()
+	return y.GetDataRange(ctx, symbol, end.AddDate(0, 0, -days), end, "1m", onProgress...)
+}
+
+// GetDataRange fetches bars at interval between start and end. 1-minute
+// ranges longer than the batch size are fetched in batches, up to the batch
+// concurrency at a time, with request starts at least the batch delay apart;
+// other intervals are fetched in one request. If a batch fails, the bars of
+// the newer batches are kept and older ones dropped, unless there are none,
+// in which case its error is returned. Bars come back sorted by timestamp,
+// one per timestamp.
+// Optional progress callbacks are invoked as each batch completes.
+func (y *YahooFinanceClient) GetDataRange(ctx context.Context, symbol string, start, end time.Time, interval string, onProgress ...ProgressFunc) ([]MinuteBar, error) {
+	if !start.Before(end) {
+		return nil, fmt.Errorf("invalid range: start %s is not before end %s",
+			start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	log.Printf("Fetching %s data for %s from %s to %s...", interval, symbol,
+		start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
+
+	span := end.Sub(start)
+	if interval == "1m" {
+		span = time.Duration(y.batchDays) * 24 * time.Hour
+	}
+
+	// Batches newest first
+	var batches []batchRange
+	for batchEnd := end; batchEnd.After(start); {
+		batchStart := batchEnd.Add(-span)
+		if batchStart.Before(start) {
+			batchStart = start
 		}
+		batches = append(batches, batchRange{start: batchStart, end: batchEnd})
+		batchEnd = batchStart
+	}
 
-		// High should be >= other prices, Low should be <= other prices
-		if high < open || high < close || low > open || low > close {
-			continue
+	results := make([][]MinuteBar, len(batches))
+	errs := make([]error, len(batches))
+
+	var (
+		mu        sync.Mutex
+		completed int
+		barsSoFar int
+		// failedFrom is the newest failed batch; older ones aren't started,
+		// since their bars would be dropped
+		failedFrom = len(batches)
+	)
+	pace := &pacer{interval: y.batchDelay}
+	work := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(y.batchConcurrency, 1), len(batches)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				mu.Lock()
+				skip := i > failedFrom
+				mu.Unlock()
+				if skip {
+					continue
+				}
+
+				var bars []MinuteBar
+				err := pace.wait(ctx)
+				if err == nil {
+					bars, err = y.fetchBatch(ctx, symbol, interval, i+1, batches[i], start)
+				}
+
+				mu.Lock()
+				results[i], errs[i] = bars, err
+				if err != nil {
+					failedFrom = min(failedFrom, i)
+				} else {
+					completed++
+					barsSoFar += len(bars)
+					log.Printf("Batch %d completed, got %d bars", i+1, len(bars))
+					for _, progress := range onProgress {
+						if progress != nil {
+							progress(completed, len(batches), barsSoFar)
+						}
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range batches {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			break feed
 		}
+	}
+	close(work)
+	wg.Wait()
 
-		// Price change should not be too extreme (more than 20% in one minute is suspicious)
-		priceChange := close - open
-		if open > 0 {
-			changePercent := (priceChange / open) * 100
-			if changePercent > 20 || changePercent < -20 {
-				continue
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("fetch cancelled after %d of %d batches: %v", completed, len(batches), err)
+	}
+
+	var allBars []MinuteBar
+	for i := range batches {
+		if errs[i] != nil {
+			if len(allBars) == 0 {
+				return nil, errs[i]
 			}
+			log.Printf("Warning: batch %d failed, keeping %d bars: %v", i+1, len(allBars), errs[i])
+			break
 		}
+		allBars = append(allBars, results[i]...)
+	}
+	sort.SliceStable(allBars, func(i, j int) bool {
+		return allBars[i].Timestamp.Before(allBars[j].Timestamp)
+	})
+	allBars = dedupeBars(allBars)
 
-		bar := MinuteBar{
-			Symbol:    strings.ToUpper(symbol),
-			Timestamp: time.Unix(timestamp, 0),
-			Open:      open,
-			High:      high,
-			Low:       low,
-			Close:     close,
-			Volume:    volume,
+	log.Printf("Successfully fetched total of %d bars for %s", len(allBars), symbol)
+	return allBars, nil
+}
+
+// dedupeBars drops all but the last of each run of bars sharing a timestamp
+// in bars, which must be sorted by timestamp. Overlapping batches would
+// otherwise count a minute's volume twice in the daily summary.
+func dedupeBars(bars []MinuteBar) []MinuteBar {
+	deduped := bars[:0]
+	for i, bar := range bars {
+		if i+1 < len(bars) && bars[i+1].Timestamp.Equal(bar.Timestamp) {
+			continue
 		}
-		bars = append(bars, bar)
+		deduped = append(deduped, bar)
 	}
+	return deduped
+}
 
-	return bars, nil
+// batchRange is the time span one chart request of a multi-batch fetch covers
+type batchRange struct {
+	start, end time.Time
 }
 
-func (y *YahooFinanceClient) GetMinuteData(symbol string, days int) ([]MinuteBar, error) {
-	log.Printf("Fetching %d days of minute data for %s...", days, symbol)
+// fetchBatch requests one batch of bars, keeping those from rangeStart up to
+// the batch's end; bars on a batch boundary come back from both neighbouring
+// requests
+func (y *YahooFinanceClient) fetchBatch(ctx context.Context, symbol, interval string, batch int, r batchRange, rangeStart time.Time) ([]MinuteBar, error) {
+	log.Printf("Batch %d: Fetching %s to %s", batch,
+		r.start.Format("2006-01-02 15:04"), r.end.Format("2006-01-02 15:04"))
 
-	var allBars []MinuteBar
-	maxDaysPerRequest := 7 // Use 7 days to be safe (Yahoo limit is 8)
+	path := fmt.Sprintf("/v8/finance/chart/%s?period1=%s&period2=%s&interval=%s&includePrePost=true",
+		symbol,
+		strconv.FormatInt(r.start.Unix(), 10),
+		strconv.FormatInt(r.end.Unix(), 10),
+		interval,
+	)
 
-	remainingDays := days
-	batch := 1
+	resp, err := y.get(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch batch %d: %v", batch, err)
+	}
+	if resp.StatusCode() != 200 {
+		return nil, classifyYahooError(symbol, resp.StatusCode(), parseYahooError(resp.Body()))
+	}
 
-	for remainingDays > 0 {
-		daysToFetch := remainingDays
-		if daysToFetch > maxDaysPerRequest {
-			daysToFetch = maxDaysPerRequest
+	var chart YahooChart
+	if err := json.Unmarshal(resp.Body(), &chart); err != nil {
+		return nil, fmt.Errorf("failed to parse batch %d: %v", batch, err)
+	}
+	if chart.Chart.Error != nil {
+		return nil, classifyYahooError(symbol, resp.StatusCode(), chart.Chart.Error)
+	}
+	if len(chart.Chart.Result) == 0 {
+		return nil, nil
+	}
+
+	var bars []MinuteBar
+	for _, bar := range y.minuteBarsFromResult(symbol, chart.Chart.Result[0]) {
+		if !bar.Timestamp.Before(rangeStart) && bar.Timestamp.Before(r.end) {
+			bars = append(bars, bar)
 		}
+	}
+	return bars, nil
+}
 
-		// Calculate the offset for this batch
-		offsetDays := (batch - 1) * maxDaysPerRequest
-		startTime := time.Now().AddDate(0, 0, -(offsetDays + daysToFetch))
-		endTime := time.Now().AddDate(0, 0, -offsetDays)
+// pacer spaces out the starts of concurrent requests by at least interval
+type pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
 
-		log.Printf("Batch %d: Fetching %d days from %s to %s",
-			batch, daysToFetch,
-			startTime.Format("2006-01-02"),
-			endTime.Format("2006-01-02"))
+// wait blocks until the caller's turn to start a request, or ctx ends
+func (p *pacer) wait(ctx context.Context) error {
+	p.mu.Lock()
+	at := p.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	p.next = at.Add(p.interval)
+	p.mu.Unlock()
 
-		// Yahoo Finance query format for this batch
-		url := fmt.Sprintf("https://query1.finance.yahoo.com/v8/finance/chart/%s?period1=%s&period2=%s&interval=1m&includePrePost=true",
-			symbol,
-			strconv.FormatInt(startTime.Unix(), 10),
-			strconv.FormatInt(endTime.Unix(), 10),
-		)
+	select {
+	case <-time.After(time.Until(at)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-		resp, err := y.client.R().Get(url)
-		if err != nil {
-			log.Printf("Warning: failed to fetch batch %d: %v", batch, err)
-			break
-		}
+// minuteBarsFromResult converts one chart result to bars, dropping null,
+// implausible (per the filter mode) and (unless extended hours are kept)
+// zero-volume bars. Implausible bars go to the reject handler, if any.
+func (y *YahooFinanceClient) minuteBarsFromResult(symbol string, result ChartResult) []MinuteBar {
+	if len(result.Indicators.Quote) == 0 {
+		return nil
+	}
+	quote := result.Indicators.Quote[0]
 
-		if resp.StatusCode() != 200 {
-			log.Printf("Warning: batch %d returned status %d", batch, resp.StatusCode())
-			break
+	var rejected []RejectedBar
+	reject := func(bar MinuteBar, reason string) {
+		rejected = append(rejected, RejectedBar{
+			Symbol:    bar.Symbol,
+			Timestamp: bar.Timestamp,
+			Open:      bar.Open,
+			High:      bar.High,
+			Low:       bar.Low,
+			Close:     bar.Close,
+			Volume:    bar.Volume,
+			Reason:    reason,
+		})
+	}
+
+	var bars []MinuteBar
+	for i, timestamp := range result.Timestamp {
+		if i >= len(quote.Close) || i >= len(quote.Open) || i >= len(quote.High) || i >= len(quote.Low) || i >= len(quote.Volume) {
+			continue
 		}
 
-		var chart YahooChart
-		if err := json.Unmarshal(resp.Body(), &chart); err != nil {
-			log.Printf("Warning: failed to parse batch %d: %v", batch, err)
-			break
+		// Skip null/zero values
+		if quote.Close[i] == 0 || quote.Open[i] == 0 || quote.High[i] == 0 || quote.Low[i] == 0 {
+			continue
 		}
 
-		if chart.Chart.Error != nil {
-			log.Printf("Warning: batch %d API error: %v", batch, chart.Chart.Error)
-			break
+		open := quote.Open[i]
+		high := quote.High[i]
+		low := quote.Low[i]
+		close := quote.Close[i]
+		volume := quote.Volume[i]
+
+		// Skip non-finite prices whatever the filter mode
+		if !isFinite(open) || !isFinite(high) || !isFinite(low) || !isFinite(close) {
+			continue
 		}
 
-		if len(chart.Chart.Result) > 0 {
-			result := chart.Chart.Result[0]
-			if len(result.Indicators.Quote) > 0 {
-				quote := result.Indicators.Quote[0]
+		// Timestamps are stored as UTC instants; sessions are classified
+		// in New York time by classifySession itself
+		barTime := time.Unix(timestamp, 0).UTC()
 
-				for i, timestamp := range result.Timestamp {
-					if i >= len(quote.Close) || i >= len(quote.Open) || i >= len(quote.High) || i >= len(quote.Low) || i >= len(quote.Volume) {
-						continue
-					}
+		// Skip data with zero volume (likely pre/post market data),
+		// unless extended hours are being kept
+		session := classifySession(barTime)
+		if volume == 0 && !(y.extendedHours && session != SessionRegular) {
+			continue
+		}
 
-					// Skip null/zero values
-					if quote.Close[i] == 0 || quote.Open[i] == 0 || quote.High[i] == 0 || quote.Low[i] == 0 {
-						continue
-					}
+		bar := MinuteBar{
+			Symbol:    strings.ToUpper(symbol),
+			Timestamp: barTime,
+			Open:      open,
+			High:      high,
+			Low:       low,
+			Close:     close,
+			Volume:    volume,
+			Currency:  result.Meta.Currency,
+			Session:   session,
+		}
 
-					// Filter out anomalous data
-					open := quote.Open[i]
-					high := quote.High[i]
-					low := quote.Low[i]
-					close := quote.Close[i]
-					volume := quote.Volume[i]
+		// Filter out anomalous data
+		if reason := y.anomalyReason(open, high, low, close); reason != "" {
+			reject(bar, reason)
+			continue
+		}
+		bars = append(bars, bar)
+	}
 
-					// Skip data with zero volume (likely pre/post market data)
-					if volume == 0 {
-						continue
-					}
+	if y.spikeFactor > 1 {
+		bars = dropPriceSpikes(bars, y.spikeFactor, y.spikeWindow, reject)
+	}
+	if y.onReject != nil && len(rejected) > 0 {
+		y.onReject(rejected)
+	}
+	return bars
+}
 
-					// Basic price validation: prices should be reasonable
-					// For most stocks, price should be between $1 and $10000
-					if open < 1 || open > 10000 || high < 1 || high > 10000 || low < 1 || low > 10000 || close < 1 || close > 10000 {
-						continue
-					}
+// dropPriceSpikes removes bars whose close is more than factor times above or
+// below the median close of up to window neighbouring bars (half before, half
+// after, shifted inward at the ends). This catches isolated glitches, such as
+// one bar priced 10x off, that look self-consistent on their own. Dropped
+// bars are passed to reject when it isn't nil.
+func dropPriceSpikes(bars []MinuteBar, factor float64, window int, reject func(MinuteBar, string)) []MinuteBar {
+	if len(bars) < 3 {
+		return bars
+	}
+	if window > len(bars)-1 {
+		window = len(bars) - 1
+	}
 
-					// High should be >= other prices, Low should be <= other prices
-					if high < open || high < close || low > open || low > close {
-						continue
-					}
+	kept := make([]MinuteBar, 0, len(bars))
+	neighbours := make([]float64, 0, window)
+	for i, bar := range bars {
+		start := i - window/2
+		if start < 0 {
+			start = 0
+		}
+		if start+window >= len(bars) {
+			start = len(bars) - 1 - window
+		}
 
-					// Price change should not be too extreme (more than 20% in one minute is suspicious)
-					priceChange := close - open
-					if open > 0 {
-						changePercent := (priceChange / open) * 100
-						if changePercent > 20 || changePercent < -20 {
-							continue
-						}
-					}
+		neighbours = neighbours[:0]
+		for j := start; j <= start+window; j++ {
+			if j != i {
+				neighbours = append(neighbours, bars[j].Close)
+			}
+		}
 
-					bar := MinuteBar{
-						Symbol:    strings.ToUpper(symbol),
-						Timestamp: time.Unix(timestamp, 0),
-						Open:      open,
-						High:      high,
-						Low:       low,
-						Close:     close,
-						Volume:    volume,
-					}
-					allBars = append(allBars, bar)
-				}
+		ref := median(neighbours)
+		if ref > 0 && (bar.Close > ref*factor || bar.Close < ref/factor) {
+			log.Printf("Dropping %s bar at %s: close %.2f is over %.0fx off the nearby median %.2f",
+				bar.Symbol, bar.Timestamp.Format(time.RFC3339), bar.Close, factor, ref)
+			if reject != nil {
+				reject(bar, fmt.Sprintf("spike: close over %gx off the nearby median %.2f", factor, ref))
 			}
+			continue
 		}
+		kept = append(kept, bar)
+	}
+	return kept
+}
 
-		log.Printf("Batch %d completed, got %d bars", batch, len(allBars))
+// anomalyReason returns why the filter mode rejects a bar's prices, or ""
+// when it doesn't
+func (y *YahooFinanceClient) anomalyReason(open, high, low, close float64) string {
+	if y.filterMode == FilterModeOff {
+		return ""
+	}
 
-		// Add delay between requests to avoid rate limiting
-		if remainingDays > maxDaysPerRequest {
-			time.Sleep(1 * time.Second)
-		}
+	// High should be >= other prices, Low should be <= other prices
+	if high < open || high < close || low > open || low > close {
+		return "high/low don't bound open/close"
+	}
 
-		remainingDays -= daysToFetch
-		batch++
+	if y.filterMode == FilterModeLenient {
+		return ""
 	}
 
-	log.Printf("Successfully fetched total of %d minute bars for %s", len(allBars), symbol)
-	return allBars, nil
-}
\ No newline at end of file
+	// Basic price validation: prices should be reasonable
+	// For most stocks, price should be between $1 and $10000
+	if open < 1 || open > 10000 || high < 1 || high > 10000 || low < 1 || low > 10000 || close < 1 || close > 10000 {
+		return "price outside $1-$10000"
+	}
+
+	// Price change should not be too extreme (more than 20% in one minute is suspicious)
+	changePercent := (close - open) / open * 100
+	if changePercent > 20 || changePercent < -20 {
+		return fmt.Sprintf("%.1f%% move in one minute", changePercent)
+	}
+	return ""
+}
@@ -0,0 +1,969 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubCrumb is the crumb the stub Yahoo server hands out
+const stubCrumb = "stubcrumb"
+
+// newStubYahooServer starts a Yahoo stand-in that answers the session cookie
+// and crumb requests itself and passes the rest to handler
+func newStubYahooServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cookie":
+		case yahooCrumbPath:
+			w.Write([]byte(stubCrumb))
+		default:
+			handler(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newStubYahooClient returns a client whose hosts, in order, are stub servers
+// for handlers, with its session cookie fetched from the first
+func newStubYahooClient(t *testing.T, handlers ...http.HandlerFunc) *YahooFinanceClient {
+	t.Helper()
+	var hosts []string
+	for _, handler := range handlers {
+		hosts = append(hosts, newStubYahooServer(t, handler).URL)
+	}
+
+	y := NewYahooFinanceClient()
+	y.SetHosts(hosts)
+	y.cookieURL = hosts[0] + "/cookie"
+	y.SetTimeout(5*time.Second, 0)
+	y.SetBatching(0, 0)
+	return y
+}
+
+// stubBar is one bar of a stubbed chart response
+type stubBar struct {
+	at     time.Time
+	price  float64
+	volume int64
+}
+
+// chartJSON encodes a chart response for symbol with flat bars at each price
+func chartJSON(t *testing.T, symbol string, bars ...stubBar) []byte {
+	t.Helper()
+	result := ChartResult{
+		Meta:       ChartMeta{Symbol: symbol, Currency: "USD", RegularMarketPrice: 100, ChartPreviousClose: 99, RegularMarketTime: time.Now().Unix()},
+		Indicators: Indicators{Quote: []Quote{{}}},
+	}
+	quote := &result.Indicators.Quote[0]
+	for _, bar := range bars {
+		result.Timestamp = append(result.Timestamp, bar.at.Unix())
+		quote.Open = append(quote.Open, bar.price)
+		quote.High = append(quote.High, bar.price)
+		quote.Low = append(quote.Low, bar.price)
+		quote.Close = append(quote.Close, bar.price)
+		quote.Volume = append(quote.Volume, bar.volume)
+	}
+
+	body, err := json.Marshal(YahooChart{Chart: ChartData{Result: []ChartResult{result}}})
+	if err != nil {
+		t.Fatalf("marshal chart: %v", err)
+	}
+	return body
+}
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		name       string
+		userAgents []string
+		want       []string
+	}{
+		{name: "default", userAgents: nil, want: []string{defaultUserAgent, defaultUserAgent}},
+		{name: "configured", userAgents: []string{"collector-test/1.0"}, want: []string{"collector-test/1.0", "collector-test/1.0"}},
+		{name: "rotated per request", userAgents: []string{"ua-a", " ", "ua-b"}, want: []string{"ua-a", "ua-b", "ua-a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []string
+			y := newStubYahooClient(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				got = append(got, r.Header.Get("User-Agent"))
+				mu.Unlock()
+				w.Write(chartJSON(t, "AAPL"))
+			})
+			// Fetch the session up front so only chart requests are counted
+			if _, err := y.ensureSession(context.Background()); err != nil {
+				t.Fatalf("ensureSession: %v", err)
+			}
+			if tt.userAgents != nil {
+				y.SetUserAgents(tt.userAgents)
+			}
+
+			for range tt.want {
+				if _, err := y.GetSpotPrice(context.Background(), "AAPL"); err != nil {
+					t.Fatalf("GetSpotPrice: %v", err)
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d requests, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("request %d User-Agent = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSetProxy(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxy   string
+		wantErr bool
+	}{
+		{name: "http proxy", proxy: "http://127.0.0.1:8080"},
+		{name: "socks proxy", proxy: "socks5://127.0.0.1:1080"},
+		{name: "empty removes the proxy", proxy: ""},
+		{name: "missing scheme", proxy: "127.0.0.1:8080", wantErr: true},
+		{name: "missing host", proxy: "http://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewYahooFinanceClient().SetProxy(tt.proxy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetProxy(%q) error = %v, wantErr %v", tt.proxy, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionCrumb(t *testing.T) {
+	tests := []struct {
+		name string
+		// crumbs are served by successive crumb requests; "" fails the request
+		crumbs []string
+		// chartStatuses are the statuses of successive chart requests, 200
+		// once they run out
+		chartStatuses []int
+		calls         int
+		// wantCrumbs is the crumb query parameter of each chart request
+		wantCrumbs       []string
+		wantCrumbFetches int
+		wantErr          bool
+	}{
+		{
+			name:             "crumb fetched once and reused",
+			crumbs:           []string{"crumb-1"},
+			calls:            2,
+			wantCrumbs:       []string{"crumb-1", "crumb-1"},
+			wantCrumbFetches: 1,
+		},
+		{
+			name:             "401 refreshes the crumb and retries",
+			crumbs:           []string{"crumb-1", "crumb-2"},
+			chartStatuses:    []int{http.StatusUnauthorized},
+			calls:            1,
+			wantCrumbs:       []string{"crumb-1", "crumb-2"},
+			wantCrumbFetches: 2,
+		},
+		{
+			name:             "second 401 is returned",
+			crumbs:           []string{"crumb-1", "crumb-2"},
+			chartStatuses:    []int{http.StatusUnauthorized, http.StatusUnauthorized},
+			calls:            1,
+			wantCrumbs:       []string{"crumb-1", "crumb-2"},
+			wantCrumbFetches: 2,
+			wantErr:          true,
+		},
+		{
+			name:             "failed crumb fetch continues without one",
+			crumbs:           []string{""},
+			calls:            2,
+			wantCrumbs:       []string{"", ""},
+			wantCrumbFetches: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var gotCrumbs []string
+			crumbFetches, chartRequests := 0, 0
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch r.URL.Path {
+				case "/cookie":
+					http.SetCookie(w, &http.Cookie{Name: "B", Value: "session"})
+					w.WriteHeader(http.StatusNotFound)
+				case yahooCrumbPath:
+					if _, err := r.Cookie("B"); err != nil {
+						t.Errorf("crumb requested without the session cookie")
+					}
+					crumb := ""
+					if crumbFetches < len(tt.crumbs) {
+						crumb = tt.crumbs[crumbFetches]
+					}
+					crumbFetches++
+					if crumb == "" {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					w.Write([]byte(crumb))
+				default:
+					gotCrumbs = append(gotCrumbs, r.URL.Query().Get("crumb"))
+					status := http.StatusOK
+					if chartRequests < len(tt.chartStatuses) {
+						status = tt.chartStatuses[chartRequests]
+					}
+					chartRequests++
+					w.WriteHeader(status)
+					w.Write(chartJSON(t, "AAPL"))
+				}
+			}))
+			defer server.Close()
+
+			y := NewYahooFinanceClient()
+			y.SetHosts([]string{server.URL})
+			y.cookieURL = server.URL + "/cookie"
+
+			var err error
+			for i := 0; i < tt.calls; i++ {
+				_, err = y.GetSpotPrice(context.Background(), "AAPL")
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetSpotPrice error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if crumbFetches != tt.wantCrumbFetches {
+				t.Errorf("got %d crumb fetches, want %d", crumbFetches, tt.wantCrumbFetches)
+			}
+			if len(gotCrumbs) != len(tt.wantCrumbs) {
+				t.Fatalf("chart requests carried crumbs %q, want %q", gotCrumbs, tt.wantCrumbs)
+			}
+			for i := range tt.wantCrumbs {
+				if gotCrumbs[i] != tt.wantCrumbs[i] {
+					t.Errorf("chart request %d crumb = %q, want %q", i, gotCrumbs[i], tt.wantCrumbs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHostFallback(t *testing.T) {
+	// 2024-03-05 10:00-10:02 New York
+	start := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	bars := []stubBar{{start, 100, 10}, {start.Add(time.Minute), 101, 10}, {start.Add(2 * time.Minute), 102, 10}}
+
+	tests := []struct {
+		name          string
+		statuses      []int // of query1 and query2
+		wantBars      int
+		wantErr       bool
+		wantPreferred int // index of the host tried first afterwards
+	}{
+		{name: "query1 rate-limited", statuses: []int{http.StatusTooManyRequests, http.StatusOK}, wantBars: 3, wantPreferred: 1},
+		{name: "query1 server error", statuses: []int{http.StatusBadGateway, http.StatusOK}, wantBars: 3, wantPreferred: 1},
+		{name: "query1 healthy", statuses: []int{http.StatusOK, http.StatusOK}, wantBars: 3, wantPreferred: 0},
+		{name: "both rate-limited", statuses: []int{http.StatusTooManyRequests, http.StatusTooManyRequests}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers := make([]http.HandlerFunc, len(tt.statuses))
+			for i, status := range tt.statuses {
+				status := status
+				handlers[i] = func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(status)
+					if status == http.StatusOK {
+						w.Write(chartJSON(t, "AAPL", bars...))
+					}
+				}
+			}
+			y := newStubYahooClient(t, handlers...)
+			hosts := y.hostOrder()
+
+			got, err := y.GetDataRange(context.Background(), "AAPL", start, start.Add(time.Hour), "1m")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetDataRange error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrRateLimited) {
+					t.Errorf("error = %v, want ErrRateLimited", err)
+				}
+				return
+			}
+			if len(got) != tt.wantBars {
+				t.Errorf("got %d bars, want %d", len(got), tt.wantBars)
+			}
+			if first := y.hostOrder()[0]; first != hosts[tt.wantPreferred] {
+				t.Errorf("next request starts at %s, want %s", first, hosts[tt.wantPreferred])
+			}
+		})
+	}
+}
+
+func TestMinuteBarsFromResultSessions(t *testing.T) {
+	// 2024-03-05 in New York: a pre-market bar without volume, one with
+	// volume, a regular bar and a post-market bar without volume
+	pre := newYork(2024, 3, 5, 8, 0)
+	bars := []stubBar{
+		{pre, 100, 0},
+		{pre.Add(time.Minute), 100, 5},
+		{newYork(2024, 3, 5, 10, 0), 100, 10},
+		{newYork(2024, 3, 5, 17, 0), 100, 0},
+	}
+
+	tests := []struct {
+		name          string
+		extendedHours bool
+		wantSessions  []string
+	}{
+		{name: "zero-volume extended bars dropped", extendedHours: false, wantSessions: []string{SessionPre, SessionRegular}},
+		{name: "extended hours kept", extendedHours: true, wantSessions: []string{SessionPre, SessionPre, SessionRegular, SessionPost}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chart YahooChart
+			if err := json.Unmarshal(chartJSON(t, "AAPL", bars...), &chart); err != nil {
+				t.Fatalf("decode chart: %v", err)
+			}
+			y := NewYahooFinanceClient()
+			y.SetExtendedHours(tt.extendedHours)
+
+			got := y.minuteBarsFromResult("AAPL", chart.Chart.Result[0])
+			if len(got) != len(tt.wantSessions) {
+				t.Fatalf("got %d bars, want %d", len(got), len(tt.wantSessions))
+			}
+			for i, want := range tt.wantSessions {
+				if got[i].Session != want {
+					t.Errorf("bar %d at %s session = %q, want %q", i, got[i].Timestamp.Format(time.RFC3339), got[i].Session, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchCount(t *testing.T) {
+	end := time.Date(2024, 3, 8, 21, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		days      int
+		batchDays int
+		want      int
+	}{
+		{name: "30 days in weeks", days: 30, batchDays: 7, want: 5},
+		{name: "exact multiple", days: 14, batchDays: 7, want: 2},
+		{name: "single batch", days: 5, batchDays: 7, want: 1},
+		{name: "one-day batches", days: 3, batchDays: 1, want: 3},
+		{name: "batch size capped at 8 days", days: 30, batchDays: 10, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			requests := 0
+			y := newStubYahooClient(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests++
+				mu.Unlock()
+				w.Write(chartJSON(t, "AAPL"))
+			})
+			y.SetBatching(tt.batchDays, 0)
+
+			reportedTotal := 0
+			_, err := y.GetDataRange(context.Background(), "AAPL", end.AddDate(0, 0, -tt.days), end, "1m",
+				func(batch, totalBatches, barsSoFar int) { reportedTotal = totalBatches })
+			if err != nil {
+				t.Fatalf("GetDataRange: %v", err)
+			}
+			if requests != tt.want {
+				t.Errorf("made %d requests, want %d", requests, tt.want)
+			}
+			if reportedTotal != tt.want {
+				t.Errorf("progress reported %d batches, want %d", reportedTotal, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataRangeBatches(t *testing.T) {
+	start := time.Date(2024, 2, 27, 14, 30, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	type span struct{ from, to time.Time }
+	tests := []struct {
+		name     string
+		start    time.Time
+		end      time.Time
+		interval string
+		want     []span // requested periods
+		wantErr  bool
+	}{
+		{
+			name:     "shorter than a batch",
+			start:    start,
+			end:      start.Add(3 * day),
+			interval: "1m",
+			want:     []span{{start, start.Add(3 * day)}},
+		},
+		{
+			name:     "split into batches, last one partial",
+			start:    start,
+			end:      start.Add(10 * day),
+			interval: "1m",
+			want:     []span{{start.Add(3 * day), start.Add(10 * day)}, {start, start.Add(3 * day)}},
+		},
+		{
+			name:     "exact multiple of the batch",
+			start:    start,
+			end:      start.Add(14 * day),
+			interval: "1m",
+			want:     []span{{start.Add(7 * day), start.Add(14 * day)}, {start, start.Add(7 * day)}},
+		},
+		{
+			name:     "other intervals in one request",
+			start:    start,
+			end:      start.Add(30 * day),
+			interval: "5m",
+			want:     []span{{start, start.Add(30 * day)}},
+		},
+		{name: "empty range", start: start, end: start, interval: "1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []span
+			y := newStubYahooClient(t, func(w http.ResponseWriter, r *http.Request) {
+				from, _ := strconv.ParseInt(r.URL.Query().Get("period1"), 10, 64)
+				to, _ := strconv.ParseInt(r.URL.Query().Get("period2"), 10, 64)
+				mu.Lock()
+				got = append(got, span{time.Unix(from, 0).UTC(), time.Unix(to, 0).UTC()})
+				mu.Unlock()
+				w.Write(chartJSON(t, "AAPL"))
+			})
+			y.SetBatching(7, 0)
+			y.SetBatchConcurrency(1)
+
+			_, err := y.GetDataRange(context.Background(), "AAPL", tt.start, tt.end, tt.interval)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetDataRange error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("requested %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if !got[i].from.Equal(tt.want[i].from) || !got[i].to.Equal(tt.want[i].to) {
+					t.Errorf("request %d covers %s to %s, want %s to %s", i,
+						got[i].from, got[i].to, tt.want[i].from, tt.want[i].to)
+				}
+			}
+		})
+	}
+}
+
+func TestDataRangeKeepsBarsInRange(t *testing.T) {
+	start := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{name: "before the range", at: start.Add(-time.Minute), want: false},
+		{name: "range start", at: start, want: true},
+		{name: "inside", at: start.Add(30 * time.Minute), want: true},
+		{name: "range end", at: end, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := newStubYahooClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write(chartJSON(t, "AAPL", stubBar{tt.at, 100, 10}))
+			})
+
+			bars, err := y.GetDataRange(context.Background(), "AAPL", start, end, "1m")
+			if err != nil {
+				t.Fatalf("GetDataRange: %v", err)
+			}
+			if got := len(bars) == 1; got != tt.want {
+				t.Errorf("bar at %s kept = %v, want %v", tt.at.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterModes(t *testing.T) {
+	// A regular session on 2024-03-05 with one bar of each kind
+	start := newYork(2024, 3, 5, 10, 0)
+	type ohlc struct{ open, high, low, close float64 }
+	bars := []ohlc{
+		{100, 101, 99, 100},    // normal
+		{100, 100, 60, 65},     // a real 35% crash within the minute
+		{92, 90, 89, 95},       // high below the close
+		{0.5, 0.52, 0.48, 0.5}, // under $1
+		{100, 101, 99, 0},      // null close
+	}
+	result := ChartResult{
+		Meta:       ChartMeta{Symbol: "AAPL", Currency: "USD"},
+		Indicators: Indicators{Quote: []Quote{{}}},
+	}
+	quote := &result.Indicators.Quote[0]
+	for i, bar := range bars {
+		result.Timestamp = append(result.Timestamp, start.Add(time.Duration(i)*time.Minute).Unix())
+		quote.Open = append(quote.Open, bar.open)
+		quote.High = append(quote.High, bar.high)
+		quote.Low = append(quote.Low, bar.low)
+		quote.Close = append(quote.Close, bar.close)
+		quote.Volume = append(quote.Volume, 100)
+	}
+
+	tests := []struct {
+		mode string
+		want []float64 // closes of the kept bars
+	}{
+		{mode: FilterModeStrict, want: []float64{100}},
+		{mode: FilterModeLenient, want: []float64{100, 65, 0.5}},
+		{mode: FilterModeOff, want: []float64{100, 65, 95, 0.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			y := NewYahooFinanceClient()
+			y.SetFilterMode(tt.mode)
+
+			got := y.minuteBarsFromResult("AAPL", result)
+			if len(got) != len(tt.want) {
+				t.Fatalf("kept %d bars, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[i].Close != want {
+					t.Errorf("bar %d close = %v, want %v", i, got[i].Close, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTimestampsAreUTC(t *testing.T) {
+	// 2024-03-05 10:00 New York, a regular-session minute
+	at := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	body := chartJSON(t, "AAPL", stubBar{at, 100, 10}, stubBar{at.Add(time.Minute), 101, 10})
+	var chart YahooChart
+	if err := json.Unmarshal(body, &chart); err != nil {
+		t.Fatalf("decode chart: %v", err)
+	}
+
+	local := time.Local
+	t.Cleanup(func() { time.Local = local })
+
+	for _, zone := range []string{"UTC", "Asia/Tokyo", "America/Los_Angeles", "Australia/Adelaide"} {
+		t.Run(zone, func(t *testing.T) {
+			loc, err := time.LoadLocation(zone)
+			if err != nil {
+				t.Skipf("no zone data for %s: %v", zone, err)
+			}
+			// What the TZ environment variable sets at startup
+			time.Local = loc
+
+			y := newStubYahooClient(t, func(w http.ResponseWriter, r *http.Request) { w.Write(body) })
+			daily, err := y.GetDailyHistory(context.Background(), "AAPL")
+			if err != nil {
+				t.Fatalf("GetDailyHistory: %v", err)
+			}
+
+			sources := map[string][]MinuteBar{
+				"minute": y.minuteBarsFromResult("AAPL", chart.Chart.Result[0]),
+				"daily":  daily,
+			}
+			for name, bars := range sources {
+				if len(bars) != 2 {
+					t.Fatalf("%s: got %d bars, want 2", name, len(bars))
+				}
+				for i, bar := range bars {
+					want := at.Add(time.Duration(i) * time.Minute)
+					if bar.Timestamp.Location() != time.UTC || !bar.Timestamp.Equal(want) {
+						t.Errorf("%s bar %d timestamp = %s, want %s", name, i, bar.Timestamp, want)
+					}
+				}
+			}
+
+			// Stored and read back, the instant and the day it's summarized
+			// under don't move either
+			database := newTestDatabase(t)
+			ctx := context.Background()
+			if err := database.InsertWithSummary(ctx, "AAPL", sources["minute"]); err != nil {
+				t.Fatalf("InsertWithSummary: %v", err)
+			}
+			stored, err := database.GetMinuteData(ctx, "AAPL", at.Add(-time.Hour), at.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("GetMinuteData: %v", err)
+			}
+			if len(stored) != 2 || !stored[0].Timestamp.Equal(at) || stored[0].Timestamp.Location() != time.UTC {
+				t.Errorf("stored bars = %+v, want two from %s in UTC", stored, at)
+			}
+			var days []string
+			if err := database.db.Model(&StockDailySummary{}).Where("symbol = ?", "AAPL").Pluck("strftime('%Y-%m-%d', date)", &days).Error; err != nil {
+				t.Fatalf("query summary: %v", err)
+			}
+			if len(days) != 1 || days[0] != "2024-03-05" {
+				t.Errorf("summary days = %v, want [2024-03-05]", days)
+			}
+		})
+	}
+}
+
+func TestDropPriceSpikes(t *testing.T) {
+	tests := []struct {
+		name         string
+		closes       []float64
+		factor       float64
+		window       int
+		want         []float64
+		wantRejected []float64
+	}{
+		{
+			name:   "10x spike in the middle",
+			closes: []float64{100, 101, 1000, 102, 103}, factor: 5, window: 4,
+			want: []float64{100, 101, 102, 103}, wantRejected: []float64{1000},
+		},
+		{
+			name:   "10x spike first",
+			closes: []float64{1000, 100, 101, 102, 103}, factor: 5, window: 4,
+			want: []float64{100, 101, 102, 103}, wantRejected: []float64{1000},
+		},
+		{
+			name:   "10x spike last",
+			closes: []float64{100, 101, 102, 103, 1000}, factor: 5, window: 4,
+			want: []float64{100, 101, 102, 103}, wantRejected: []float64{1000},
+		},
+		{
+			name:   "tenth of the price",
+			closes: []float64{100, 101, 10, 102, 103}, factor: 5, window: 4,
+			want: []float64{100, 101, 102, 103}, wantRejected: []float64{10},
+		},
+		{
+			name:   "move within the factor",
+			closes: []float64{100, 101, 180, 102, 103}, factor: 5, window: 4,
+			want: []float64{100, 101, 180, 102, 103},
+		},
+		{
+			name:   "window wider than the bars",
+			closes: []float64{100, 1000, 101, 102}, factor: 5, window: 10,
+			want: []float64{100, 101, 102}, wantRejected: []float64{1000},
+		},
+		{
+			name:   "too few bars to compare",
+			closes: []float64{100, 1000}, factor: 5, window: 4,
+			want: []float64{100, 1000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := newYork(2024, 3, 5, 10, 0)
+			var bars []MinuteBar
+			for i, c := range tt.closes {
+				bars = append(bars, testBar("AAPL", start.Add(time.Duration(i)*time.Minute), c, 10))
+			}
+			var rejected []float64
+			got := dropPriceSpikes(bars, tt.factor, tt.window, func(bar MinuteBar, reason string) {
+				rejected = append(rejected, bar.Close)
+			})
+
+			var kept []float64
+			for _, bar := range got {
+				kept = append(kept, bar.Close)
+			}
+			if !reflect.DeepEqual(kept, tt.want) {
+				t.Errorf("kept %v, want %v", kept, tt.want)
+			}
+			if !reflect.DeepEqual(rejected, tt.wantRejected) {
+				t.Errorf("rejected %v, want %v", rejected, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestSpikeFilterOptIn(t *testing.T) {
+	start := newYork(2024, 3, 5, 10, 0)
+	var bars []stubBar
+	for i, price := range []float64{100, 101, 1000, 102, 103} {
+		bars = append(bars, stubBar{start.Add(time.Duration(i) * time.Minute), price, 10})
+	}
+	var chart YahooChart
+	if err := json.Unmarshal(chartJSON(t, "AAPL", bars...), &chart); err != nil {
+		t.Fatalf("decode chart: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		factor float64
+		want   int
+	}{
+		{name: "off by default", want: 5},
+		{name: "enabled", factor: 5, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := NewYahooFinanceClient()
+			if tt.factor > 0 {
+				y.SetSpikeFilter(tt.factor, 0)
+			}
+			if got := y.minuteBarsFromResult("AAPL", chart.Chart.Result[0]); len(got) != tt.want {
+				t.Errorf("kept %d bars, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutRetry(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		retries      int
+		slowAttempts int // leading chart requests that stall past the timeout
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{name: "fast", retries: 1, slowAttempts: 0, wantAttempts: 1},
+		{name: "retry recovers", retries: 1, slowAttempts: 1, wantAttempts: 2},
+		{name: "second retry recovers", retries: 2, slowAttempts: 2, wantAttempts: 3},
+		{name: "no retries", retries: 0, slowAttempts: 1, wantAttempts: 1, wantErr: true},
+		{name: "retries exhausted", retries: 1, slowAttempts: 5, wantAttempts: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			y := newStubYahooClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if int(attempts.Add(1)) <= tt.slowAttempts {
+					// Stall until the client gives up on the attempt
+					select {
+					case <-r.Context().Done():
+					case <-time.After(10 * time.Second):
+					}
+					return
+				}
+				w.Write(chartJSON(t, "AAPL"))
+			})
+			if _, err := y.ensureSession(context.Background()); err != nil {
+				t.Fatalf("ensureSession: %v", err)
+			}
+			y.SetTimeout(timeout, tt.retries)
+
+			began := time.Now()
+			_, err := y.GetSpotPrice(context.Background(), "AAPL")
+			elapsed := time.Since(began)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSpotPrice error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", got, tt.wantAttempts)
+			}
+			// Each stalled attempt ends at the timeout, plus the wait between
+			// attempts
+			if limit := time.Duration(tt.wantAttempts) * (timeout + yahooRetryWait + time.Second); elapsed > limit {
+				t.Errorf("took %s, want under %s", elapsed, limit)
+			}
+		})
+	}
+}
+
+// rangeBarsHandler answers each chart request with a bar every step within
+// its [period1, period2) span, or [period1, period2] when inclusive, delaying
+// requests for older spans less so batches complete out of order
+func rangeBarsHandler(t *testing.T, origin time.Time, step time.Duration, inclusive bool, inFlight, peak *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+
+		from, _ := strconv.ParseInt(r.URL.Query().Get("period1"), 10, 64)
+		to, _ := strconv.ParseInt(r.URL.Query().Get("period2"), 10, 64)
+		start, end := time.Unix(from, 0).UTC(), time.Unix(to, 0).UTC()
+		time.Sleep(time.Duration(start.Sub(origin).Hours()) * time.Millisecond / 10)
+
+		var bars []stubBar
+		for at := start; at.Before(end) || inclusive && at.Equal(end); at = at.Add(step) {
+			bars = append(bars, stubBar{at, 100 + float64(at.Sub(origin)/step)/100, 10})
+		}
+		w.Write(chartJSON(t, "AAPL", bars...))
+	}
+}
+
+func TestConcurrentBatchesComplete(t *testing.T) {
+	start := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 30)
+	step := 2 * time.Hour
+	wantBars := int(end.Sub(start) / step)
+
+	tests := []struct {
+		name        string
+		concurrency int
+		wantOverlap bool
+	}{
+		{name: "sequential", concurrency: 1},
+		{name: "two at a time", concurrency: 2, wantOverlap: true},
+		{name: "every batch at once", concurrency: 8, wantOverlap: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inFlight, peak atomic.Int32
+			y := newStubYahooClient(t, rangeBarsHandler(t, start, step, false, &inFlight, &peak))
+			y.SetBatching(7, 0)
+			y.SetBatchConcurrency(tt.concurrency)
+
+			var progress []int
+			bars, err := y.GetDataRange(context.Background(), "AAPL", start, end, "1m", func(batch, total, barsSoFar int) {
+				progress = append(progress, barsSoFar)
+			})
+			if err != nil {
+				t.Fatalf("GetDataRange: %v", err)
+			}
+
+			if len(bars) != wantBars {
+				t.Fatalf("got %d bars, want %d", len(bars), wantBars)
+			}
+			for i, bar := range bars {
+				if want := start.Add(time.Duration(i) * step); !bar.Timestamp.Equal(want) {
+					t.Fatalf("bar %d at %s, want %s", i, bar.Timestamp.Format(time.RFC3339), want.Format(time.RFC3339))
+				}
+			}
+			if len(progress) != 5 || progress[len(progress)-1] != wantBars {
+				t.Errorf("progress = %v, want 5 batches ending at %d bars", progress, wantBars)
+			}
+			if overlapped := peak.Load() > 1; overlapped != tt.wantOverlap {
+				t.Errorf("batches overlapped = %v, want %v", overlapped, tt.wantOverlap)
+			}
+			if int(peak.Load()) > tt.concurrency {
+				t.Errorf("%d batches in flight, want at most %d", peak.Load(), tt.concurrency)
+			}
+		})
+	}
+}
+
+func TestDedupeBars(t *testing.T) {
+	at := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	minute := func(i int) time.Time { return at.Add(time.Duration(i) * time.Minute) }
+
+	tests := []struct {
+		name        string
+		bars        []MinuteBar
+		wantVolumes []int64
+	}{
+		{name: "empty", bars: nil, wantVolumes: nil},
+		{
+			name:        "no duplicates",
+			bars:        []MinuteBar{testBar("AAPL", minute(0), 100, 1), testBar("AAPL", minute(1), 100, 2)},
+			wantVolumes: []int64{1, 2},
+		},
+		{
+			name:        "overlap keeps the last",
+			bars:        []MinuteBar{testBar("AAPL", minute(0), 100, 1), testBar("AAPL", minute(1), 100, 2), testBar("AAPL", minute(1), 100, 3), testBar("AAPL", minute(2), 100, 4)},
+			wantVolumes: []int64{1, 3, 4},
+		},
+		{
+			name:        "run of three",
+			bars:        []MinuteBar{testBar("AAPL", minute(0), 100, 1), testBar("AAPL", minute(0), 100, 2), testBar("AAPL", minute(0), 100, 3)},
+			wantVolumes: []int64{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var volumes []int64
+			for _, bar := range dedupeBars(tt.bars) {
+				volumes = append(volumes, bar.Volume)
+			}
+			if !reflect.DeepEqual(volumes, tt.wantVolumes) {
+				t.Errorf("volumes = %v, want %v", volumes, tt.wantVolumes)
+			}
+		})
+	}
+}
+
+func TestOverlappingBatchesVolume(t *testing.T) {
+	// Every batch also returns the bar at its end, which the next batch
+	// returns as its first
+	start := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	step := 2 * time.Hour
+
+	tests := []struct {
+		name        string
+		days        int
+		concurrency int
+	}{
+		{name: "two batches", days: 14, concurrency: 1},
+		{name: "five batches", days: 30, concurrency: 1},
+		{name: "five batches concurrently", days: 30, concurrency: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end := start.AddDate(0, 0, tt.days)
+			var inFlight, peak atomic.Int32
+			y := newStubYahooClient(t, rangeBarsHandler(t, start, step, true, &inFlight, &peak))
+			y.SetBatching(7, 0)
+			y.SetBatchConcurrency(tt.concurrency)
+
+			bars, err := y.GetDataRange(context.Background(), "AAPL", start, end, "1m")
+			if err != nil {
+				t.Fatalf("GetDataRange: %v", err)
+			}
+			wantBars := int(end.Sub(start) / step)
+			if len(bars) != wantBars {
+				t.Fatalf("got %d bars, want %d", len(bars), wantBars)
+			}
+
+			// The daily summaries count each trading-day bar's volume once
+			var want int64
+			for i := range wantBars {
+				if isTradingDate(start.Add(time.Duration(i) * step).In(marketLocation)) {
+					want += 10
+				}
+			}
+			database := newTestDatabase(t)
+			if err := database.InsertWithSummary(context.Background(), "AAPL", bars); err != nil {
+				t.Fatalf("InsertWithSummary: %v", err)
+			}
+			var total int64
+			if err := database.db.Model(&StockDailySummary{}).Where("symbol = ?", "AAPL").Select("SUM(volume)").Scan(&total).Error; err != nil {
+				t.Fatalf("sum volume: %v", err)
+			}
+			if total != want {
+				t.Errorf("summed daily volume = %d, want %d", total, want)
+			}
+		})
+	}
+}
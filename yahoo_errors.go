@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors returned (wrapped) by the Yahoo fetch methods, so callers can
+// tell a typo from a temporary failure with errors.Is
+var (
+	ErrInvalidSymbol = errors.New("invalid or unknown symbol")
+	ErrRateLimited   = errors.New("rate limited by Yahoo Finance")
+)
+
+// YahooError is the error object Yahoo embeds in chart and quoteSummary responses
+type YahooError struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+func (e *YahooError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// classifyYahooError turns a failed Yahoo response into an error, wrapping
+// ErrInvalidSymbol or ErrRateLimited when the status or Yahoo's error code
+// identifies the cause. apiErr may be nil when the body couldn't be parsed.
+func classifyYahooError(symbol string, status int, apiErr *YahooError) error {
+	detail := fmt.Sprintf("status %d", status)
+	if apiErr != nil {
+		detail = apiErr.Error()
+	}
+
+	switch {
+	case status == 429 || (apiErr != nil && strings.Contains(strings.ToLower(apiErr.Description), "too many requests")):
+		return fmt.Errorf("%w: %s", ErrRateLimited, detail)
+	case status == 404 || (apiErr != nil && apiErr.Code == "Not Found"):
+		return fmt.Errorf("%w %s: %s", ErrInvalidSymbol, symbol, detail)
+	default:
+		return fmt.Errorf("Yahoo Finance API error: %s", detail)
+	}
+}
+
+// parseYahooError extracts the embedded error object from a failed response body
+func parseYahooError(body []byte) *YahooError {
+	var chart YahooChart
+	if err := json.Unmarshal(body, &chart); err == nil && chart.Chart.Error != nil {
+		return chart.Chart.Error
+	}
+
+	var summary QuoteSummaryResponse
+	if err := json.Unmarshal(body, &summary); err == nil && summary.QuoteSummary.Error != nil {
+		return summary.QuoteSummary.Error
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Corporate event types
+const (
+	EventTypeDividend = "dividend"
+	EventTypeEarnings = "earnings"
+)
+
+type ChartEvents struct {
+	Dividends map[string]ChartDividend `json:"dividends"`
+}
+
+type ChartDividend struct {
+	Amount float64 `json:"amount"`
+	Date   int64   `json:"date"`
+}
+
+type QuoteSummaryResponse struct {
+	QuoteSummary struct {
+		Result []struct {
+			CalendarEvents CalendarEvents `json:"calendarEvents"`
+		} `json:"result"`
+		Error *YahooError `json:"error"`
+	} `json:"quoteSummary"`
+}
+
+type CalendarEvents struct {
+	Earnings struct {
+		EarningsDate []YahooRawValue `json:"earningsDate"`
+	} `json:"earnings"`
+}
+
+type YahooRawValue struct {
+	Raw int64  `json:"raw"`
+	Fmt string `json:"fmt"`
+}
+
+// GetEvents fetches dividends paid over the last year (from the chart endpoint)
+// and upcoming earnings dates (from the quoteSummary endpoint). Earnings are
+// best-effort: if quoteSummary rejects the request the dividends are still returned.
+func (y *YahooFinanceClient) GetEvents(ctx context.Context, symbol string) ([]CorporateEvent, error) {
+	symbol = strings.ToUpper(symbol)
+
+	dividends, err := y.getDividends(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	earnings, err := y.getEarningsDates(ctx, symbol)
+	if err != nil {
+		log.Printf("Warning: failed to fetch earnings dates for %s: %v", symbol, err)
+	}
+
+	return append(dividends, earnings...), nil
+}
+
+func (y *YahooFinanceClient) getDividends(ctx context.Context, symbol string) ([]CorporateEvent, error) {
+	path := fmt.Sprintf("/v8/finance/chart/%s?range=1y&interval=1d&events=div", symbol)
+
+	resp, err := y.get(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch dividends: %v", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return nil, classifyYahooError(symbol, resp.StatusCode(), parseYahooError(resp.Body()))
+	}
+
+	var chart YahooChart
+	if err := json.Unmarshal(resp.Body(), &chart); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if chart.Chart.Error != nil {
+		return nil, classifyYahooError(symbol, resp.StatusCode(), chart.Chart.Error)
+	}
+
+	events := []CorporateEvent{}
+	if len(chart.Chart.Result) == 0 || chart.Chart.Result[0].Events == nil {
+		return events, nil
+	}
+
+	for _, dividend := range chart.Chart.Result[0].Events.Dividends {
+		events = append(events, CorporateEvent{
+			Symbol: symbol,
+			Type:   EventTypeDividend,
+			Date:   marketDate(dividend.Date),
+			Amount: dividend.Amount,
+		})
+	}
+
+	return events, nil
+}
+
+func (y *YahooFinanceClient) getEarningsDates(ctx context.Context, symbol string) ([]CorporateEvent, error) {
+	path := fmt.Sprintf("/v10/finance/quoteSummary/%s?modules=calendarEvents", symbol)
+
+	resp, err := y.get(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar events: %v", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return nil, classifyYahooError(symbol, resp.StatusCode(), parseYahooError(resp.Body()))
+	}
+
+	var summary QuoteSummaryResponse
+	if err := json.Unmarshal(resp.Body(), &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if summary.QuoteSummary.Error != nil {
+		return nil, classifyYahooError(symbol, resp.StatusCode(), summary.QuoteSummary.Error)
+	}
+
+	events := []CorporateEvent{}
+	if len(summary.QuoteSummary.Result) == 0 {
+		return events, nil
+	}
+
+	for _, date := range summary.QuoteSummary.Result[0].CalendarEvents.Earnings.EarningsDate {
+		events = append(events, CorporateEvent{
+			Symbol: symbol,
+			Type:   EventTypeEarnings,
+			Date:   marketDate(date.Raw),
+		})
+	}
+
+	return events, nil
+}
+
+// marketDate converts a Unix timestamp to midnight UTC of its US Eastern calendar date,
+// matching how daily summary dates are stored
+func marketDate(unix int64) time.Time {
+	t := time.Unix(unix, 0)
+	if etLocation, err := time.LoadLocation("America/New_York"); err == nil {
+		t = t.In(etLocation)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}